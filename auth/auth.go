@@ -0,0 +1,243 @@
+// Package auth authenticates the clients of the id center by tokens or client certificates,
+// and authorises them by their roles and allowed groups.
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"go_idcenter/base"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// The roles of clients. The admin role has all the permissions of the allocate one.
+const (
+	ROLE_ALLOCATE = "allocate"
+	ROLE_ADMIN    = "admin"
+)
+
+// The permissions of operations.
+const (
+	// Get ids, reserve ranges and validate ids.
+	PERMISSION_ALLOCATE = "allocate"
+	// Create, read, update, forward and clear groups, and decode ids.
+	PERMISSION_ADMIN = "admin"
+)
+
+const (
+	// The group which stands for all groups, e.g. for listing groups.
+	ALL_GROUPS    = "*"
+	CONFIG_PREFIX = "auth.client."
+)
+
+var (
+	ErrUnauthenticated = errors.New("The token is missing or INVALID!")
+	ErrForbidden       = errors.New("The operation on the group is not allowed!")
+)
+
+type Client struct {
+	Name   string
+	Role   string
+	Groups []string
+}
+
+// Allows returns true if the client has the permission on group. The group ALL_GROUPS is
+// allowed only if the client is allowed for all groups.
+func (self *Client) Allows(permission string, group string) bool {
+	if permission == PERMISSION_ADMIN && self.Role != ROLE_ADMIN {
+		return false
+	}
+	for _, allowedGroup := range self.Groups {
+		if allowedGroup == ALL_GROUPS || allowedGroup == group {
+			return true
+		}
+	}
+	return false
+}
+
+// The authenticator keeps the clients by the digests of their tokens, so that the tokens
+// are not kept in memory and the lookup does not leak them by timing, and by the subjects
+// (common names) of their client certificates.
+type Authenticator struct {
+	clients        map[[sha256.Size]byte]*Client
+	subjectClients map[string]*Client
+}
+
+func NewAuthenticator() *Authenticator {
+	return &Authenticator{
+		clients:        make(map[[sha256.Size]byte]*Client),
+		subjectClients: make(map[string]*Client),
+	}
+}
+
+// AddClient adds the client which is identified by token.
+func (self *Authenticator) AddClient(name string, token string, role string, groups []string) error {
+	if len(token) == 0 {
+		return fmt.Errorf("The token of client '%s' is empty!", name)
+	}
+	return self.addClient(name, token, "", role, groups)
+}
+
+// AddCertificateClient adds the client which is identified by the subject (common name) of its certificate.
+func (self *Authenticator) AddCertificateClient(name string, subject string, role string, groups []string) error {
+	if len(subject) == 0 {
+		return fmt.Errorf("The certificate subject of client '%s' is empty!", name)
+	}
+	return self.addClient(name, "", subject, role, groups)
+}
+
+// addClient adds the client which is identified by token or subject, at least one of which is not empty.
+func (self *Authenticator) addClient(name string, token string, subject string, role string, groups []string) error {
+	if len(name) == 0 {
+		return errors.New("The client name is empty!")
+	}
+	if len(token) == 0 && len(subject) == 0 {
+		return fmt.Errorf("Neither the token nor the certificate subject of client '%s' is given!", name)
+	}
+	if len(token) > 0 && len(token) < 16 {
+		return fmt.Errorf("The token of client '%s' is too short! (at least 16 characters)", name)
+	}
+	if role != ROLE_ALLOCATE && role != ROLE_ADMIN {
+		return fmt.Errorf("The role '%s' of client '%s' is INVALID!", role, name)
+	}
+	if len(groups) == 0 {
+		return fmt.Errorf("The groups of client '%s' are empty!", name)
+	}
+	digest := sha256.Sum256([]byte(token))
+	if _, contains := self.clients[digest]; contains && len(token) > 0 {
+		return fmt.Errorf("The token of client '%s' is used by another client!", name)
+	}
+	if _, contains := self.subjectClients[subject]; contains && len(subject) > 0 {
+		return fmt.Errorf("The certificate subject of client '%s' is used by another client!", name)
+	}
+	client := &Client{Name: name, Role: role, Groups: groups}
+	if len(token) > 0 {
+		self.clients[digest] = client
+	}
+	if len(subject) > 0 {
+		self.subjectClients[subject] = client
+	}
+	return nil
+}
+
+// Authenticate returns the client of token, or nil if the token is unknown.
+func (self *Authenticator) Authenticate(token string) *Client {
+	if len(token) == 0 {
+		return nil
+	}
+	return self.clients[sha256.Sum256([]byte(token))]
+}
+
+// Identify returns the client of token, or the client of the verified client certificate in
+// state if the token is absent. The state is nil for the plain connection.
+func (self *Authenticator) Identify(token string, state *tls.ConnectionState) *Client {
+	if len(token) > 0 {
+		return self.Authenticate(token)
+	}
+	if subject := CertificateSubject(state); len(subject) > 0 {
+		return self.subjectClients[subject]
+	}
+	return nil
+}
+
+// Authorize returns the client identified by token or state if it has the permission on group, or
+// ErrUnauthenticated or ErrForbidden. The client is returned with ErrForbidden for auditing.
+func (self *Authenticator) Authorize(token string, state *tls.ConnectionState, permission string, group string) (*Client, error) {
+	client := self.Identify(token, state)
+	if client == nil {
+		return nil, ErrUnauthenticated
+	}
+	if !client.Allows(permission, group) {
+		return client, ErrForbidden
+	}
+	return client, nil
+}
+
+// Audit logs the denied attempt of client, which is nil if the token is unknown.
+func Audit(ctx context.Context, client *Client, remote string, permission string, group string, err error) {
+	clientName := "-"
+	if client != nil {
+		clientName = client.Name
+	}
+	base.Log(base.SUBSYSTEM_AUTH).WarnContext(ctx, "AUDIT: The access is denied.", "audit", true, "client", clientName,
+		"remote", remote, "permission", permission, base.LOG_FIELD_GROUP, group, base.LOG_FIELD_ERROR, err.Error())
+}
+
+// TokenFromRequest returns the token in the header 'Authorization: Bearer <token>' or 'X-Api-Key: <token>'.
+func TokenFromRequest(r *http.Request) string {
+	authorization := r.Header.Get("Authorization")
+	if len(authorization) > 7 && strings.EqualFold(authorization[:7], "Bearer ") {
+		return strings.TrimSpace(authorization[7:])
+	}
+	return r.Header.Get("X-Api-Key")
+}
+
+// LoadConfig creates the authenticator by the options in the form 'auth.client.<client name>.<option>=<value>':
+//
+//	token     the api key or bearer token of client, at least 16 characters
+//	subject   the subject (common name) of the client certificate, if the client certificates are verified
+//	role      allocate (default) or admin
+//	groups    the comma-separated groups which the client is allowed for, or '*' for all groups
+//
+// The result is nil if no client is configured, i.e. the authentication is disabled.
+func LoadConfig(dict map[string]string) (*Authenticator, error) {
+	type clientConfig struct {
+		token   string
+		subject string
+		role    string
+		groups  []string
+	}
+	clientConfigs := make(map[string]*clientConfig)
+	for key, value := range dict {
+		if !strings.HasPrefix(key, CONFIG_PREFIX) {
+			continue
+		}
+		index := strings.LastIndex(key, ".")
+		if index <= len(CONFIG_PREFIX) {
+			return nil, fmt.Errorf("The client name in config key '%s' is empty!", key)
+		}
+		name := key[len(CONFIG_PREFIX):index]
+		option := key[index+1:]
+		config := clientConfigs[name]
+		if config == nil {
+			config = &clientConfig{role: ROLE_ALLOCATE}
+			clientConfigs[name] = config
+		}
+		switch option {
+		case "token":
+			config.token = value
+		case "subject":
+			config.subject = value
+		case "role":
+			config.role = value
+		case "groups":
+			for _, group := range strings.Split(value, ",") {
+				if group = strings.TrimSpace(group); len(group) > 0 {
+					config.groups = append(config.groups, group)
+				}
+			}
+		default:
+			return nil, fmt.Errorf("Unknown client option '%s'! (key=%s)", option, key)
+		}
+	}
+	if len(clientConfigs) == 0 {
+		return nil, nil
+	}
+	names := make([]string, 0, len(clientConfigs))
+	for name := range clientConfigs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	authenticator := NewAuthenticator()
+	for _, name := range names {
+		config := clientConfigs[name]
+		if err := authenticator.addClient(name, config.token, config.subject, config.role, config.groups); err != nil {
+			return nil, err
+		}
+	}
+	return authenticator, nil
+}
@@ -0,0 +1,111 @@
+package auth
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+const (
+	ADMIN_TOKEN    = "admin-token-0123456789"
+	ALLOCATE_TOKEN = "allocate-token-0123456789"
+)
+
+func TestLoadConfig(t *testing.T) {
+	authenticator, err := LoadConfig(map[string]string{"id_start": "1"})
+	if err != nil || authenticator != nil {
+		t.Errorf("The authentication should be disabled without clients. (%v)", err)
+		t.FailNow()
+	}
+	authenticator, err = LoadConfig(map[string]string{
+		"auth.client.ops.token":      ADMIN_TOKEN,
+		"auth.client.ops.role":       ROLE_ADMIN,
+		"auth.client.ops.groups":     "*",
+		"auth.client.orders.token":   ALLOCATE_TOKEN,
+		"auth.client.orders.groups":  "order, invoice",
+		"auth.client.orders.comment": "",
+	})
+	if err == nil {
+		t.Errorf("The unknown option should be refused.")
+		t.FailNow()
+	}
+	authenticator, err = LoadConfig(map[string]string{
+		"auth.client.ops.token":     ADMIN_TOKEN,
+		"auth.client.ops.role":      ROLE_ADMIN,
+		"auth.client.ops.groups":    "*",
+		"auth.client.orders.token":  ALLOCATE_TOKEN,
+		"auth.client.orders.groups": "order, invoice",
+	})
+	if err != nil || authenticator == nil {
+		t.Errorf("Config loading error: %v", err)
+		t.FailNow()
+	}
+	client := authenticator.Authenticate(ALLOCATE_TOKEN)
+	if client == nil || client.Name != "orders" || client.Role != ROLE_ALLOCATE || len(client.Groups) != 2 || client.Groups[1] != "invoice" {
+		t.Errorf("Unexpected client: %v", client)
+		t.FailNow()
+	}
+	invalidDicts := []map[string]string{
+		{"auth.client.token": ADMIN_TOKEN},
+		{"auth.client.short.token": "short", "auth.client.short.groups": "*"},
+		{"auth.client.nogroup.token": ADMIN_TOKEN},
+		{"auth.client.root.token": ADMIN_TOKEN, "auth.client.root.groups": "*", "auth.client.root.role": "root"},
+		{"auth.client.a.token": ADMIN_TOKEN, "auth.client.a.groups": "*", "auth.client.b.token": ADMIN_TOKEN, "auth.client.b.groups": "*"},
+	}
+	for _, dict := range invalidDicts {
+		if _, err := LoadConfig(dict); err == nil {
+			t.Errorf("The config %v should be refused.", dict)
+		}
+	}
+}
+
+func TestAuthorize(t *testing.T) {
+	authenticator := NewAuthenticator()
+	if err := authenticator.AddClient("ops", ADMIN_TOKEN, ROLE_ADMIN, []string{ALL_GROUPS}); err != nil {
+		t.Errorf("Add client error: %s", err)
+		t.FailNow()
+	}
+	if err := authenticator.AddClient("orders", ALLOCATE_TOKEN, ROLE_ALLOCATE, []string{"order"}); err != nil {
+		t.Errorf("Add client error: %s", err)
+		t.FailNow()
+	}
+	cases := []struct {
+		token      string
+		permission string
+		group      string
+		err        error
+	}{
+		{"", PERMISSION_ALLOCATE, "order", ErrUnauthenticated},
+		{"unknown-token-0123456789", PERMISSION_ALLOCATE, "order", ErrUnauthenticated},
+		{ALLOCATE_TOKEN, PERMISSION_ALLOCATE, "order", nil},
+		{ALLOCATE_TOKEN, PERMISSION_ALLOCATE, "user", ErrForbidden},
+		{ALLOCATE_TOKEN, PERMISSION_ADMIN, "order", ErrForbidden},
+		{ALLOCATE_TOKEN, PERMISSION_ADMIN, ALL_GROUPS, ErrForbidden},
+		{ADMIN_TOKEN, PERMISSION_ALLOCATE, "user", nil},
+		{ADMIN_TOKEN, PERMISSION_ADMIN, "order", nil},
+		{ADMIN_TOKEN, PERMISSION_ADMIN, ALL_GROUPS, nil},
+	}
+	for _, c := range cases {
+		client, err := authenticator.Authorize(c.token, nil, c.permission, c.group)
+		if err != c.err {
+			t.Errorf("Unexpected error of %s on group '%s' by token '%s': %v (expected: %v)", c.permission, c.group, c.token, err, c.err)
+		}
+		if err != ErrUnauthenticated && client == nil {
+			t.Errorf("The client of token '%s' should be returned.", c.token)
+		}
+	}
+}
+
+func TestTokenFromRequest(t *testing.T) {
+	request := httptest.NewRequest("GET", "/id?group=test", nil)
+	if token := TokenFromRequest(request); token != "" {
+		t.Errorf("Unexpected token '%s'.", token)
+	}
+	request.Header.Set("X-Api-Key", ALLOCATE_TOKEN)
+	if token := TokenFromRequest(request); token != ALLOCATE_TOKEN {
+		t.Errorf("Unexpected token '%s'.", token)
+	}
+	request.Header.Set("Authorization", "bearer "+ADMIN_TOKEN)
+	if token := TokenFromRequest(request); token != ADMIN_TOKEN {
+		t.Errorf("Unexpected token '%s'.", token)
+	}
+}
@@ -0,0 +1,200 @@
+package auth
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"go_idcenter/base"
+	"os"
+	"sync"
+	"time"
+)
+
+// The client authentication of TLS, i.e. whether the client certificates are required.
+const (
+	TLS_CLIENT_AUTH_REQUIRE  = "require"
+	TLS_CLIENT_AUTH_OPTIONAL = "optional"
+)
+
+// The interval of checking the modification of the certificate files.
+var tlsReloadCheckInterval = 5 * time.Second
+
+type TlsParameter struct {
+	CertFile string
+	KeyFile  string
+	// tls.VersionTLS12 (default) or tls.VersionTLS13.
+	MinVersion uint16
+	// The CA certificates of clients in PEM. The client certificates are not requested if it is empty.
+	ClientCaFile string
+	// TLS_CLIENT_AUTH_REQUIRE (default) or TLS_CLIENT_AUTH_OPTIONAL.
+	ClientAuth string
+}
+
+// LoadTlsParameter reads the options 'tls_cert_file', 'tls_key_file', 'tls_min_version',
+// 'tls_client_ca_file' and 'tls_client_auth'. The result is nil if no certificate is
+// configured, i.e. the TLS is disabled.
+func LoadTlsParameter(dict map[string]string) (*TlsParameter, error) {
+	parameter := &TlsParameter{
+		CertFile:     dict["tls_cert_file"],
+		KeyFile:      dict["tls_key_file"],
+		ClientCaFile: dict["tls_client_ca_file"],
+		ClientAuth:   dict["tls_client_auth"],
+	}
+	if len(parameter.CertFile) == 0 && len(parameter.KeyFile) == 0 {
+		if len(parameter.ClientCaFile) > 0 {
+			return nil, errors.New("The client CA file is given without the certificate of server!")
+		}
+		return nil, nil
+	}
+	if len(parameter.CertFile) == 0 || len(parameter.KeyFile) == 0 {
+		return nil, errors.New("Both the certificate file and the key file must be given!")
+	}
+	switch dict["tls_min_version"] {
+	case "", "1.2":
+		parameter.MinVersion = tls.VersionTLS12
+	case "1.3":
+		parameter.MinVersion = tls.VersionTLS13
+	default:
+		return nil, fmt.Errorf("The TLS min version '%s' is INVALID! (1.2 or 1.3)", dict["tls_min_version"])
+	}
+	switch parameter.ClientAuth {
+	case "":
+		parameter.ClientAuth = TLS_CLIENT_AUTH_REQUIRE
+	case TLS_CLIENT_AUTH_REQUIRE, TLS_CLIENT_AUTH_OPTIONAL:
+	default:
+		return nil, fmt.Errorf("The TLS client auth '%s' is INVALID! (%s or %s)", parameter.ClientAuth, TLS_CLIENT_AUTH_REQUIRE, TLS_CLIENT_AUTH_OPTIONAL)
+	}
+	return parameter, nil
+}
+
+// The TLS reloader reloads the certificate files when they are modified, so that the
+// renewed certificates are served without restarting. The modification is checked on
+// handshakes, at most once per tlsReloadCheckInterval. If the reloading fails, the
+// previous certificates are kept.
+type TlsReloader struct {
+	parameter TlsParameter
+	sign      sync.Mutex
+	checkedAt time.Time
+	modTimes  []time.Time
+	config    *tls.Config
+}
+
+func NewTlsReloader(parameter TlsParameter) (*TlsReloader, error) {
+	reloader := &TlsReloader{parameter: parameter}
+	modTimes, err := reloader.getModTimes()
+	if err != nil {
+		return nil, err
+	}
+	config, err := reloader.load()
+	if err != nil {
+		return nil, err
+	}
+	reloader.checkedAt = time.Now()
+	reloader.modTimes = modTimes
+	reloader.config = config
+	return reloader, nil
+}
+
+// Config returns the server config which uses the current certificates, with the application
+// protocols nextProtos, e.g. 'h2' for gRPC.
+func (self *TlsReloader) Config(nextProtos ...string) *tls.Config {
+	return &tls.Config{
+		MinVersion: self.parameter.MinVersion,
+		NextProtos: nextProtos,
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			config := self.current().Clone()
+			config.NextProtos = nextProtos
+			return config, nil
+		},
+	}
+}
+
+func (self *TlsReloader) current() *tls.Config {
+	self.sign.Lock()
+	defer self.sign.Unlock()
+	if time.Since(self.checkedAt) < tlsReloadCheckInterval {
+		return self.config
+	}
+	self.checkedAt = time.Now()
+	modTimes, err := self.getModTimes()
+	if err != nil {
+		base.Logger().Errorf("Checking the certificate files error: %s\n", err)
+		return self.config
+	}
+	if equalTimes(modTimes, self.modTimes) {
+		return self.config
+	}
+	config, err := self.load()
+	if err != nil {
+		base.Logger().Errorf("Reloading the certificate files error (the previous ones are kept): %s\n", err)
+		return self.config
+	}
+	base.Logger().Infof("The certificate files are reloaded. (cert=%s)\n", self.parameter.CertFile)
+	self.modTimes = modTimes
+	self.config = config
+	return config
+}
+
+func (self *TlsReloader) load() (*tls.Config, error) {
+	certificate, err := tls.LoadX509KeyPair(self.parameter.CertFile, self.parameter.KeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("The certificate '%s' or the key '%s' is INVALID! (%s)", self.parameter.CertFile, self.parameter.KeyFile, err)
+	}
+	config := &tls.Config{
+		MinVersion:   self.parameter.MinVersion,
+		Certificates: []tls.Certificate{certificate},
+	}
+	if len(self.parameter.ClientCaFile) == 0 {
+		return config, nil
+	}
+	content, err := os.ReadFile(self.parameter.ClientCaFile)
+	if err != nil {
+		return nil, err
+	}
+	config.ClientCAs = x509.NewCertPool()
+	if !config.ClientCAs.AppendCertsFromPEM(content) {
+		return nil, fmt.Errorf("No certificate is found in the client CA file '%s'!", self.parameter.ClientCaFile)
+	}
+	config.ClientAuth = tls.RequireAndVerifyClientCert
+	if self.parameter.ClientAuth == TLS_CLIENT_AUTH_OPTIONAL {
+		config.ClientAuth = tls.VerifyClientCertIfGiven
+	}
+	return config, nil
+}
+
+func (self *TlsReloader) getModTimes() ([]time.Time, error) {
+	paths := []string{self.parameter.CertFile, self.parameter.KeyFile}
+	if len(self.parameter.ClientCaFile) > 0 {
+		paths = append(paths, self.parameter.ClientCaFile)
+	}
+	modTimes := make([]time.Time, 0, len(paths))
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		modTimes = append(modTimes, info.ModTime())
+	}
+	return modTimes, nil
+}
+
+func equalTimes(times1 []time.Time, times2 []time.Time) bool {
+	if len(times1) != len(times2) {
+		return false
+	}
+	for i := range times1 {
+		if !times1[i].Equal(times2[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// CertificateSubject returns the common name of the verified client certificate, or the empty string.
+func CertificateSubject(state *tls.ConnectionState) string {
+	if state == nil || len(state.VerifiedChains) == 0 || len(state.VerifiedChains[0]) == 0 {
+		return ""
+	}
+	return state.VerifiedChains[0][0].Subject.CommonName
+}
@@ -0,0 +1,187 @@
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+type testCertificate struct {
+	certificate *x509.Certificate
+	key         *ecdsa.PrivateKey
+	certPem     []byte
+	keyPem      []byte
+}
+
+// newTestCertificate creates the certificate of commonName, which is signed by parent or self-signed if parent is nil.
+func newTestCertificate(t *testing.T, commonName string, parent *testCertificate) *testCertificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Errorf("Key generating error: %s", err)
+		t.FailNow()
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	parentCertificate, parentKey := template, key
+	if parent == nil {
+		template.IsCA = true
+		template.BasicConstraintsValid = true
+	} else {
+		parentCertificate, parentKey = parent.certificate, parent.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, parentCertificate, &key.PublicKey, parentKey)
+	if err != nil {
+		t.Errorf("Certificate creating error: %s", err)
+		t.FailNow()
+	}
+	certificate, _ := x509.ParseCertificate(der)
+	keyDer, _ := x509.MarshalECPrivateKey(key)
+	return &testCertificate{
+		certificate: certificate,
+		key:         key,
+		certPem:     pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		keyPem:      pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}),
+	}
+}
+
+func (self *testCertificate) keyPair() tls.Certificate {
+	keyPair, _ := tls.X509KeyPair(self.certPem, self.keyPem)
+	return keyPair
+}
+
+func TestLoadTlsParameter(t *testing.T) {
+	parameter, err := LoadTlsParameter(map[string]string{})
+	if err != nil || parameter != nil {
+		t.Errorf("The TLS should be disabled without the certificate. (%v)", err)
+		t.FailNow()
+	}
+	parameter, err = LoadTlsParameter(map[string]string{"tls_cert_file": "server.pem", "tls_key_file": "server.key", "tls_client_ca_file": "ca.pem"})
+	if err != nil || parameter.MinVersion != tls.VersionTLS12 || parameter.ClientAuth != TLS_CLIENT_AUTH_REQUIRE {
+		t.Errorf("Unexpected parameter %v. (%v)", parameter, err)
+		t.FailNow()
+	}
+	invalidDicts := []map[string]string{
+		{"tls_cert_file": "server.pem"},
+		{"tls_client_ca_file": "ca.pem"},
+		{"tls_cert_file": "server.pem", "tls_key_file": "server.key", "tls_min_version": "1.0"},
+		{"tls_cert_file": "server.pem", "tls_key_file": "server.key", "tls_client_auth": "never"},
+	}
+	for _, dict := range invalidDicts {
+		if _, err := LoadTlsParameter(dict); err == nil {
+			t.Errorf("The config %v should be refused.", dict)
+		}
+	}
+}
+
+func TestTlsReloader(t *testing.T) {
+	defer func(interval time.Duration) { tlsReloadCheckInterval = interval }(tlsReloadCheckInterval)
+	tlsReloadCheckInterval = 0
+	ca := newTestCertificate(t, "Test CA", nil)
+	clientCertificate := newTestCertificate(t, "orders", ca)
+	dir := t.TempDir()
+	parameter := TlsParameter{
+		CertFile:     filepath.Join(dir, "server.pem"),
+		KeyFile:      filepath.Join(dir, "server.key"),
+		MinVersion:   tls.VersionTLS12,
+		ClientCaFile: filepath.Join(dir, "ca.pem"),
+		ClientAuth:   TLS_CLIENT_AUTH_REQUIRE,
+	}
+	writeServerCertificate := func(commonName string, modTime time.Time) {
+		serverCertificate := newTestCertificate(t, commonName, ca)
+		os.WriteFile(parameter.CertFile, serverCertificate.certPem, 0600)
+		os.WriteFile(parameter.KeyFile, serverCertificate.keyPem, 0600)
+		os.Chtimes(parameter.CertFile, modTime, modTime)
+	}
+	writeServerCertificate("server-1", time.Now().Add(-time.Minute))
+	os.WriteFile(parameter.ClientCaFile, ca.certPem, 0600)
+	reloader, err := NewTlsReloader(parameter)
+	if err != nil {
+		t.Errorf("Reloader creating error: %s", err)
+		t.FailNow()
+	}
+	listener, err := tls.Listen("tcp", "127.0.0.1:0", reloader.Config())
+	if err != nil {
+		t.Errorf("Listen error: %s", err)
+		t.FailNow()
+	}
+	defer listener.Close()
+	states := make(chan *tls.ConnectionState, 1)
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			tlsConn := conn.(*tls.Conn)
+			if err := tlsConn.Handshake(); err != nil {
+				states <- nil
+			} else {
+				state := tlsConn.ConnectionState()
+				states <- &state
+			}
+			conn.Close()
+		}
+	}()
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.certificate)
+	handshake := func(certificates []tls.Certificate) (string, *tls.ConnectionState, error) {
+		conn, err := tls.Dial("tcp", listener.Addr().String(), &tls.Config{RootCAs: roots, Certificates: certificates})
+		if err != nil {
+			<-states
+			return "", nil, err
+		}
+		defer conn.Close()
+		// The client certificate is checked by the server after the handshake of client in TLS 1.3.
+		conn.Read(make([]byte, 1))
+		return conn.ConnectionState().PeerCertificates[0].Subject.CommonName, <-states, nil
+	}
+
+	serverName, state, err := handshake([]tls.Certificate{clientCertificate.keyPair()})
+	if err != nil || serverName != "server-1" || state == nil {
+		t.Errorf("Unexpected handshake (server=%s, state=%v): %v", serverName, state, err)
+		t.FailNow()
+	}
+	if subject := CertificateSubject(state); subject != "orders" {
+		t.Errorf("Unexpected client certificate subject '%s'.", subject)
+	}
+	authenticator := NewAuthenticator()
+	authenticator.AddCertificateClient("orders", "orders", ROLE_ALLOCATE, []string{"order"})
+	if client, err := authenticator.Authorize("", state, PERMISSION_ALLOCATE, "order"); err != nil || client.Name != "orders" {
+		t.Errorf("The client certificate should be authorized. (%v)", err)
+	}
+	if _, err := authenticator.Authorize("", nil, PERMISSION_ALLOCATE, "order"); err != ErrUnauthenticated {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	if _, state, _ := handshake(nil); state != nil {
+		t.Errorf("The client without certificate should be refused.")
+	}
+
+	writeServerCertificate("server-2", time.Now())
+	serverName, _, err = handshake([]tls.Certificate{clientCertificate.keyPair()})
+	if err != nil || serverName != "server-2" {
+		t.Errorf("The renewed certificate should be served, but: %s (%v)", serverName, err)
+	}
+	os.WriteFile(parameter.KeyFile, []byte("broken"), 0600)
+	os.Chtimes(parameter.KeyFile, time.Now().Add(time.Minute), time.Now().Add(time.Minute))
+	serverName, _, err = handshake([]tls.Certificate{clientCertificate.keyPair()})
+	if err != nil || serverName != "server-2" {
+		t.Errorf("The previous certificate should be kept, but: %s (%v)", serverName, err)
+	}
+}
@@ -9,8 +9,10 @@ const (
 	CONFIG_FILE_NAME = "id_center.config"
 )
 
-var logger logging.Logger = logging.GetSimpleLogger()
+var logger logging.Logger = legacyLogger{}
 
+// Logger returns the logger of the free-form messages, which are written as the structured
+// records of the subsystem of caller. The new code should use Log for the fields.
 func Logger() logging.Logger {
 	return logger
 }
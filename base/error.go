@@ -1,9 +1,109 @@
 package base
 
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+)
+
+// The kinds of errors, which are checked by errors.Is, e.g. errors.Is(err, ErrGroupNotFound).
+var (
+	ErrInvalidGroupName   = errors.New("The group name is INVALID!")
+	ErrInvalidArgument    = errors.New("The argument is INVALID!")
+	ErrGroupNotFound      = errors.New("The group is not found!")
+	ErrStorageUnavailable = errors.New("The storage is unavailable!")
+	ErrCacheUnavailable   = errors.New("The cache is unavailable!")
+	ErrExhausted          = errors.New("The ids are exhausted!")
+	ErrTimeout            = errors.New("The operation is timed out!")
+	ErrCanceled           = errors.New("The operation is canceled!")
+	ErrConflict           = errors.New("The operation conflicts with the group!")
+	ErrShutDown           = errors.New("The id center is shut down!")
+	ErrProviderNotFound   = errors.New("The provider is not registered!")
+	ErrInternal           = errors.New("The internal error occurs!")
+)
+
+// The operations of errors.
+const (
+	OP_GET_ID       = "get_id"
+	OP_BUILD_LIST   = "build_list"
+	OP_POP          = "pop"
+	OP_BUILD_INFO   = "build_info"
+	OP_GET          = "get"
+	OP_LIST         = "list"
+	OP_UPDATE       = "update"
+	OP_PROPEL       = "propel"
+	OP_RESERVE      = "reserve"
+	OP_FORWARD      = "forward"
+	OP_CLEAR        = "clear"
+	OP_CHECK_HEALTH = "check_health"
+	OP_DECODE       = "decode"
+	OP_VALIDATE     = "validate"
+	OP_REGISTER     = "register"
+	OP_UNREGISTER   = "unregister"
+	OP_DRAIN        = "drain"
+)
+
+// Error is the error of an operation on group, whose kind is one of the errors above, and which
+// wraps the cause, so that both of them can be checked by errors.Is and errors.As.
+type Error struct {
+	Kind  error
+	Op    string
+	Group string
+	Cause error
+}
+
+// NewError returns the error of op on group, whose cause may be nil.
+func NewError(kind error, op string, group string, cause error) *Error {
+	return &Error{Kind: kind, Op: op, Group: group, Cause: cause}
+}
+
+func (e *Error) Error() string {
+	errorMsg := fmt.Sprintf("%s (op=%s", e.Kind, e.Op)
+	if len(e.Group) > 0 {
+		errorMsg += fmt.Sprintf(", group=%s", e.Group)
+	}
+	errorMsg += ")"
+	if e.Cause != nil {
+		errorMsg += ": " + e.Cause.Error()
+	}
+	return errorMsg
+}
+
+func (e *Error) Unwrap() []error {
+	if e.Cause == nil {
+		return []error{e.Kind}
+	}
+	return []error{e.Kind, e.Cause}
+}
+
+// UnavailableError returns the error of op on group whose cause is returned by the storage or the
+// cache (kind is ErrStorageUnavailable or ErrCacheUnavailable). The timeout of network is ErrTimeout.
+func UnavailableError(kind error, op string, group string, cause error) *Error {
+	var netErr net.Error
+	if errors.As(cause, &netErr) && netErr.Timeout() {
+		kind = ErrTimeout
+	}
+	return NewError(kind, op, group, cause)
+}
+
 type EmptyListError struct {
 	Msg string
 }
 
-func (e EmptyListError) Error() string {
+func (e *EmptyListError) Error() string {
 	return e.Msg
 }
+
+// CheckContext returns the error of op on group which wraps the one of ctx if ctx is done, or nil.
+// Its kind is ErrTimeout if the deadline passes, or ErrCanceled.
+func CheckContext(ctx context.Context, op string, group string) error {
+	err := ctx.Err()
+	if err == nil {
+		return nil
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return NewError(ErrTimeout, op, group, err)
+	}
+	return NewError(ErrCanceled, op, group, err)
+}
@@ -0,0 +1,65 @@
+package base
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+)
+
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+var _ net.Error = timeoutError{}
+
+func TestError(t *testing.T) {
+	cause := errors.New("connection refused")
+	err := error(UnavailableError(ErrStorageUnavailable, OP_PROPEL, "error_test", cause))
+	if !errors.Is(err, ErrStorageUnavailable) || !errors.Is(err, cause) {
+		t.Errorf("The error should be both the kind and the cause: %s", err)
+		t.FailNow()
+	}
+	var idCenterErr *Error
+	if !errors.As(err, &idCenterErr) || idCenterErr.Op != OP_PROPEL || idCenterErr.Group != "error_test" {
+		t.Errorf("Unexpected error: %#v", idCenterErr)
+		t.FailNow()
+	}
+	expectedMsg := "The storage is unavailable! (op=propel, group=error_test): connection refused"
+	if err.Error() != expectedMsg {
+		t.Errorf("The message of error should be %q, but %q.", expectedMsg, err.Error())
+		t.FailNow()
+	}
+	err = UnavailableError(ErrCacheUnavailable, OP_POP, "error_test", timeoutError{})
+	if !errors.Is(err, ErrTimeout) || errors.Is(err, ErrCacheUnavailable) {
+		t.Errorf("The timeout of network should be ErrTimeout: %s", err)
+		t.FailNow()
+	}
+	if err = NewError(ErrGroupNotFound, OP_GET, "", nil); err.Error() != "The group is not found! (op=get)" {
+		t.Errorf("Unexpected message of error: %s", err)
+		t.FailNow()
+	}
+}
+
+func TestCheckContext(t *testing.T) {
+	if err := CheckContext(context.Background(), OP_GET_ID, "error_test"); err != nil {
+		t.Errorf("The error should be nil, but %s.", err)
+		t.FailNow()
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := CheckContext(ctx, OP_GET_ID, "error_test"); !errors.Is(err, ErrCanceled) || !errors.Is(err, context.Canceled) {
+		t.Errorf("The error should be ErrCanceled, but %v.", err)
+		t.FailNow()
+	}
+	ctx, cancel = context.WithTimeout(context.Background(), time.Nanosecond)
+	defer cancel()
+	<-ctx.Done()
+	if err := CheckContext(ctx, OP_GET_ID, "error_test"); !errors.Is(err, ErrTimeout) || !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("The error should be ErrTimeout, but %v.", err)
+		t.FailNow()
+	}
+}
@@ -0,0 +1,329 @@
+package base
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"go_lib/logging"
+	"io"
+	"log/slog"
+	"os"
+	"reflect"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+)
+
+// The subsystems of logging, each of which can have its own level.
+const (
+	SUBSYSTEM_SERVER   = "server"
+	SUBSYSTEM_FRONTEND = "frontend"
+	SUBSYSTEM_RPC      = "rpc"
+	SUBSYSTEM_MANAGER  = "manager"
+	SUBSYSTEM_PROVIDER = "provider"
+	SUBSYSTEM_AUTH     = "auth"
+)
+
+// The fields of log records.
+const (
+	LOG_FIELD_SUBSYSTEM   = "subsystem"
+	LOG_FIELD_REQUEST_ID  = "request_id"
+	LOG_FIELD_GROUP       = "group"
+	LOG_FIELD_OP          = "op"
+	LOG_FIELD_PATH        = "path"
+	LOG_FIELD_PROVIDER    = "provider"
+	LOG_FIELD_LATENCY     = "latency_ms"
+	LOG_FIELD_ERROR       = "error"
+	LOG_FIELD_ERROR_CLASS = "error_class"
+)
+
+const (
+	LOG_FORMAT_JSON = "json"
+	LOG_FORMAT_TEXT = "text"
+	// The level of the fatal messages. The process exits after Logger().Fatal* like before.
+	LevelFatal = slog.Level(12)
+	// The max length of the request id given by client.
+	MAX_REQUEST_ID_LENGTH = 64
+)
+
+type requestIdKey struct{}
+
+var logSign sync.RWMutex
+var logHandler slog.Handler = slog.NewJSONHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelDebug, ReplaceAttr: replaceLevel})
+var defaultLogLevel = slog.LevelInfo
+var logLevels = map[string]slog.Level{}
+var subsystemLoggers = map[string]*slog.Logger{}
+
+// ConfigureLogging sets the format (LOG_FORMAT_JSON or LOG_FORMAT_TEXT) and the output of logs, the default
+// level, and the levels of subsystems.
+func ConfigureLogging(writer io.Writer, format string, defaultLevel slog.Level, levels map[string]slog.Level) error {
+	options := &slog.HandlerOptions{Level: slog.LevelDebug, ReplaceAttr: replaceLevel}
+	var handler slog.Handler
+	switch format {
+	case LOG_FORMAT_JSON:
+		handler = slog.NewJSONHandler(writer, options)
+	case LOG_FORMAT_TEXT:
+		handler = slog.NewTextHandler(writer, options)
+	default:
+		return fmt.Errorf("The log format '%s' is INVALID!", format)
+	}
+	currentLevels := make(map[string]slog.Level, len(levels))
+	for subsystem, level := range levels {
+		currentLevels[subsystem] = level
+	}
+	logSign.Lock()
+	defer logSign.Unlock()
+	logHandler = handler
+	defaultLogLevel = defaultLevel
+	logLevels = currentLevels
+	subsystemLoggers = map[string]*slog.Logger{}
+	return nil
+}
+
+// LoadLogConfig configures the logging to stderr by the options 'log_format' (json by default),
+// 'log_level' (info by default) and 'log_level.<subsystem>', e.g. 'log_level.provider=debug'.
+func LoadLogConfig(dict map[string]string) error {
+	format := dict["log_format"]
+	if len(format) == 0 {
+		format = LOG_FORMAT_JSON
+	}
+	defaultLevel := slog.LevelInfo
+	levels := map[string]slog.Level{}
+	for key, value := range dict {
+		if key != "log_level" && !strings.HasPrefix(key, "log_level.") {
+			continue
+		}
+		level, err := ParseLogLevel(value)
+		if err != nil {
+			return err
+		}
+		if key == "log_level" {
+			defaultLevel = level
+		} else {
+			levels[strings.TrimPrefix(key, "log_level.")] = level
+		}
+	}
+	return ConfigureLogging(os.Stderr, format, defaultLevel, levels)
+}
+
+// ParseLogLevel parses the level: debug, info, warn, error or fatal.
+func ParseLogLevel(literal string) (slog.Level, error) {
+	if strings.EqualFold(literal, "fatal") {
+		return LevelFatal, nil
+	}
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(literal)); err != nil {
+		return level, fmt.Errorf("The log level '%s' is INVALID!", literal)
+	}
+	return level, nil
+}
+
+func replaceLevel(groups []string, attr slog.Attr) slog.Attr {
+	if attr.Key == slog.LevelKey && len(groups) == 0 {
+		if level, ok := attr.Value.Any().(slog.Level); ok && level >= LevelFatal {
+			return slog.String(slog.LevelKey, "FATAL")
+		}
+	}
+	return attr
+}
+
+// Log returns the structured logger of subsystem. The request id in the context of a record
+// is added as the field LOG_FIELD_REQUEST_ID, e.g. by Log(SUBSYSTEM_MANAGER).InfoContext(ctx, ...).
+func Log(subsystem string) *slog.Logger {
+	logSign.RLock()
+	logger, contains := subsystemLoggers[subsystem]
+	logSign.RUnlock()
+	if contains {
+		return logger
+	}
+	logSign.Lock()
+	defer logSign.Unlock()
+	if logger, contains = subsystemLoggers[subsystem]; !contains {
+		level, contains := logLevels[subsystem]
+		if !contains {
+			level = defaultLogLevel
+		}
+		handler := logHandler.WithAttrs([]slog.Attr{slog.String(LOG_FIELD_SUBSYSTEM, subsystem)})
+		logger = slog.New(&subsystemHandler{Handler: handler, level: level})
+		subsystemLoggers[subsystem] = logger
+	}
+	return logger
+}
+
+// The subsystem handler filters the records by the level of subsystem, and adds the request id.
+type subsystemHandler struct {
+	slog.Handler
+	level slog.Level
+}
+
+func (self *subsystemHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return level >= self.level
+}
+
+func (self *subsystemHandler) Handle(ctx context.Context, record slog.Record) error {
+	if requestId := RequestId(ctx); len(requestId) > 0 {
+		record.AddAttrs(slog.String(LOG_FIELD_REQUEST_ID, requestId))
+	}
+	return self.Handler.Handle(ctx, record)
+}
+
+func (self *subsystemHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &subsystemHandler{Handler: self.Handler.WithAttrs(attrs), level: self.level}
+}
+
+func (self *subsystemHandler) WithGroup(name string) slog.Handler {
+	return &subsystemHandler{Handler: self.Handler.WithGroup(name), level: self.level}
+}
+
+// NewRequestId returns a random id of 16 hex digits.
+func NewRequestId() string {
+	var buffer [8]byte
+	rand.Read(buffer[:])
+	return hex.EncodeToString(buffer[:])
+}
+
+// IsValidRequestId returns true if the request id given by client is not empty, not too long, and in visible ASCII characters.
+func IsValidRequestId(requestId string) bool {
+	if len(requestId) == 0 || len(requestId) > MAX_REQUEST_ID_LENGTH {
+		return false
+	}
+	for i := 0; i < len(requestId); i++ {
+		if requestId[i] <= ' ' || requestId[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+// WithRequestId returns the context which carries the request id, for correlating the logs of a request.
+func WithRequestId(ctx context.Context, requestId string) context.Context {
+	return context.WithValue(ctx, requestIdKey{}, requestId)
+}
+
+// RequestId returns the request id carried by ctx, or the empty string.
+func RequestId(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	requestId, _ := ctx.Value(requestIdKey{}).(string)
+	return requestId
+}
+
+// ErrorClass returns the type of err for the field LOG_FIELD_ERROR_CLASS, e.g. '*errors.errorString'.
+// The type of the cause is returned for *Error if it has one, e.g. '*net.OpError'.
+func ErrorClass(err error) string {
+	if err == nil {
+		return ""
+	}
+	if idCenterErr, ok := err.(*Error); ok && idCenterErr.Cause != nil {
+		return ErrorClass(idCenterErr.Cause)
+	}
+	return reflect.TypeOf(err).String()
+}
+
+// Latency returns the milliseconds since start for the field LOG_FIELD_LATENCY.
+func Latency(start time.Time) float64 {
+	return float64(time.Since(start).Microseconds()) / 1000
+}
+
+// The legacy logger writes the free-form messages of Logger() as the records of the subsystem
+// which is the package of caller, so that they are filtered by the levels of subsystems too.
+type legacyLogger struct{}
+
+func (self legacyLogger) log(level slog.Level, message string) {
+	var pcs [1]uintptr
+	runtime.Callers(3, pcs[:])
+	logger := Log(callerSubsystem(pcs[0]))
+	ctx := context.Background()
+	if !logger.Enabled(ctx, level) {
+		return
+	}
+	record := slog.NewRecord(time.Now(), level, strings.TrimRight(message, "\n"), pcs[0])
+	logger.Handler().Handle(ctx, record)
+}
+
+// callerSubsystem returns the package name of the function of pc, or SUBSYSTEM_SERVER for the main package.
+func callerSubsystem(pc uintptr) string {
+	frame, _ := runtime.CallersFrames([]uintptr{pc}).Next()
+	name := frame.Function
+	if len(name) == 0 {
+		return SUBSYSTEM_SERVER
+	}
+	if index := strings.LastIndex(name, "/"); index >= 0 {
+		name = name[index+1:]
+	}
+	if index := strings.Index(name, "."); index >= 0 {
+		name = name[:index]
+	}
+	if name == "main" || name == "go_idcenter" {
+		return SUBSYSTEM_SERVER
+	}
+	return name
+}
+
+func (self legacyLogger) Error(v ...interface{}) {
+	self.log(slog.LevelError, fmt.Sprint(v...))
+}
+
+func (self legacyLogger) Errorf(format string, v ...interface{}) {
+	self.log(slog.LevelError, fmt.Sprintf(format, v...))
+}
+
+func (self legacyLogger) Errorln(v ...interface{}) {
+	self.log(slog.LevelError, fmt.Sprintln(v...))
+}
+
+func (self legacyLogger) Fatal(v ...interface{}) {
+	self.log(LevelFatal, fmt.Sprint(v...))
+	os.Exit(1)
+}
+
+func (self legacyLogger) Fatalf(format string, v ...interface{}) {
+	self.log(LevelFatal, fmt.Sprintf(format, v...))
+	os.Exit(1)
+}
+
+func (self legacyLogger) Fatalln(v ...interface{}) {
+	self.log(LevelFatal, fmt.Sprintln(v...))
+	os.Exit(1)
+}
+
+func (self legacyLogger) Info(v ...interface{}) {
+	self.log(slog.LevelInfo, fmt.Sprint(v...))
+}
+
+func (self legacyLogger) Infof(format string, v ...interface{}) {
+	self.log(slog.LevelInfo, fmt.Sprintf(format, v...))
+}
+
+func (self legacyLogger) Infoln(v ...interface{}) {
+	self.log(slog.LevelInfo, fmt.Sprintln(v...))
+}
+
+func (self legacyLogger) Debug(v ...interface{}) {
+	self.log(slog.LevelDebug, fmt.Sprint(v...))
+}
+
+func (self legacyLogger) Debugf(format string, v ...interface{}) {
+	self.log(slog.LevelDebug, fmt.Sprintf(format, v...))
+}
+
+func (self legacyLogger) Debugln(v ...interface{}) {
+	self.log(slog.LevelDebug, fmt.Sprintln(v...))
+}
+
+func (self legacyLogger) Warn(v ...interface{}) {
+	self.log(slog.LevelWarn, fmt.Sprint(v...))
+}
+
+func (self legacyLogger) Warnf(format string, v ...interface{}) {
+	self.log(slog.LevelWarn, fmt.Sprintf(format, v...))
+}
+
+func (self legacyLogger) Warnln(v ...interface{}) {
+	self.log(slog.LevelWarn, fmt.Sprintln(v...))
+}
+
+var _ logging.Logger = legacyLogger{}
@@ -0,0 +1,84 @@
+package base
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestSubsystemLogging(t *testing.T) {
+	var buffer bytes.Buffer
+	levels := map[string]slog.Level{SUBSYSTEM_MANAGER: slog.LevelDebug}
+	if err := ConfigureLogging(&buffer, LOG_FORMAT_JSON, slog.LevelWarn, levels); err != nil {
+		t.Errorf("Configure error: %s", err)
+		t.FailNow()
+	}
+	defer ConfigureLogging(os.Stderr, LOG_FORMAT_JSON, slog.LevelInfo, nil)
+
+	ctx := WithRequestId(context.Background(), "test-request")
+	Log(SUBSYSTEM_FRONTEND).InfoContext(ctx, "The filtered message.")
+	Log(SUBSYSTEM_MANAGER).DebugContext(ctx, "The debug message.", LOG_FIELD_GROUP, "order")
+	lines := strings.Split(strings.TrimSpace(buffer.String()), "\n")
+	if len(lines) != 1 {
+		t.Errorf("Unexpected logs: %s", buffer.String())
+		t.FailNow()
+	}
+	var record map[string]interface{}
+	if err := json.Unmarshal([]byte(lines[0]), &record); err != nil {
+		t.Errorf("The log '%s' is not in json: %s", lines[0], err)
+		t.FailNow()
+	}
+	expectedFields := map[string]string{"level": "DEBUG", "msg": "The debug message.", LOG_FIELD_SUBSYSTEM: SUBSYSTEM_MANAGER,
+		LOG_FIELD_REQUEST_ID: "test-request", LOG_FIELD_GROUP: "order"}
+	for key, value := range expectedFields {
+		if record[key] != value {
+			t.Errorf("The field '%s' of log is '%v', but '%s' expected.", key, record[key], value)
+		}
+	}
+
+	// The legacy logger takes the subsystem of the caller, i.e. 'base' here, which is at the default level.
+	buffer.Reset()
+	Logger().Infoln("The filtered legacy message.")
+	Logger().Errorf("The legacy message (%d).\n", 1)
+	if output := buffer.String(); strings.Contains(output, "filtered") ||
+		!strings.Contains(output, `"level":"ERROR","msg":"The legacy message (1)."`) || !strings.Contains(output, `"subsystem":"base"`) {
+		t.Errorf("Unexpected logs: %s", output)
+	}
+}
+
+func TestLoadLogConfig(t *testing.T) {
+	defer ConfigureLogging(os.Stderr, LOG_FORMAT_JSON, slog.LevelInfo, nil)
+	if err := LoadLogConfig(map[string]string{"log_format": "xml"}); err == nil {
+		t.Errorf("The log format 'xml' should be INVALID!")
+	}
+	if err := LoadLogConfig(map[string]string{"log_level.provider": "verbose"}); err == nil {
+		t.Errorf("The log level 'verbose' should be INVALID!")
+	}
+	if err := LoadLogConfig(map[string]string{"log_format": "text", "log_level": "error", "log_level.provider": "debug"}); err != nil {
+		t.Errorf("Load error: %s", err)
+		t.FailNow()
+	}
+	if Log(SUBSYSTEM_SERVER).Enabled(context.Background(), slog.LevelWarn) || !Log(SUBSYSTEM_PROVIDER).Enabled(context.Background(), slog.LevelDebug) {
+		t.Errorf("The levels of subsystems are not configured.")
+	}
+	if level, err := ParseLogLevel("FATAL"); err != nil || level != LevelFatal {
+		t.Errorf("Unexpected level of 'FATAL': %v (%v)", level, err)
+	}
+}
+
+func TestIsValidRequestId(t *testing.T) {
+	expectedResults := map[string]bool{"": false, "abc-123": true, "a b": false, "a\nb": false,
+		strings.Repeat("a", MAX_REQUEST_ID_LENGTH): true, strings.Repeat("a", MAX_REQUEST_ID_LENGTH+1): false}
+	for requestId, expectedResult := range expectedResults {
+		if IsValidRequestId(requestId) != expectedResult {
+			t.Errorf("The validity of request id '%q' should be %v.", requestId, expectedResult)
+		}
+	}
+	if requestId := NewRequestId(); len(requestId) != 16 || !IsValidRequestId(requestId) {
+		t.Errorf("The generated request id '%s' is INVALID!", requestId)
+	}
+}
@@ -1,6 +1,8 @@
 package base
 
 import (
+	"context"
+	"math/rand"
 	"time"
 )
 
@@ -8,14 +10,69 @@ type GroupInfo struct {
 	Name         string
 	Start        uint64
 	Step         uint32
+	Offset       uint64
+	Increment    uint32
+	Bound        uint64 // The exclusive upper limit of ids, zero means unbounded.
 	Count        uint64
 	Range        IdRange
 	LastModified time.Time
 }
 
+// The ids of range are in [Begin, End), and every two adjacent ones differ by Increment.
 type IdRange struct {
-	Begin uint64
-	End   uint64
+	Begin     uint64
+	End       uint64
+	Increment uint32
+}
+
+// Align returns the least id which is not less than the given one and
+// belongs to the offset of group, like the 'auto_increment_offset' of MySQL.
+func (self GroupInfo) Align(id uint64) uint64 {
+	return Align(id, self.Offset, self.Increment)
+}
+
+// Align returns the least id which is not less than the given one and equals offset modulo increment.
+func Align(id uint64, offset uint64, increment uint32) uint64 {
+	if increment <= 1 {
+		return id
+	}
+	if id <= offset {
+		return offset
+	}
+	remainder := (id - offset) % uint64(increment)
+	if remainder == 0 {
+		return id
+	}
+	return id + uint64(increment) - remainder
+}
+
+func (self IdRange) Ids() []uint64 {
+	increment := uint64(self.Increment)
+	if increment == 0 {
+		increment = 1
+	}
+	if self.End <= self.Begin {
+		return []uint64{}
+	}
+	ids := make([]uint64, 0, (self.End-self.Begin+increment-1)/increment)
+	for id := self.Begin; id < self.End; id += increment {
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// ShuffledIds returns the ids of range in the order of the permutation seeded by seed.
+// The zero seed keeps the ascending order.
+func (self IdRange) ShuffledIds(seed int64) []uint64 {
+	ids := self.Ids()
+	if seed == 0 {
+		return ids
+	}
+	random := rand.New(rand.NewSource(seed))
+	random.Shuffle(len(ids), func(i, j int) {
+		ids[i], ids[j] = ids[j], ids[i]
+	})
+	return ids
 }
 
 type Provider interface {
@@ -24,15 +81,71 @@ type Provider interface {
 
 type CacheProvider interface {
 	Name() string
-	BuildList(group string, begin uint64, end uint64) (bool, error)
+	BuildList(group string, idRange IdRange, seed int64) (bool, error)
 	Pop(group string) (uint64, error)
 	Clear(group string) (bool, error)
 }
 
 type StorageProvider interface {
 	Name() string
-	BuildInfo(group string, start uint64, step uint32) (bool, error)
+	BuildInfo(group string, start uint64, step uint32, offset uint64, increment uint32) (bool, error)
 	Get(group string) (*GroupInfo, error)
-	Propel(group string) (*IdRange, error)
+	List() ([]GroupInfo, error)
+	Update(group string, step uint32, bound uint64) (bool, error)
+	// Propel moves the range of group forward by step ids. The ids of range equal offset modulo increment,
+	// which are the ones of the calling instance rather than the ones stored with the group, so that
+	// the instances of different offsets never issue the same ids from a shared group.
+	Propel(group string, offset uint64, increment uint32) (*IdRange, error)
+	// Reserve moves the range of group forward by size ids like Propel, which are never cached.
+	Reserve(group string, size uint64, offset uint64, increment uint32) (*IdRange, error)
+	// Forward moves the range of group forward, so that the next range begins at or after next.
+	// The range is never moved backward. The result is false if the group does not exist.
+	Forward(group string, next uint64) (bool, error)
 	Clear(group string) (bool, error)
 }
+
+// ContextCacheProvider is optionally implemented by the cache provider whose operations give up
+// when ctx is done, e.g. the client is gone or the deadline passes.
+type ContextCacheProvider interface {
+	CacheProvider
+	BuildListContext(ctx context.Context, group string, idRange IdRange, seed int64) (bool, error)
+	PopContext(ctx context.Context, group string) (uint64, error)
+	ClearContext(ctx context.Context, group string) (bool, error)
+}
+
+// ContextStorageProvider is optionally implemented by the storage provider whose operations give up
+// when ctx is done. The range which is propelled but given up is skipped, and its ids are never issued.
+type ContextStorageProvider interface {
+	StorageProvider
+	BuildInfoContext(ctx context.Context, group string, start uint64, step uint32, offset uint64, increment uint32) (bool, error)
+	GetContext(ctx context.Context, group string) (*GroupInfo, error)
+	ListContext(ctx context.Context) ([]GroupInfo, error)
+	UpdateContext(ctx context.Context, group string, step uint32, bound uint64) (bool, error)
+	PropelContext(ctx context.Context, group string, offset uint64, increment uint32) (*IdRange, error)
+	ReserveContext(ctx context.Context, group string, size uint64, offset uint64, increment uint32) (*IdRange, error)
+	ForwardContext(ctx context.Context, group string, next uint64) (bool, error)
+	ClearContext(ctx context.Context, group string) (bool, error)
+}
+
+// HealthChecker is optionally implemented by the provider which can check its connectivity.
+type HealthChecker interface {
+	CheckHealth() error
+}
+
+// ContextHealthChecker is optionally implemented by the health checker whose check gives up when ctx is done.
+type ContextHealthChecker interface {
+	CheckHealthContext(ctx context.Context) error
+}
+
+// Drainer is optionally implemented by the cache provider which keeps the ids in the process memory.
+type Drainer interface {
+	// Drain removes and returns the ids of all groups.
+	Drain() (map[string][]uint64, error)
+}
+
+// Retainer is optionally implemented by the cache provider which keeps the ids outside the process,
+// e.g. in Redis, where they outlive the process.
+type Retainer interface {
+	// Retained returns the count of the ids of group which are kept.
+	Retained(group string) (uint64, error)
+}
@@ -0,0 +1,69 @@
+package base
+
+import (
+	"sort"
+	"testing"
+)
+
+func TestGroupInfoAlign(t *testing.T) {
+	groupInfo := GroupInfo{Name: "test", Start: 1, Step: 10, Offset: 2, Increment: 3}
+	expectedIds := map[uint64]uint64{0: 2, 1: 2, 2: 2, 3: 5, 4: 5, 5: 5, 6: 8, 100: 101}
+	for id, expectedId := range expectedIds {
+		if alignedId := groupInfo.Align(id); alignedId != expectedId {
+			t.Errorf("The aligned id '%d' of '%d' is not equals '%d'.", alignedId, id, expectedId)
+		}
+	}
+	groupInfo = GroupInfo{Name: "test", Start: 1, Step: 10, Offset: 1, Increment: 1}
+	if alignedId := groupInfo.Align(7); alignedId != 7 {
+		t.Errorf("The aligned id '%d' of '%d' is not equals '%d'.", alignedId, 7, 7)
+	}
+}
+
+func TestIdRangeIds(t *testing.T) {
+	ids := IdRange{Begin: 2, End: 11, Increment: 3}.Ids()
+	expectedIds := []uint64{2, 5, 8}
+	if len(ids) != len(expectedIds) {
+		t.Errorf("The ids '%v' are not equals '%v'.", ids, expectedIds)
+		t.FailNow()
+	}
+	for i, id := range ids {
+		if id != expectedIds[i] {
+			t.Errorf("The ids '%v' are not equals '%v'.", ids, expectedIds)
+			t.FailNow()
+		}
+	}
+	if ids := (IdRange{Begin: 1, End: 101}).Ids(); len(ids) != 100 || ids[99] != 100 {
+		t.Errorf("Unexpected ids of range [1, 101): %v", ids)
+	}
+}
+
+func TestIdRangeShuffledIds(t *testing.T) {
+	idRange := IdRange{Begin: 1, End: 1001, Increment: 2}
+	ids := idRange.ShuffledIds(20131018)
+	sameIds := idRange.ShuffledIds(20131018)
+	ascending := true
+	for i, id := range ids {
+		if id != sameIds[i] {
+			t.Errorf("The permutations of the same seed are different. (index=%d)", i)
+			t.FailNow()
+		}
+		if i > 0 && id < ids[i-1] {
+			ascending = false
+		}
+	}
+	if ascending {
+		t.Error("The ids are not shuffled!")
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	expectedIds := idRange.ShuffledIds(0)
+	if len(ids) != len(expectedIds) {
+		t.Errorf("The number of shuffled ids '%d' is not equals '%d'.", len(ids), len(expectedIds))
+		t.FailNow()
+	}
+	for i, id := range ids {
+		if id != expectedIds[i] {
+			t.Errorf("The shuffled ids are not a permutation of the range. (index=%d)", i)
+			t.FailNow()
+		}
+	}
+}
@@ -0,0 +1,306 @@
+// Package client is the Go client of the id center.
+//
+// The client fetches the ids in batches by the http api (POST /v1/groups/<name>/ids),
+// keeps them in a local buffer per group, and refills the buffer in the background
+// before it runs out. The requests fail over between the endpoints with retries and
+// exponential backoff.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	DEFAULT_BATCH_SIZE      = 100
+	DEFAULT_MAX_ATTEMPTS    = 3
+	DEFAULT_INITIAL_BACKOFF = 50 * time.Millisecond
+	DEFAULT_MAX_BACKOFF     = 2 * time.Second
+	DEFAULT_TIMEOUT         = 5 * time.Second
+	// The max count of ids per request, which the servers accept.
+	MAX_BATCH_SIZE = 1000
+)
+
+// ErrClosed is returned by Next after Close is called.
+var ErrClosed = errors.New("The id center client is closed!")
+
+// ServerError is returned when the id center rejects the request, e.g. for the
+// invalid group name. It is not retried on the other endpoints.
+type ServerError struct {
+	Endpoint string
+	Status   int
+	Code     string
+	Message  string
+}
+
+func (self *ServerError) Error() string {
+	return fmt.Sprintf("The id center '%s' rejects the request (status=%d, code=%s): %s", self.Endpoint, self.Status, self.Code, self.Message)
+}
+
+// UnavailableError is returned when all attempts on the endpoints fail.
+type UnavailableError struct {
+	Errors []error
+}
+
+func (self *UnavailableError) Error() string {
+	messages := make([]string, 0, len(self.Errors))
+	for _, err := range self.Errors {
+		messages = append(messages, err.Error())
+	}
+	return fmt.Sprintf("The id center is unavailable after %d attempts: %s", len(self.Errors), strings.Join(messages, "; "))
+}
+
+type Config struct {
+	// The base urls of the id centers, e.g. http://10.0.0.1:9092.
+	Endpoints []string
+	// The count of ids fetched per request, in [1, MAX_BATCH_SIZE]. The default is DEFAULT_BATCH_SIZE.
+	BatchSize int
+	// The buffer is refilled in the background when the count of buffered ids is below it.
+	// The default is a quarter of BatchSize.
+	RefillThreshold int
+	// The max count of attempts per batch, which are made on the endpoints in turn.
+	MaxAttempts    int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	HttpClient     *http.Client
+	// The bearer token of the client, if the authentication of servers is enabled.
+	Token string
+}
+
+type Client struct {
+	config   Config
+	ctx      context.Context
+	cancel   context.CancelFunc
+	sign     sync.Mutex
+	buffers  map[string]*groupBuffer
+	endpoint int
+}
+
+type groupBuffer struct {
+	ids       []uint64
+	refilling bool
+	// It is closed when the refilling in progress is done.
+	refilled chan struct{}
+	lastErr  error
+}
+
+func NewClient(config Config) (*Client, error) {
+	if len(config.Endpoints) == 0 {
+		return nil, errors.New("The endpoints of id center are empty!")
+	}
+	config.Endpoints = append([]string(nil), config.Endpoints...)
+	for i, endpoint := range config.Endpoints {
+		if _, err := url.Parse(endpoint); err != nil {
+			return nil, fmt.Errorf("The endpoint '%s' is INVALID! (%s)", endpoint, err)
+		}
+		config.Endpoints[i] = strings.TrimRight(endpoint, "/")
+	}
+	if config.BatchSize <= 0 {
+		config.BatchSize = DEFAULT_BATCH_SIZE
+	}
+	if config.BatchSize > MAX_BATCH_SIZE {
+		return nil, fmt.Errorf("The batch size '%d' exceeds %d!", config.BatchSize, MAX_BATCH_SIZE)
+	}
+	if config.RefillThreshold <= 0 {
+		config.RefillThreshold = (config.BatchSize + 3) / 4
+	}
+	if config.MaxAttempts <= 0 {
+		config.MaxAttempts = DEFAULT_MAX_ATTEMPTS
+	}
+	if config.InitialBackoff <= 0 {
+		config.InitialBackoff = DEFAULT_INITIAL_BACKOFF
+	}
+	if config.MaxBackoff <= 0 {
+		config.MaxBackoff = DEFAULT_MAX_BACKOFF
+	}
+	if config.HttpClient == nil {
+		config.HttpClient = &http.Client{Timeout: DEFAULT_TIMEOUT}
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Client{config: config, ctx: ctx, cancel: cancel, buffers: make(map[string]*groupBuffer)}, nil
+}
+
+// Next returns the next id of group. It waits for the refilling if the buffer is empty.
+func (self *Client) Next(ctx context.Context, group string) (uint64, error) {
+	for {
+		self.sign.Lock()
+		if self.ctx.Err() != nil {
+			self.sign.Unlock()
+			return 0, ErrClosed
+		}
+		buffer := self.buffers[group]
+		if buffer == nil {
+			buffer = &groupBuffer{}
+			self.buffers[group] = buffer
+		}
+		if len(buffer.ids) > 0 {
+			id := buffer.ids[0]
+			buffer.ids = buffer.ids[1:]
+			if len(buffer.ids) < self.config.RefillThreshold {
+				self.refill(group, buffer)
+			}
+			self.sign.Unlock()
+			return id, nil
+		}
+		if buffer.lastErr != nil && !buffer.refilling {
+			// Report the failure of last refilling once, and retry on the next call.
+			err := buffer.lastErr
+			buffer.lastErr = nil
+			self.sign.Unlock()
+			return 0, err
+		}
+		self.refill(group, buffer)
+		refilled := buffer.refilled
+		self.sign.Unlock()
+		select {
+		case <-refilled:
+		case <-ctx.Done():
+			return 0, ctx.Err()
+		}
+	}
+}
+
+// Close stops the refilling in progress. The buffered ids are dropped.
+func (self *Client) Close() error {
+	self.sign.Lock()
+	defer self.sign.Unlock()
+	self.cancel()
+	self.buffers = make(map[string]*groupBuffer)
+	return nil
+}
+
+// refill starts the refilling of buffer if it is not in progress. The lock must be held.
+func (self *Client) refill(group string, buffer *groupBuffer) {
+	if buffer.refilling {
+		return
+	}
+	buffer.refilling = true
+	buffer.refilled = make(chan struct{})
+	go func() {
+		ids, err := self.fetch(self.ctx, group)
+		self.sign.Lock()
+		defer self.sign.Unlock()
+		if err != nil {
+			buffer.lastErr = err
+		} else {
+			// The failure of a former refilling is stale once the ids are refilled.
+			buffer.ids = append(buffer.ids, ids...)
+			buffer.lastErr = nil
+		}
+		buffer.refilling = false
+		close(buffer.refilled)
+	}()
+}
+
+// fetch requests a batch of ids, failing over between the endpoints.
+func (self *Client) fetch(ctx context.Context, group string) ([]uint64, error) {
+	body, _ := json.Marshal(map[string]int{"count": self.config.BatchSize})
+	backoff := self.config.InitialBackoff
+	var errs []error
+	for attempt := 0; attempt < self.config.MaxAttempts; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-time.After(backoff):
+			case <-ctx.Done():
+				return nil, ErrClosed
+			}
+			backoff *= 2
+			if backoff > self.config.MaxBackoff {
+				backoff = self.config.MaxBackoff
+			}
+		}
+		endpoint := self.currentEndpoint()
+		ids, err := self.fetchFrom(ctx, endpoint, group, body)
+		if err == nil {
+			return ids, nil
+		}
+		if ctx.Err() != nil {
+			return nil, ErrClosed
+		}
+		if _, ok := err.(*ServerError); ok {
+			return nil, err
+		}
+		errs = append(errs, err)
+		self.failOver(endpoint)
+	}
+	return nil, &UnavailableError{Errors: errs}
+}
+
+func (self *Client) fetchFrom(ctx context.Context, endpoint string, group string, body []byte) ([]uint64, error) {
+	requestUrl := endpoint + "/v1/groups/" + url.PathEscape(group) + "/ids"
+	request, err := http.NewRequestWithContext(ctx, "POST", requestUrl, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("Accept", "application/json")
+	if len(self.config.Token) > 0 {
+		request.Header.Set("Authorization", "Bearer "+self.config.Token)
+	}
+	response, err := self.config.HttpClient.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+	content, err := io.ReadAll(response.Body)
+	if err != nil {
+		return nil, err
+	}
+	if response.StatusCode != http.StatusOK {
+		var errorContent struct {
+			Code    string `json:"code"`
+			Message string `json:"message"`
+		}
+		json.Unmarshal(content, &errorContent)
+		serverErr := &ServerError{Endpoint: endpoint, Status: response.StatusCode, Code: errorContent.Code, Message: errorContent.Message}
+		if response.StatusCode >= 500 || response.StatusCode == http.StatusTooManyRequests {
+			// It may be served by the other endpoints.
+			return nil, errors.New(serverErr.Error())
+		}
+		return nil, serverErr
+	}
+	// The ids are in json strings, and the numbers of the former servers are accepted too.
+	var idsContent struct {
+		Ids []json.Number `json:"ids"`
+	}
+	if err := json.Unmarshal(content, &idsContent); err != nil {
+		return nil, fmt.Errorf("The response of '%s' is INVALID! (%s)", endpoint, err)
+	}
+	if len(idsContent.Ids) == 0 {
+		return nil, fmt.Errorf("The response of '%s' has no id!", endpoint)
+	}
+	ids := make([]uint64, len(idsContent.Ids))
+	for i, literal := range idsContent.Ids {
+		id, err := strconv.ParseUint(literal.String(), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("The id '%s' in the response of '%s' is INVALID!", literal, endpoint)
+		}
+		ids[i] = id
+	}
+	return ids, nil
+}
+
+func (self *Client) currentEndpoint() string {
+	self.sign.Lock()
+	defer self.sign.Unlock()
+	return self.config.Endpoints[self.endpoint]
+}
+
+// failOver switches to the next endpoint, unless it is switched by the other request.
+func (self *Client) failOver(endpoint string) {
+	self.sign.Lock()
+	defer self.sign.Unlock()
+	if self.config.Endpoints[self.endpoint] == endpoint {
+		self.endpoint = (self.endpoint + 1) % len(self.config.Endpoints)
+	}
+}
@@ -0,0 +1,128 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"go_idcenter/frontend"
+	"go_idcenter/manager"
+	"go_idcenter/provider/providertest"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func startIdCenter(t *testing.T) *httptest.Server {
+	cp := providertest.NewMemoryCacheProvider("Memory Cache Provider (" + t.Name() + ")")
+	sp := providertest.NewMemoryStorageProvider("Memory Storage Provider (" + t.Name() + ")")
+	manager.RegisterProvider(cp)
+	manager.RegisterProvider(sp)
+	idCenterManager := &manager.IdCenterManager{
+		CacheProviderName:   cp.Name(),
+		StorageProviderName: sp.Name(),
+		Start:               1,
+		Step:                10,
+	}
+	server := httptest.NewServer(frontend.NewHttpFrontend(idCenterManager))
+	t.Cleanup(func() {
+		server.Close()
+		manager.UnregisterProvider(cp)
+		manager.UnregisterProvider(sp)
+	})
+	return server
+}
+
+func TestClientNext(t *testing.T) {
+	server := startIdCenter(t)
+	// The first endpoint is down, so the requests fail over to the second one.
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer down.Close()
+	client, err := NewClient(Config{Endpoints: []string{down.URL, server.URL}, BatchSize: 7, InitialBackoff: time.Millisecond})
+	if err != nil {
+		t.Errorf("New client error: %s", err)
+		t.FailNow()
+	}
+	defer client.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	var lastId uint64
+	for i := 0; i < 50; i++ {
+		id, err := client.Next(ctx, "client_test")
+		if err != nil {
+			t.Errorf("Next error: %s", err)
+			t.FailNow()
+		}
+		if id <= lastId {
+			t.Errorf("The id %d is not greater than the last one %d.", id, lastId)
+			t.FailNow()
+		}
+		lastId = id
+	}
+}
+
+func TestClientErrors(t *testing.T) {
+	server := startIdCenter(t)
+	if _, err := NewClient(Config{Endpoints: []string{server.URL}, BatchSize: MAX_BATCH_SIZE + 1}); err == nil {
+		t.Errorf("The batch size beyond %d should be rejected.", MAX_BATCH_SIZE)
+		t.FailNow()
+	}
+	rejecting := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"code":"invalid_group","message":"The group name is INVALID!"}`))
+	}))
+	defer rejecting.Close()
+	client, _ := NewClient(Config{Endpoints: []string{rejecting.URL}})
+	_, err := client.Next(context.Background(), "client_test")
+	if serverErr, ok := err.(*ServerError); !ok || serverErr.Status != http.StatusBadRequest {
+		t.Errorf("The error %v should be a server error of status 400.", err)
+		t.FailNow()
+	}
+	client.Close()
+	if _, err := client.Next(context.Background(), "client_test"); err != ErrClosed {
+		t.Errorf("The error %v should be ErrClosed.", err)
+		t.FailNow()
+	}
+
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer down.Close()
+	client, _ = NewClient(Config{Endpoints: []string{down.URL}, MaxAttempts: 2, InitialBackoff: time.Millisecond})
+	defer client.Close()
+	_, err = client.Next(context.Background(), "client_test")
+	if unavailableErr, ok := err.(*UnavailableError); !ok || len(unavailableErr.Errors) != 2 {
+		t.Errorf("The error %v should be an unavailable error of 2 attempts.", err)
+		t.FailNow()
+	}
+}
+
+func TestClientRecovery(t *testing.T) {
+	var requests int32
+	flaky := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The first batch fails, and the later ones are the ids from 10 * n.
+		n := atomic.AddInt32(&requests, 1)
+		if n == 1 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		fmt.Fprintf(w, `{"group":"client_test","ids":["%d","%d"]}`, 10*n, 10*n+1)
+	}))
+	defer flaky.Close()
+	client, _ := NewClient(Config{Endpoints: []string{flaky.URL}, BatchSize: 2, MaxAttempts: 1})
+	defer client.Close()
+	if _, err := client.Next(context.Background(), "client_test"); err == nil {
+		t.Errorf("The failure of the first batch should be reported.")
+		t.FailNow()
+	}
+	// The failure is not reported again after the ids are refilled.
+	for i := 0; i < 10; i++ {
+		id, err := client.Next(context.Background(), "client_test")
+		if err != nil || id < 20 {
+			t.Errorf("Unexpected id %d after the recovery. (%v)", id, err)
+			t.FailNow()
+		}
+	}
+}
@@ -0,0 +1,273 @@
+package main
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go_idcenter/base"
+	"go_idcenter/frontend"
+	"go_idcenter/manager"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// The backend executes the commands against a running server or a storage provider.
+type backend interface {
+	GetIds(group string, count int) ([]uint64, error)
+	ListGroups() ([]base.GroupInfo, error)
+	// GetGroup returns nil if the group does not exist.
+	GetGroup(group string) (*base.GroupInfo, error)
+	// ForwardGroup returns false if the group does not exist.
+	ForwardGroup(group string, next uint64) (bool, error)
+	ClearGroup(group string) (bool, error)
+	// ImportGroup returns false if the group already exists.
+	ImportGroup(groupInfo base.GroupInfo) (bool, error)
+}
+
+// The server backend uses the admin api (/v1) of the running server.
+type serverBackend struct {
+	endpoint   string
+	token      string
+	httpClient *http.Client
+}
+
+func newServerBackend(endpoint string, token string, tlsConfig *tls.Config) *serverBackend {
+	httpClient := &http.Client{Timeout: 10 * time.Second}
+	if tlsConfig != nil {
+		httpClient.Transport = &http.Transport{TLSClientConfig: tlsConfig}
+	}
+	return &serverBackend{endpoint: strings.TrimRight(endpoint, "/"), token: token, httpClient: httpClient}
+}
+
+func (self *serverBackend) GetIds(group string, count int) ([]uint64, error) {
+	var response frontend.IdsResponse
+	_, err := self.do("POST", groupPath(group, "ids"), frontend.IdsRequest{Count: count}, &response)
+	if err != nil {
+		return nil, err
+	}
+	return response.Ids, nil
+}
+
+func (self *serverBackend) ListGroups() ([]base.GroupInfo, error) {
+	var response frontend.GroupListResponse
+	if _, err := self.do("GET", "/v1/groups", nil, &response); err != nil {
+		return nil, err
+	}
+	groupInfos := make([]base.GroupInfo, 0, len(response.Groups))
+	for _, group := range response.Groups {
+		groupInfos = append(groupInfos, toGroupInfo(group))
+	}
+	return groupInfos, nil
+}
+
+func (self *serverBackend) GetGroup(group string) (*base.GroupInfo, error) {
+	var response frontend.GroupResponse
+	status, err := self.do("GET", groupPath(group, ""), nil, &response)
+	if status == http.StatusNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	groupInfo := toGroupInfo(response)
+	return &groupInfo, nil
+}
+
+func (self *serverBackend) ForwardGroup(group string, next uint64) (bool, error) {
+	status, err := self.do("POST", groupPath(group, "forward"), frontend.ForwardRequest{Next: next}, nil)
+	if status == http.StatusNotFound {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (self *serverBackend) ClearGroup(group string) (bool, error) {
+	var response frontend.ClearResponse
+	if _, err := self.do("DELETE", groupPath(group, ""), nil, &response); err != nil {
+		return false, err
+	}
+	return response.Cleared, nil
+}
+
+// ImportGroup creates the group, and then restores its step, bound and range. The offset
+// and the increment of group are the ones configured for the server.
+func (self *serverBackend) ImportGroup(groupInfo base.GroupInfo) (bool, error) {
+	createRequest := frontend.CreateGroupRequest{Name: groupInfo.Name, Start: groupInfo.Start, Step: groupInfo.Step}
+	status, err := self.do("POST", "/v1/groups", createRequest, nil)
+	if status == http.StatusConflict {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	updateRequest := frontend.UpdateGroupRequest{Step: &groupInfo.Step, Bound: &groupInfo.Bound}
+	if _, err := self.do("PATCH", groupPath(groupInfo.Name, ""), updateRequest, nil); err != nil {
+		return true, err
+	}
+	if groupInfo.Count > 0 {
+		if _, err := self.ForwardGroup(groupInfo.Name, groupInfo.Range.End); err != nil {
+			return true, err
+		}
+	}
+	return true, nil
+}
+
+// do sends the request in json, and decodes the response into result. The status is 0 if no response is received.
+func (self *serverBackend) do(method string, path string, request interface{}, result interface{}) (int, error) {
+	var body io.Reader
+	if request != nil {
+		content, err := json.Marshal(request)
+		if err != nil {
+			return 0, err
+		}
+		body = bytes.NewReader(content)
+	}
+	httpRequest, err := http.NewRequest(method, self.endpoint+path, body)
+	if err != nil {
+		return 0, err
+	}
+	httpRequest.Header.Set("Content-Type", "application/json")
+	httpRequest.Header.Set("Accept", "application/json")
+	if len(self.token) > 0 {
+		httpRequest.Header.Set("Authorization", "Bearer "+self.token)
+	}
+	httpResponse, err := self.httpClient.Do(httpRequest)
+	if err != nil {
+		return 0, err
+	}
+	defer httpResponse.Body.Close()
+	content, err := io.ReadAll(httpResponse.Body)
+	if err != nil {
+		return httpResponse.StatusCode, err
+	}
+	if httpResponse.StatusCode/100 != 2 {
+		var httpErr frontend.HttpError
+		if json.Unmarshal(content, &httpErr) != nil || len(httpErr.Message) == 0 {
+			httpErr.Message = string(content)
+		}
+		return httpResponse.StatusCode, fmt.Errorf("%s %s: %d %s", method, path, httpResponse.StatusCode, httpErr.Message)
+	}
+	if result != nil {
+		if err := json.Unmarshal(content, result); err != nil {
+			return httpResponse.StatusCode, fmt.Errorf("The response of %s %s is INVALID! (%s)", method, path, err)
+		}
+	}
+	return httpResponse.StatusCode, nil
+}
+
+func groupPath(group string, action string) string {
+	path := "/v1/groups/" + url.PathEscape(group)
+	if len(action) > 0 {
+		path += "/" + action
+	}
+	return path
+}
+
+func toGroupInfo(group frontend.GroupResponse) base.GroupInfo {
+	return base.GroupInfo{
+		Name:         group.Name,
+		Start:        group.Start,
+		Step:         group.Step,
+		Offset:       group.Offset,
+		Increment:    group.Increment,
+		Bound:        group.Bound,
+		Count:        group.Count,
+		Range:        base.IdRange{Begin: group.Begin, End: group.End, Increment: group.Increment},
+		LastModified: group.LastModified,
+	}
+}
+
+// The storage backend works on the storage provider directly, without the server.
+// The ids are reserved from the storage aligned with offset and increment, so they are
+// never cached nor issued by the servers. Getting ids is refused for the groups which are
+// obfuscated or carry a check digit (and their periodic groups), because the reserved ids
+// are the raw ones, which may collide with the encoded ones. Forwarding is safe without the
+// server, because the ids cached are always below the end of the stored range. Clearing
+// also clears the cache provider, without which it is refused, because the ids cached
+// would be issued again after the group is rebuilt.
+type storageBackend struct {
+	storageProvider base.StorageProvider
+	cacheProvider   base.CacheProvider
+	offset          uint64
+	increment       uint32
+	// The groups which are obfuscated or carry a check digit.
+	encodedGroups map[string]bool
+}
+
+func (self *storageBackend) GetIds(group string, count int) ([]uint64, error) {
+	if self.isEncoded(group) {
+		return nil, fmt.Errorf("The ids of group '%s' are obfuscated or carry a check digit, so getting them needs a running server (-server)!", group)
+	}
+	groupInfo, err := self.storageProvider.Get(group)
+	if err != nil {
+		return nil, err
+	}
+	if groupInfo == nil {
+		return nil, fmt.Errorf("The group '%s' is not found!", group)
+	}
+	idRange, err := self.storageProvider.Reserve(group, uint64(count), self.offset, self.increment)
+	if err != nil {
+		return nil, err
+	}
+	if idRange == nil {
+		return nil, fmt.Errorf("No id of group '%s' is available now!", group)
+	}
+	return idRange.Ids(), nil
+}
+
+// isEncoded returns true if the group, or the group of which it is a periodic one, is encoded.
+func (self *storageBackend) isEncoded(group string) bool {
+	if self.encodedGroups[group] {
+		return true
+	}
+	index := strings.LastIndex(group, manager.PERIOD_SEPARATOR)
+	return index > 0 && self.encodedGroups[group[:index]]
+}
+
+func (self *storageBackend) ListGroups() ([]base.GroupInfo, error) {
+	return self.storageProvider.List()
+}
+
+func (self *storageBackend) GetGroup(group string) (*base.GroupInfo, error) {
+	return self.storageProvider.Get(group)
+}
+
+func (self *storageBackend) ForwardGroup(group string, next uint64) (bool, error) {
+	return self.storageProvider.Forward(group, next)
+}
+
+func (self *storageBackend) ClearGroup(group string) (bool, error) {
+	if self.cacheProvider == nil {
+		return false, errors.New("The clearing needs the cache provider configured, otherwise the ids cached would be issued again!")
+	}
+	spResult, err := self.storageProvider.Clear(group)
+	if err != nil {
+		return false, err
+	}
+	cpResult, err := self.cacheProvider.Clear(group)
+	if err != nil {
+		return false, err
+	}
+	return spResult && cpResult, nil
+}
+
+func (self *storageBackend) ImportGroup(groupInfo base.GroupInfo) (bool, error) {
+	ok, err := self.storageProvider.BuildInfo(groupInfo.Name, groupInfo.Start, groupInfo.Step, groupInfo.Offset, groupInfo.Increment)
+	if err != nil || !ok {
+		return false, err
+	}
+	if _, err := self.storageProvider.Update(groupInfo.Name, groupInfo.Step, groupInfo.Bound); err != nil {
+		return true, err
+	}
+	if groupInfo.Count > 0 {
+		if _, err := self.storageProvider.Forward(groupInfo.Name, groupInfo.Range.End); err != nil {
+			return true, err
+		}
+	}
+	return true, nil
+}
@@ -0,0 +1,395 @@
+// The idctl is the command-line admin tool of the id center.
+//
+// It works against a running server by the admin api (-server), or directly
+// against the storage provider configured in the config file (-config). Against
+// the storage, get is refused for the groups which are obfuscated or carry a check
+// digit, and clear needs the Redis cache provider configured as well:
+//
+//	idctl [-server <url> [-token <token>] [-ca <file>] [-cert <file> -key <file>] | -config <path>] [-yes] <command> [arguments]
+//
+// The token of an admin client is needed if the authentication of server is enabled.
+// It can also be given by the environment variable IDCTL_TOKEN. For the server in TLS
+// (https), the CA of the server certificate is given by -ca, and the client certificate
+// by -cert and -key.
+//
+// The commands:
+//
+//	get <group> [count]        get ids of group
+//	list                       list groups
+//	describe <group>           describe group
+//	forward <group> <next>     reset group forward, so that the later ids are not less than next
+//	clear <group>              clear group, after the confirmation
+//	export [file]              export groups in json, to stdout by default
+//	import [file]              import the groups exported, from stdin by default
+package main
+
+import (
+	"bufio"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"go_idcenter/base"
+	"go_idcenter/frontend"
+	"go_idcenter/manager"
+	"go_idcenter/provider"
+	"go_lib"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+const (
+	TIME_FORMAT = "2006-01-02 15:04:05.000"
+)
+
+func main() {
+	err := run(os.Args[1:], os.Stdin, os.Stdout)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "idctl: %s\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(args []string, stdin io.Reader, stdout io.Writer) error {
+	flagSet := flag.NewFlagSet("idctl", flag.ContinueOnError)
+	flagSet.SetOutput(stdout)
+	server := flagSet.String("server", "", "the url of running server, e.g. http://127.0.0.1:9092")
+	configPath := flagSet.String("config", "", "the config file whose storage provider is used directly, e.g. "+base.CONFIG_FILE_NAME)
+	token := flagSet.String("token", os.Getenv("IDCTL_TOKEN"), "the bearer token of admin client, if the authentication of server is enabled")
+	caFile := flagSet.String("ca", "", "the CA certificates of server in PEM, the system ones by default")
+	certFile := flagSet.String("cert", "", "the client certificate in PEM, if the server verifies client certificates")
+	keyFile := flagSet.String("key", "", "the key of client certificate in PEM")
+	yes := flagSet.Bool("yes", false, "skip the confirmation of clearing")
+	flagSet.Usage = func() {
+		fmt.Fprintln(stdout, "Usage: idctl [-server <url> [-token <token>] [-ca <file>] [-cert <file> -key <file>] | -config <path>] [-yes] <command> [arguments]")
+		fmt.Fprintln(stdout, "Commands: get <group> [count], list, describe <group>, forward <group> <next>, clear <group>, export [file], import [file]")
+		flagSet.PrintDefaults()
+	}
+	if err := flagSet.Parse(args); err != nil {
+		return err
+	}
+	if flagSet.NArg() == 0 {
+		flagSet.Usage()
+		return errors.New("The command is missing!")
+	}
+	var idBackend backend
+	switch {
+	case len(*server) > 0 && len(*configPath) > 0:
+		return errors.New("Only one of -server and -config can be given!")
+	case len(*server) > 0:
+		tlsConfig, err := newTlsConfig(*caFile, *certFile, *keyFile)
+		if err != nil {
+			return err
+		}
+		idBackend = newServerBackend(*server, *token, tlsConfig)
+	case len(*configPath) > 0:
+		storageBackend, err := newStorageBackend(*configPath)
+		if err != nil {
+			return err
+		}
+		idBackend = storageBackend
+	default:
+		return errors.New("Either -server or -config must be given!")
+	}
+	command, commandArgs := flagSet.Arg(0), flagSet.Args()[1:]
+	switch command {
+	case "get":
+		return getIds(idBackend, commandArgs, stdout)
+	case "list":
+		return listGroups(idBackend, stdout)
+	case "describe":
+		return describeGroup(idBackend, commandArgs, stdout)
+	case "forward":
+		return forwardGroup(idBackend, commandArgs, stdout)
+	case "clear":
+		return clearGroup(idBackend, commandArgs, *yes, stdin, stdout)
+	case "export":
+		return exportGroups(idBackend, commandArgs, stdout)
+	case "import":
+		return importGroups(idBackend, commandArgs, stdin, stdout)
+	}
+	return fmt.Errorf("Unknown command '%s'!", command)
+}
+
+// newTlsConfig creates the client config of TLS, which is nil if neither the CA nor the certificate is given.
+func newTlsConfig(caFile string, certFile string, keyFile string) (*tls.Config, error) {
+	if len(caFile) == 0 && len(certFile) == 0 && len(keyFile) == 0 {
+		return nil, nil
+	}
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+	if len(caFile) > 0 {
+		content, err := os.ReadFile(caFile)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.RootCAs = x509.NewCertPool()
+		if !tlsConfig.RootCAs.AppendCertsFromPEM(content) {
+			return nil, fmt.Errorf("No certificate is found in the CA file '%s'!", caFile)
+		}
+	}
+	if len(certFile) > 0 || len(keyFile) > 0 {
+		certificate, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("The client certificate '%s' or key '%s' is INVALID! (%s)", certFile, keyFile, err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{certificate}
+	}
+	return tlsConfig, nil
+}
+
+// newStorageBackend creates the storage backend by the config file, i.e. the MySQL storage provider,
+// the Redis cache provider if it is configured, the alignment of ids and the encoded groups.
+func newStorageBackend(configPath string) (*storageBackend, error) {
+	config := go_lib.Config{Path: configPath}
+	if err := config.ReadConfig(false); err != nil {
+		return nil, fmt.Errorf("Config Loading error: %s", err)
+	}
+	encodedGroups, err := loadEncodedGroups(config.Dict)
+	if err != nil {
+		return nil, fmt.Errorf("The group config is INVALID! Error: %s", err)
+	}
+	storageProvider, err := newStorageProvider(config.Dict)
+	if err != nil {
+		return nil, err
+	}
+	idBackend := &storageBackend{
+		storageProvider: storageProvider,
+		offset:          manager.DEFAULT_OFFSET,
+		increment:       manager.DEFAULT_INCREMENT,
+		encodedGroups:   encodedGroups,
+	}
+	if len(config.Dict["redis_server_port"]) > 0 {
+		redisPort, err := strconv.Atoi(config.Dict["redis_server_port"])
+		if err != nil {
+			return nil, fmt.Errorf("The redis server port '%v' is INVALID! Error: %s", config.Dict["redis_server_port"], err)
+		}
+		parameter := provider.RedisParameter{
+			Name:     "Redis Cache Provider",
+			Ip:       config.Dict["redis_server_ip"],
+			Port:     redisPort,
+			Password: config.Dict["redis_server_password"],
+			PoolSize: 1,
+		}
+		idBackend.cacheProvider = manager.NewRedisCacheProvider(parameter)
+	}
+	if offset, err := strconv.ParseUint(config.Dict["id_offset"], 10, 64); err == nil && offset > 0 {
+		idBackend.offset = offset
+	}
+	if increment, err := strconv.ParseUint(config.Dict["id_increment"], 10, 32); err == nil && increment > 0 {
+		idBackend.increment = uint32(increment)
+	}
+	return idBackend, nil
+}
+
+// loadEncodedGroups returns the groups which are configured to be obfuscated or carry a check digit.
+func loadEncodedGroups(dict map[string]string) (map[string]bool, error) {
+	groupConfigs, err := manager.LoadGroupConfigs(dict)
+	if err != nil {
+		return nil, err
+	}
+	encodedGroups := make(map[string]bool)
+	for group, groupConfig := range groupConfigs {
+		if groupConfig.Obfuscator != nil || groupConfig.CheckDigitScheme != nil {
+			encodedGroups[group] = true
+		}
+	}
+	return encodedGroups, nil
+}
+
+// newStorageProvider creates the MySQL storage provider by the config.
+func newStorageProvider(dict map[string]string) (base.StorageProvider, error) {
+	port, err := strconv.Atoi(dict["mysql_server_port"])
+	if err != nil {
+		return nil, fmt.Errorf("The mysql server port '%v' is INVALID! Error: %s", dict["mysql_server_port"], err)
+	}
+	poolSize, err := strconv.Atoi(dict["mysql_server_pool_size"])
+	if err != nil {
+		poolSize = 1
+	}
+	parameter := provider.MysqlParameter{
+		Name:     "Mysql Storage Provider",
+		Ip:       dict["mysql_server_ip"],
+		Port:     port,
+		DbName:   dict["mysql_server_db_name"],
+		User:     dict["mysql_server_user"],
+		Password: dict["mysql_server_password"],
+		PoolSize: uint16(poolSize),
+	}
+	return manager.NewMysqlStorageProvider(parameter)
+}
+
+func getIds(idBackend backend, args []string, stdout io.Writer) error {
+	if len(args) == 0 || len(args) > 2 {
+		return errors.New("Usage: get <group> [count]")
+	}
+	count := 1
+	if len(args) == 2 {
+		var err error
+		count, err = strconv.Atoi(args[1])
+		if err != nil || count <= 0 || count > manager.MAX_ID_COUNT {
+			return fmt.Errorf("The count must be in [1, %d]!", manager.MAX_ID_COUNT)
+		}
+	}
+	ids, err := idBackend.GetIds(args[0], count)
+	if err != nil {
+		return err
+	}
+	for _, id := range ids {
+		fmt.Fprintln(stdout, id)
+	}
+	return nil
+}
+
+func listGroups(idBackend backend, stdout io.Writer) error {
+	groupInfos, err := idBackend.ListGroups()
+	if err != nil {
+		return err
+	}
+	writer := tabwriter.NewWriter(stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(writer, "NAME\tSTART\tSTEP\tCOUNT\tBEGIN\tEND\tBOUND\tLAST MODIFIED")
+	for _, groupInfo := range groupInfos {
+		fmt.Fprintf(writer, "%s\t%d\t%d\t%d\t%d\t%d\t%d\t%s\n", groupInfo.Name, groupInfo.Start, groupInfo.Step, groupInfo.Count,
+			groupInfo.Range.Begin, groupInfo.Range.End, groupInfo.Bound, groupInfo.LastModified.Format(TIME_FORMAT))
+	}
+	return writer.Flush()
+}
+
+func describeGroup(idBackend backend, args []string, stdout io.Writer) error {
+	if len(args) != 1 {
+		return errors.New("Usage: describe <group>")
+	}
+	groupInfo, err := idBackend.GetGroup(args[0])
+	if err != nil {
+		return err
+	}
+	if groupInfo == nil {
+		return fmt.Errorf("The group '%s' is not found!", args[0])
+	}
+	writer := tabwriter.NewWriter(stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(writer, "Name:\t%s\n", groupInfo.Name)
+	fmt.Fprintf(writer, "Start:\t%d\n", groupInfo.Start)
+	fmt.Fprintf(writer, "Step:\t%d\n", groupInfo.Step)
+	fmt.Fprintf(writer, "Offset:\t%d\n", groupInfo.Offset)
+	fmt.Fprintf(writer, "Increment:\t%d\n", groupInfo.Increment)
+	fmt.Fprintf(writer, "Bound:\t%d\n", groupInfo.Bound)
+	fmt.Fprintf(writer, "Count:\t%d\n", groupInfo.Count)
+	fmt.Fprintf(writer, "Range:\t[%d, %d)\n", groupInfo.Range.Begin, groupInfo.Range.End)
+	fmt.Fprintf(writer, "Last Modified:\t%s\n", groupInfo.LastModified.Format(TIME_FORMAT))
+	return writer.Flush()
+}
+
+func forwardGroup(idBackend backend, args []string, stdout io.Writer) error {
+	if len(args) != 2 {
+		return errors.New("Usage: forward <group> <next>")
+	}
+	next, err := strconv.ParseUint(args[1], 10, 64)
+	if err != nil || next == 0 {
+		return fmt.Errorf("The next id '%s' is INVALID!", args[1])
+	}
+	ok, err := idBackend.ForwardGroup(args[0], next)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("The group '%s' is not found!", args[0])
+	}
+	fmt.Fprintf(stdout, "The group '%s' is forwarded to %d.\n", args[0], next)
+	return nil
+}
+
+func clearGroup(idBackend backend, args []string, yes bool, stdin io.Reader, stdout io.Writer) error {
+	if len(args) != 1 {
+		return errors.New("Usage: clear <group>")
+	}
+	group := args[0]
+	if !yes {
+		fmt.Fprintf(stdout, "All the state of group '%s' will be lost, and its ids may be issued again.\n", group)
+		fmt.Fprint(stdout, "Type the group name to confirm: ")
+		answer, _ := bufio.NewReader(stdin).ReadString('\n')
+		if strings.TrimSpace(answer) != group {
+			return errors.New("The clearing is cancelled.")
+		}
+	}
+	result, err := idBackend.ClearGroup(group)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(stdout, "The group '%s' is cleared. (result=%v)\n", group, result)
+	return nil
+}
+
+// exportGroups writes the groups in the json format of admin api.
+func exportGroups(idBackend backend, args []string, stdout io.Writer) error {
+	if len(args) > 1 {
+		return errors.New("Usage: export [file]")
+	}
+	groupInfos, err := idBackend.ListGroups()
+	if err != nil {
+		return err
+	}
+	groups := make([]frontend.GroupResponse, 0, len(groupInfos))
+	for _, groupInfo := range groupInfos {
+		groups = append(groups, frontend.GroupResponse{
+			Name:         groupInfo.Name,
+			Start:        groupInfo.Start,
+			Step:         groupInfo.Step,
+			Offset:       groupInfo.Offset,
+			Increment:    groupInfo.Increment,
+			Bound:        groupInfo.Bound,
+			Count:        groupInfo.Count,
+			Begin:        groupInfo.Range.Begin,
+			End:          groupInfo.Range.End,
+			LastModified: groupInfo.LastModified,
+		})
+	}
+	content, err := json.MarshalIndent(frontend.GroupListResponse{Groups: groups}, "", "  ")
+	if err != nil {
+		return err
+	}
+	content = append(content, '\n')
+	if len(args) == 1 {
+		return os.WriteFile(args[0], content, 0644)
+	}
+	_, err = stdout.Write(content)
+	return err
+}
+
+// importGroups creates the groups exported. The existing groups are skipped.
+func importGroups(idBackend backend, args []string, stdin io.Reader, stdout io.Writer) error {
+	if len(args) > 1 {
+		return errors.New("Usage: import [file]")
+	}
+	reader := stdin
+	if len(args) == 1 {
+		file, err := os.Open(args[0])
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		reader = file
+	}
+	var groupList frontend.GroupListResponse
+	if err := json.NewDecoder(reader).Decode(&groupList); err != nil {
+		return fmt.Errorf("The exported groups are INVALID! (%s)", err)
+	}
+	imported, skipped := 0, 0
+	for _, group := range groupList.Groups {
+		ok, err := idBackend.ImportGroup(toGroupInfo(group))
+		if err != nil {
+			return fmt.Errorf("Import group '%s' error: %s", group.Name, err)
+		}
+		if !ok {
+			fmt.Fprintf(stdout, "The group '%s' already exists. SKIP.\n", group.Name)
+			skipped++
+			continue
+		}
+		imported++
+	}
+	fmt.Fprintf(stdout, "%d groups are imported, %d are skipped.\n", imported, skipped)
+	return nil
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"bytes"
+	"go_idcenter/frontend"
+	"go_idcenter/manager"
+	"go_idcenter/provider/providertest"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestIdctlAgainstServer(t *testing.T) {
+	cp := providertest.NewMemoryCacheProvider("Memory Cache Provider (" + t.Name() + ")")
+	sp := providertest.NewMemoryStorageProvider("Memory Storage Provider (" + t.Name() + ")")
+	manager.RegisterProvider(cp)
+	manager.RegisterProvider(sp)
+	defer manager.UnregisterProvider(cp)
+	defer manager.UnregisterProvider(sp)
+	idCenterManager := &manager.IdCenterManager{
+		CacheProviderName:   cp.Name(),
+		StorageProviderName: sp.Name(),
+		Start:               1,
+		Step:                10,
+	}
+	server := httptest.NewServer(frontend.NewHttpFrontend(idCenterManager))
+	defer server.Close()
+
+	runIdctl := func(stdin string, args ...string) (string, error) {
+		var stdout bytes.Buffer
+		err := run(append([]string{"-server", server.URL}, args...), strings.NewReader(stdin), &stdout)
+		return stdout.String(), err
+	}
+	output, err := runIdctl("", "get", "idctl_test", "3")
+	if err != nil || output != "1\n2\n3\n" {
+		t.Errorf("Unexpected output of get: %q (%v)", output, err)
+		t.FailNow()
+	}
+	if _, err := runIdctl("", "forward", "idctl_test", "100"); err != nil {
+		t.Errorf("Forward error: %s", err)
+		t.FailNow()
+	}
+	output, err = runIdctl("", "describe", "idctl_test")
+	if err != nil || !strings.Contains(output, "Range:") || !strings.Contains(output, "100)") {
+		t.Errorf("Unexpected output of describe: %q (%v)", output, err)
+		t.FailNow()
+	}
+	exported, err := runIdctl("", "export")
+	if err != nil || !strings.Contains(exported, `"name": "idctl_test"`) {
+		t.Errorf("Unexpected output of export: %q (%v)", exported, err)
+		t.FailNow()
+	}
+	if _, err := runIdctl("wrong\n", "clear", "idctl_test"); err == nil {
+		t.Errorf("The clearing should not be done without the confirmation.")
+		t.FailNow()
+	}
+	if _, err := runIdctl("idctl_test\n", "clear", "idctl_test"); err != nil {
+		t.Errorf("Clear error: %s", err)
+		t.FailNow()
+	}
+	if _, err := runIdctl("", "describe", "idctl_test"); err == nil {
+		t.Errorf("The group should be cleared.")
+		t.FailNow()
+	}
+	output, err = runIdctl(exported, "import")
+	if err != nil || !strings.Contains(output, "1 groups are imported") {
+		t.Errorf("Unexpected output of import: %q (%v)", output, err)
+		t.FailNow()
+	}
+	output, err = runIdctl("", "get", "idctl_test")
+	if err != nil || output != "100\n" {
+		t.Errorf("The imported group should continue from 100, but: %q (%v)", output, err)
+		t.FailNow()
+	}
+	output, err = runIdctl("", "list")
+	if err != nil || !strings.Contains(output, "idctl_test") {
+		t.Errorf("Unexpected output of list: %q (%v)", output, err)
+		t.FailNow()
+	}
+}
+
+func TestIdctlAgainstStorage(t *testing.T) {
+	sp := providertest.NewMemoryStorageProvider("Memory Storage Provider (" + t.Name() + ")")
+	cp := providertest.NewMemoryCacheProvider("Memory Cache Provider (" + t.Name() + ")")
+	sp.BuildInfo("idctl_test", 1, 10, 1, 1)
+	sp.BuildInfo("idctl_obfuscated_test", 1, 10, 1, 1)
+	sp.BuildInfo("idctl_check_digit_test@20261019", 1, 10, 1, 1)
+	dict := map[string]string{
+		"group.idctl_obfuscated_test.obfuscation_key": "idctl_test_key",
+		"group.idctl_check_digit_test.check_digit":    "luhn",
+		"group.idctl_check_digit_test.period":         "daily",
+	}
+	encodedGroups, err := loadEncodedGroups(dict)
+	if err != nil || len(encodedGroups) != 2 {
+		t.Errorf("Unexpected encoded groups: %v (%v)", encodedGroups, err)
+		t.FailNow()
+	}
+	idBackend := &storageBackend{storageProvider: sp, offset: 1, increment: 1, encodedGroups: encodedGroups}
+	var stdout bytes.Buffer
+	if err := describeGroup(idBackend, []string{"idctl_test"}, &stdout); err != nil {
+		t.Errorf("Describe error: %s", err)
+		t.FailNow()
+	}
+	stdout.Reset()
+	if err := getIds(idBackend, []string{"idctl_test", "3"}, &stdout); err != nil || stdout.String() != "1\n2\n3\n" {
+		t.Errorf("Unexpected output of get: %q (%v)", stdout.String(), err)
+		t.FailNow()
+	}
+	// The raw ids of the encoded groups may collide with the encoded ones.
+	for _, group := range []string{"idctl_obfuscated_test", "idctl_check_digit_test@20261019"} {
+		if err := getIds(idBackend, []string{group}, &stdout); err == nil {
+			t.Errorf("Getting ids of the encoded group '%s' from the storage should be refused.", group)
+			t.FailNow()
+		}
+	}
+	if err := forwardGroup(idBackend, []string{"idctl_test", "100"}, &stdout); err != nil {
+		t.Errorf("Forward error: %s", err)
+		t.FailNow()
+	}
+	stdout.Reset()
+	if err := getIds(idBackend, []string{"idctl_test"}, &stdout); err != nil || stdout.String() != "100\n" {
+		t.Errorf("The forwarded group should continue from 100, but: %q (%v)", stdout.String(), err)
+		t.FailNow()
+	}
+	// The clearing needs the cache provider.
+	if err := clearGroup(idBackend, []string{"idctl_test"}, true, strings.NewReader(""), &stdout); err == nil {
+		t.Errorf("Clearing without the cache provider should be refused.")
+		t.FailNow()
+	}
+	idBackend.cacheProvider = cp
+	if err := clearGroup(idBackend, []string{"idctl_test"}, true, strings.NewReader(""), &stdout); err != nil {
+		t.Errorf("Clear error: %s", err)
+		t.FailNow()
+	}
+	groupInfo, err := sp.Get("idctl_test")
+	if err != nil || groupInfo != nil {
+		t.Errorf("The group in the storage should be cleared: %v (%v)", groupInfo, err)
+		t.FailNow()
+	}
+}
@@ -0,0 +1,66 @@
+package frontend
+
+import (
+	"context"
+	"crypto/tls"
+	"go_idcenter/auth"
+	"go_idcenter/base"
+	"go_idcenter/ratelimit"
+	"math"
+	"net"
+)
+
+// authorizeConn returns nil if the client of connection has the permission on group, or
+// auth.ErrUnauthenticated or auth.ErrForbidden. The client is identified by the token given
+// on the connection, or the client certificate if the connection is in TLS. The denial is audited.
+func authorizeConn(ctx context.Context, authenticator *auth.Authenticator, conn net.Conn, token string, permission string, group string) error {
+	if authenticator == nil {
+		return nil
+	}
+	client, err := authenticator.Authorize(token, connectionState(conn), permission, group)
+	if err != nil {
+		auth.Audit(ctx, client, conn.RemoteAddr().String(), permission, group, err)
+	}
+	return err
+}
+
+// limitConn takes the tokens of count ids of the client of connection and group from the rate
+// limiter. If it is rejected, the result is the seconds after which it may be allowed, or 0.
+func limitConn(ctx context.Context, rateLimiter *ratelimit.Limiter, authenticator *auth.Authenticator, conn net.Conn, token string, group string, count uint64) int {
+	if rateLimiter == nil {
+		return 0
+	}
+	client := connClientIdentity(authenticator, conn, token)
+	ok, wait := rateLimiter.AllowN(client, group, count)
+	if ok {
+		return 0
+	}
+	retryAfter := int(math.Max(1, math.Ceil(wait.Seconds())))
+	base.Log(base.SUBSYSTEM_FRONTEND).WarnContext(ctx, "The request is rate limited.", "client", client, base.LOG_FIELD_GROUP, group, "retry_after", retryAfter)
+	return retryAfter
+}
+
+// connClientIdentity returns the name of the authenticated client of connection, or the remote
+// host if the authentication is disabled.
+func connClientIdentity(authenticator *auth.Authenticator, conn net.Conn, token string) string {
+	if authenticator != nil {
+		if client := authenticator.Identify(token, connectionState(conn)); client != nil {
+			return client.Name
+		}
+	}
+	host, _, err := net.SplitHostPort(conn.RemoteAddr().String())
+	if err != nil {
+		return conn.RemoteAddr().String()
+	}
+	return host
+}
+
+// connectionState returns the TLS state of conn, or nil for the plain connection.
+func connectionState(conn net.Conn) *tls.ConnectionState {
+	tlsConn, ok := conn.(*tls.Conn)
+	if !ok {
+		return nil
+	}
+	state := tlsConn.ConnectionState()
+	return &state
+}
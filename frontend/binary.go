@@ -0,0 +1,295 @@
+package frontend
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"go_idcenter/auth"
+	"go_idcenter/base"
+	"go_idcenter/manager"
+	"go_idcenter/ratelimit"
+	"go_idcenter/wire"
+	"io"
+	"net"
+	"sync"
+	"time"
+)
+
+const (
+	DEFAULT_BINARY_WORKERS = 4
+)
+
+// The binary frontend serves the compact binary protocol of package wire.
+//
+// Every connection is served by a fixed number of goroutines: one reader, Workers
+// workers and one writer. The pipelined requests are executed by the workers
+// concurrently, so the responses may be out of order, and the client matches
+// them by the request id.
+//
+// If the authentication is enabled, the requests need the token given by OP_AUTH
+// on the connection, or the known client certificate of the TLS connection.
+type BinaryFrontend struct {
+	Workers int
+	// The authenticator of clients. The authentication is disabled if it is nil.
+	Authenticator *auth.Authenticator
+	// The rate limiter of the allocations by clients and groups. No limit if it is nil.
+	RateLimiter *ratelimit.Limiter
+	manager     *manager.IdCenterManager
+	sign        sync.Mutex
+	listener    net.Listener
+	conns       map[net.Conn]bool
+	connWait    sync.WaitGroup
+	closed      bool
+	// The context of requests, which is cancelled by Close, so that the requests in flight give up.
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+func NewBinaryFrontend(idCenterManager *manager.IdCenterManager) *BinaryFrontend {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &BinaryFrontend{Workers: DEFAULT_BINARY_WORKERS, manager: idCenterManager, conns: make(map[net.Conn]bool), ctx: ctx, cancel: cancel}
+}
+
+// Serve accepts the connections of listener until Close is called.
+func (self *BinaryFrontend) Serve(listener net.Listener) error {
+	self.sign.Lock()
+	self.listener = listener
+	self.sign.Unlock()
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			self.sign.Lock()
+			closed := self.closed
+			self.sign.Unlock()
+			if closed {
+				return nil
+			}
+			return err
+		}
+		self.sign.Lock()
+		if self.closed {
+			self.sign.Unlock()
+			conn.Close()
+			return nil
+		}
+		self.conns[conn] = true
+		self.connWait.Add(1)
+		self.sign.Unlock()
+		go self.serveConn(conn)
+	}
+}
+
+// Shutdown stops accepting connections and reading requests, and waits for the requests
+// read to be replied until ctx is done. The connections are closed at last.
+func (self *BinaryFrontend) Shutdown(ctx context.Context) error {
+	self.sign.Lock()
+	self.closed = true
+	if self.listener != nil {
+		self.listener.Close()
+	}
+	for conn := range self.conns {
+		conn.SetReadDeadline(time.Now())
+	}
+	self.sign.Unlock()
+	done := make(chan struct{})
+	go func() {
+		self.connWait.Wait()
+		close(done)
+	}()
+	var err error
+	select {
+	case <-done:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+	self.Close()
+	return err
+}
+
+func (self *BinaryFrontend) Close() error {
+	self.sign.Lock()
+	defer self.sign.Unlock()
+	self.closed = true
+	self.cancel()
+	for conn := range self.conns {
+		conn.Close()
+	}
+	if self.listener == nil {
+		return nil
+	}
+	return self.listener.Close()
+}
+
+func (self *BinaryFrontend) serveConn(conn net.Conn) {
+	defer func() {
+		defer self.connWait.Done()
+		self.sign.Lock()
+		delete(self.conns, conn)
+		self.sign.Unlock()
+		conn.Close()
+	}()
+	workers := self.Workers
+	if workers <= 0 {
+		workers = DEFAULT_BINARY_WORKERS
+	}
+	requests := make(chan *binaryRequest, workers)
+	responses := make(chan *wire.Frame, workers)
+	var workerGroup sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		workerGroup.Add(1)
+		go func() {
+			defer workerGroup.Done()
+			for request := range requests {
+				responses <- self.execute(conn, request)
+			}
+		}()
+	}
+	writerDone := make(chan struct{})
+	go func() {
+		defer close(writerDone)
+		writer := bufio.NewWriter(conn)
+		broken := false
+		for response := range responses {
+			if broken {
+				continue
+			}
+			err := wire.WriteFrame(writer, response)
+			// Flush only when no more response is ready, so that the pipelined ones are written together.
+			if err == nil && len(responses) == 0 {
+				err = writer.Flush()
+			}
+			if err != nil {
+				base.Logger().Warnf("Writing binary response error (remote=%s): %s\n", conn.RemoteAddr(), err)
+				broken = true
+				conn.Close()
+			}
+		}
+	}()
+	reader := bufio.NewReader(conn)
+	// The token of connection, which is replaced by OP_AUTH. It is executed by the reader,
+	// so that it applies to exactly the requests which follow it.
+	token := ""
+	for {
+		request, err := wire.ReadFrame(reader)
+		if err != nil {
+			if err != io.EOF && !self.isClosed() {
+				base.Logger().Warnf("Reading binary request error (remote=%s): %s\n", conn.RemoteAddr(), err)
+			}
+			break
+		}
+		if request.Op == wire.OP_AUTH {
+			var response *wire.Frame
+			token, response = self.authenticate(conn, request)
+			responses <- response
+			continue
+		}
+		requests <- &binaryRequest{frame: request, token: token}
+	}
+	close(requests)
+	workerGroup.Wait()
+	close(responses)
+	<-writerDone
+}
+
+func (self *BinaryFrontend) isClosed() bool {
+	self.sign.Lock()
+	defer self.sign.Unlock()
+	return self.closed
+}
+
+// The request of connection with the token given before it.
+type binaryRequest struct {
+	frame *wire.Frame
+	token string
+}
+
+// authenticate returns the token of request of OP_AUTH and the response. The token is
+// empty if it is unknown.
+func (self *BinaryFrontend) authenticate(conn net.Conn, request *wire.Frame) (string, *wire.Frame) {
+	token, err := wire.ParseAuthRequest(request)
+	if err != nil {
+		return "", wire.NewErrorResponse(request.RequestId, wire.ERROR_CODE_INVALID_REQUEST, err.Error())
+	}
+	if self.Authenticator != nil && self.Authenticator.Authenticate(token) == nil {
+		auth.Audit(self.ctx, nil, conn.RemoteAddr().String(), "authenticate", "", auth.ErrUnauthenticated)
+		return "", wire.NewErrorResponse(request.RequestId, wire.ERROR_CODE_UNAUTHENTICATED, auth.ErrUnauthenticated.Error())
+	}
+	return token, wire.NewAuthOkResponse(request.RequestId)
+}
+
+// authorize returns the error response if the connection has not the permission on group.
+func (self *BinaryFrontend) authorize(conn net.Conn, request *binaryRequest, permission string, group string) *wire.Frame {
+	err := authorizeConn(self.ctx, self.Authenticator, conn, request.token, permission, group)
+	if err == nil {
+		return nil
+	}
+	if err == auth.ErrUnauthenticated {
+		return wire.NewErrorResponse(request.frame.RequestId, wire.ERROR_CODE_UNAUTHENTICATED, err.Error())
+	}
+	return wire.NewErrorResponse(request.frame.RequestId, wire.ERROR_CODE_FORBIDDEN, fmt.Sprintf("The %s operation on group '%s' is not allowed!", permission, group))
+}
+
+func (self *BinaryFrontend) execute(conn net.Conn, binaryRequest *binaryRequest) *wire.Frame {
+	request := binaryRequest.frame
+	switch request.Op {
+	case wire.OP_PING:
+		return wire.NewPongResponse(request.RequestId)
+	case wire.OP_GET_ID, wire.OP_GET_IDS:
+		group, count, err := wire.ParseGroupRequest(request)
+		if err != nil {
+			return wire.NewErrorResponse(request.RequestId, wire.ERROR_CODE_INVALID_REQUEST, err.Error())
+		}
+		if len(group) == 0 {
+			return wire.NewErrorResponse(request.RequestId, wire.ERROR_CODE_INVALID_GROUP, "The group name is empty!")
+		}
+		if count <= 0 || count > wire.MAX_BATCH_SIZE || count > manager.MAX_ID_COUNT {
+			return wire.NewErrorResponse(request.RequestId, wire.ERROR_CODE_INVALID_REQUEST, "The count is out of range!")
+		}
+		if response := self.authorize(conn, binaryRequest, auth.PERMISSION_ALLOCATE, group); response != nil {
+			return response
+		}
+		if retryAfter := limitConn(self.ctx, self.RateLimiter, self.Authenticator, conn, binaryRequest.token, group, uint64(count)); retryAfter > 0 {
+			return wire.NewErrorResponse(request.RequestId, wire.ERROR_CODE_RATE_LIMITED, fmt.Sprintf("Too many requests! Retry after %d seconds.", retryAfter))
+		}
+		if request.Op == wire.OP_GET_ID {
+			id, err := self.manager.GetIdContext(self.ctx, group)
+			if err != nil {
+				return toWireError(request.RequestId, err, fmt.Sprintf("Get id error (group=%s)", group))
+			}
+			if id == 0 {
+				return wire.NewErrorResponse(request.RequestId, wire.ERROR_CODE_UNAVAILABLE, "No id is available now!")
+			}
+			return wire.NewIdResponse(request.RequestId, id)
+		}
+		ids, err := self.manager.GetIdsContext(self.ctx, group, count)
+		if err != nil {
+			return toWireError(request.RequestId, err, fmt.Sprintf("Get ids error (group=%s, count=%d)", group, count))
+		}
+		return wire.NewIdsResponse(request.RequestId, ids)
+	}
+	return wire.NewErrorResponse(request.RequestId, wire.ERROR_CODE_UNKNOWN_OP, "Unknown op!")
+}
+
+// toWireError converts the error of manager to the error response by its kind, like toHttpError.
+func toWireError(requestId uint32, err error, errorMsgPrefix string) *wire.Frame {
+	code := wire.ERROR_CODE_INTERNAL_ERROR
+	switch {
+	case errors.Is(err, base.ErrInvalidGroupName):
+		code = wire.ERROR_CODE_INVALID_GROUP
+	case errors.Is(err, base.ErrInvalidArgument):
+		code = wire.ERROR_CODE_INVALID_REQUEST
+	case errors.Is(err, base.ErrExhausted):
+		code = wire.ERROR_CODE_EXHAUSTED
+	case errors.Is(err, base.ErrTimeout):
+		code = wire.ERROR_CODE_TIMEOUT
+	case errors.Is(err, base.ErrStorageUnavailable), errors.Is(err, base.ErrCacheUnavailable), errors.Is(err, base.ErrShutDown), errors.Is(err, base.ErrCanceled):
+		code = wire.ERROR_CODE_UNAVAILABLE
+	}
+	if code == wire.ERROR_CODE_INVALID_GROUP || code == wire.ERROR_CODE_INVALID_REQUEST || code == wire.ERROR_CODE_EXHAUSTED {
+		base.Logger().Warnf("%s: %s\n", errorMsgPrefix, err)
+	} else {
+		base.Logger().Errorf("%s: %s\n", errorMsgPrefix, err)
+	}
+	return wire.NewErrorResponse(requestId, code, err.Error())
+}
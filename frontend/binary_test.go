@@ -0,0 +1,272 @@
+package frontend
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"go_idcenter/auth"
+	"go_idcenter/base"
+	"go_idcenter/wire"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func startBinaryFrontend(t testing.TB) (net.Conn, func()) {
+	idCenterManager, unregister := newTestManager(t, nil)
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Errorf("Listen error: %s", err)
+		t.FailNow()
+	}
+	frontend := NewBinaryFrontend(idCenterManager)
+	go frontend.Serve(listener)
+	conn, err := net.Dial("tcp", listener.Addr().String())
+	if err != nil {
+		t.Errorf("Dial error: %s", err)
+		t.FailNow()
+	}
+	return conn, func() {
+		conn.Close()
+		frontend.Close()
+		unregister()
+	}
+}
+
+func TestBinaryFrontend(t *testing.T) {
+	conn, stop := startBinaryFrontend(t)
+	defer stop()
+	// The pipelined requests.
+	requests := []*wire.Frame{
+		wire.NewGetIdRequest(1, "binary_test"),
+		wire.NewGetIdsRequest(2, "binary_test", 3),
+		wire.NewPingRequest(3),
+		wire.NewGetIdRequest(4, ""),
+		wire.NewGetIdsRequest(5, "binary_test", 0),
+		{Op: 0x7F, RequestId: 6},
+	}
+	writer := bufio.NewWriter(conn)
+	for _, request := range requests {
+		wire.WriteFrame(writer, request)
+	}
+	if err := writer.Flush(); err != nil {
+		t.Errorf("Write error: %s", err)
+		t.FailNow()
+	}
+	responses := make(map[uint32]*wire.Frame)
+	reader := bufio.NewReader(conn)
+	for range requests {
+		response, err := wire.ReadFrame(reader)
+		if err != nil {
+			t.Errorf("Read error: %s", err)
+			t.FailNow()
+		}
+		responses[response.RequestId] = response
+	}
+	seen := make(map[uint64]bool)
+	for _, requestId := range []uint32{1, 2} {
+		ids, err := wire.ParseIds(responses[requestId])
+		if err != nil {
+			t.Errorf("The response of request %d is an error: %s", requestId, err)
+			t.FailNow()
+		}
+		for _, id := range ids {
+			if seen[id] {
+				t.Errorf("The id %d is duplicated.", id)
+				t.FailNow()
+			}
+			seen[id] = true
+		}
+	}
+	if len(seen) != 4 {
+		t.Errorf("The count of ids %d is not equals 4.", len(seen))
+		t.FailNow()
+	}
+	if responses[3] == nil || responses[3].Op != wire.OP_PONG {
+		t.Errorf("The response of ping %v is INVALID!", responses[3])
+		t.FailNow()
+	}
+	expectedCodes := map[uint32]uint16{
+		4: wire.ERROR_CODE_INVALID_GROUP,
+		5: wire.ERROR_CODE_INVALID_REQUEST,
+		6: wire.ERROR_CODE_UNKNOWN_OP,
+	}
+	for requestId, expectedCode := range expectedCodes {
+		_, err := wire.ParseIds(responses[requestId])
+		if wireErr, ok := err.(*wire.Error); !ok || wireErr.Code != expectedCode {
+			t.Errorf("The error of request %d is %v, but the code %d is expected.", requestId, err, expectedCode)
+			t.FailNow()
+		}
+	}
+}
+
+func TestBinaryAuthorization(t *testing.T) {
+	idCenterManager, unregister := newTestManager(t, nil)
+	defer unregister()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Errorf("Listen error: %s", err)
+		t.FailNow()
+	}
+	frontend := NewBinaryFrontend(idCenterManager)
+	frontend.Authenticator = auth.NewAuthenticator()
+	frontend.Authenticator.AddClient("orders", "allocate-token-0123456789", auth.ROLE_ALLOCATE, []string{"order"})
+	go frontend.Serve(listener)
+	defer frontend.Close()
+	conn, err := net.Dial("tcp", listener.Addr().String())
+	if err != nil {
+		t.Errorf("Dial error: %s", err)
+		t.FailNow()
+	}
+	defer conn.Close()
+	// The pipelined requests, and the token of OP_AUTH applies to the ones after it.
+	requests := []*wire.Frame{
+		wire.NewGetIdRequest(1, "order"),
+		wire.NewAuthRequest(2, "unknown-token-0123456789"),
+		wire.NewGetIdRequest(3, "order"),
+		wire.NewAuthRequest(4, "allocate-token-0123456789"),
+		wire.NewGetIdRequest(5, "order"),
+		wire.NewGetIdsRequest(6, "other", 2),
+	}
+	writer := bufio.NewWriter(conn)
+	for _, request := range requests {
+		wire.WriteFrame(writer, request)
+	}
+	if err := writer.Flush(); err != nil {
+		t.Errorf("Write error: %s", err)
+		t.FailNow()
+	}
+	responses := make(map[uint32]*wire.Frame)
+	reader := bufio.NewReader(conn)
+	for range requests {
+		response, err := wire.ReadFrame(reader)
+		if err != nil {
+			t.Errorf("Read error: %s", err)
+			t.FailNow()
+		}
+		responses[response.RequestId] = response
+	}
+	if responses[4] == nil || responses[4].Op != wire.OP_AUTH_OK {
+		t.Errorf("The response of auth %v is INVALID!", responses[4])
+		t.FailNow()
+	}
+	if ids, err := wire.ParseIds(responses[5]); err != nil || len(ids) != 1 {
+		t.Errorf("The response of authenticated request is INVALID! (ids=%v, err=%v)", ids, err)
+		t.FailNow()
+	}
+	expectedCodes := map[uint32]uint16{
+		1: wire.ERROR_CODE_UNAUTHENTICATED,
+		2: wire.ERROR_CODE_UNAUTHENTICATED,
+		3: wire.ERROR_CODE_UNAUTHENTICATED,
+		6: wire.ERROR_CODE_FORBIDDEN,
+	}
+	for requestId, expectedCode := range expectedCodes {
+		_, err := wire.ParseIds(responses[requestId])
+		if wireErr, ok := err.(*wire.Error); !ok || wireErr.Code != expectedCode {
+			t.Errorf("The error of request %d is %v, but the code %d is expected.", requestId, err, expectedCode)
+			t.FailNow()
+		}
+	}
+}
+
+func TestBinaryFrontendShutdown(t *testing.T) {
+	idCenterManager, unregister := newTestManager(t, nil)
+	defer unregister()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Errorf("Listen error: %s", err)
+		t.FailNow()
+	}
+	frontend := NewBinaryFrontend(idCenterManager)
+	go frontend.Serve(listener)
+	conn, err := net.Dial("tcp", listener.Addr().String())
+	if err != nil {
+		t.Errorf("Dial error: %s", err)
+		t.FailNow()
+	}
+	defer conn.Close()
+	wire.WriteFrame(conn, wire.NewGetIdRequest(1, "binary_shutdown_test"))
+	reader := bufio.NewReader(conn)
+	if _, err := wire.ReadFrame(reader); err != nil {
+		t.Errorf("Read error: %s", err)
+		t.FailNow()
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	// The idle connection is closed without waiting for the deadline.
+	if err := frontend.Shutdown(ctx); err != nil {
+		t.Errorf("Shutdown error: %s", err)
+		t.FailNow()
+	}
+	if _, err := wire.ReadFrame(reader); err == nil {
+		t.Errorf("The connection should be closed.")
+		t.FailNow()
+	}
+	if _, err := net.Dial("tcp", listener.Addr().String()); err == nil {
+		t.Errorf("The listener should be closed.")
+		t.FailNow()
+	}
+}
+
+func BenchmarkBinaryGetId(b *testing.B) {
+	conn, stop := startBinaryFrontend(b)
+	defer stop()
+	reader := bufio.NewReader(conn)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := wire.WriteFrame(conn, wire.NewGetIdRequest(uint32(i), "binary_bench")); err != nil {
+			b.Fatalf("Write error: %s", err)
+		}
+		response, err := wire.ReadFrame(reader)
+		if err != nil {
+			b.Fatalf("Read error: %s", err)
+		}
+		if _, err := wire.ParseIds(response); err != nil {
+			b.Fatalf("Get id error: %s", err)
+		}
+	}
+}
+
+func BenchmarkHttpGetId(b *testing.B) {
+	idCenterManager, unregister := newTestManager(b, nil)
+	defer unregister()
+	server := httptest.NewServer(NewHttpFrontend(idCenterManager))
+	defer server.Close()
+	url := server.URL + "/id?group=binary_bench"
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		resp, err := http.Get(url)
+		if err != nil {
+			b.Fatalf("Request error: %s", err)
+		}
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			b.Fatalf("The status %d is not OK.", resp.StatusCode)
+		}
+	}
+}
+
+func TestToWireError(t *testing.T) {
+	cases := []struct {
+		err  error
+		code uint16
+	}{
+		{base.NewError(base.ErrInvalidArgument, base.OP_GET_ID, "wire_error_test", nil), wire.ERROR_CODE_INVALID_REQUEST},
+		{base.NewError(base.ErrInvalidGroupName, base.OP_GET_ID, "", nil), wire.ERROR_CODE_INVALID_GROUP},
+		{base.NewError(base.ErrExhausted, base.OP_PROPEL, "wire_error_test", nil), wire.ERROR_CODE_EXHAUSTED},
+		{base.NewError(base.ErrTimeout, base.OP_POP, "wire_error_test", nil), wire.ERROR_CODE_TIMEOUT},
+		{base.NewError(base.ErrStorageUnavailable, base.OP_PROPEL, "wire_error_test", nil), wire.ERROR_CODE_UNAVAILABLE},
+		{errors.New("unknown"), wire.ERROR_CODE_INTERNAL_ERROR},
+	}
+	for _, c := range cases {
+		_, err := wire.ParseIds(toWireError(1, c.err, "Get ids error"))
+		wireErr, ok := err.(*wire.Error)
+		if !ok || wireErr.Code != c.code {
+			t.Errorf("The error '%v' should be converted to the code %d, but %v.", c.err, c.code, err)
+		}
+	}
+}
@@ -0,0 +1,32 @@
+package frontend
+
+import (
+	"go_idcenter/manager"
+	"go_idcenter/provider/providertest"
+	"testing"
+)
+
+// newTestManager returns a manager on the memory providers, and the function which unregisters them.
+func newTestManager(t testing.TB, groupConfigs map[string]manager.GroupConfig) (*manager.IdCenterManager, func()) {
+	cp := providertest.NewMemoryCacheProvider("Memory Cache Provider (" + t.Name() + ")")
+	sp := providertest.NewMemoryStorageProvider("Memory Storage Provider (" + t.Name() + ")")
+	if err := manager.RegisterProvider(cp); err != nil {
+		t.Errorf("Provider register error: %s", err)
+		t.FailNow()
+	}
+	if err := manager.RegisterProvider(sp); err != nil {
+		t.Errorf("Provider register error: %s", err)
+		t.FailNow()
+	}
+	idCenterManager := &manager.IdCenterManager{
+		CacheProviderName:   cp.Name(),
+		StorageProviderName: sp.Name(),
+		Start:               1,
+		Step:                10,
+		Groups:              groupConfigs,
+	}
+	return idCenterManager, func() {
+		manager.UnregisterProvider(cp)
+		manager.UnregisterProvider(sp)
+	}
+}
@@ -0,0 +1,55 @@
+package frontend
+
+import (
+	"go_idcenter/base"
+	"go_idcenter/manager"
+	"net/http"
+)
+
+const (
+	HEALTH_STATUS_OK        = "ok"
+	HEALTH_STATUS_READY     = "ready"
+	HEALTH_STATUS_NOT_READY = "not_ready"
+)
+
+type ProviderHealthResponse struct {
+	Name      string  `json:"name"`
+	Type      string  `json:"type"`
+	Status    string  `json:"status"`
+	Error     string  `json:"error,omitempty"`
+	LatencyMs float64 `json:"latency_ms"`
+}
+
+type HealthResponse struct {
+	Status    string                   `json:"status"`
+	Providers []ProviderHealthResponse `json:"providers,omitempty"`
+}
+
+// doForLiveness reports that the process is alive, without checking the providers.
+func (self *HttpFrontend) doForLiveness(w http.ResponseWriter, r *http.Request) {
+	writeJson(w, r, http.StatusOK, HealthResponse{Status: HEALTH_STATUS_OK})
+}
+
+// doForReadiness reports whether the id center can allocate, by checking the health of providers.
+// The status is 503 if any provider is failing.
+func (self *HttpFrontend) doForReadiness(w http.ResponseWriter, r *http.Request) {
+	response := HealthResponse{Status: HEALTH_STATUS_READY}
+	for _, health := range self.manager.CheckProviders(manager.DEFAULT_HEALTH_CHECK_TIMEOUT) {
+		if health.Status == manager.HEALTH_STATUS_FAILING {
+			base.Logger().Warnf("The %s provider '%s' is failing: %s\n", health.Type, health.Name, health.Error)
+			response.Status = HEALTH_STATUS_NOT_READY
+		}
+		response.Providers = append(response.Providers, ProviderHealthResponse{
+			Name:      health.Name,
+			Type:      health.Type,
+			Status:    health.Status,
+			Error:     health.Error,
+			LatencyMs: float64(health.Latency.Microseconds()) / 1000,
+		})
+	}
+	status := http.StatusOK
+	if response.Status != HEALTH_STATUS_READY {
+		status = http.StatusServiceUnavailable
+	}
+	writeJson(w, r, status, response)
+}
@@ -0,0 +1,65 @@
+package frontend
+
+import (
+	"encoding/json"
+	"errors"
+	"go_idcenter/base"
+	"go_idcenter/manager"
+	"net/http"
+	"testing"
+)
+
+// The cache provider whose health check fails.
+type failingCacheProvider struct {
+	base.CacheProvider
+}
+
+func (self failingCacheProvider) Name() string {
+	return "Failing Cache Provider"
+}
+
+func (self failingCacheProvider) CheckHealth() error {
+	return errors.New("connection refused")
+}
+
+func TestHealth(t *testing.T) {
+	idCenterManager, unregister := newTestManager(t, nil)
+	defer unregister()
+	frontend := NewHttpFrontend(idCenterManager)
+
+	recorder := doRequest(frontend, "GET", "/healthz", "")
+	if recorder.Code != http.StatusOK {
+		t.Errorf("Unexpected response (status=%d, body=%s).", recorder.Code, recorder.Body)
+		t.FailNow()
+	}
+	var healthResponse HealthResponse
+	recorder = doRequest(frontend, "GET", "/readyz", "")
+	json.Unmarshal(recorder.Body.Bytes(), &healthResponse)
+	if recorder.Code != http.StatusOK || healthResponse.Status != HEALTH_STATUS_READY || len(healthResponse.Providers) != 2 {
+		t.Errorf("Unexpected response (status=%d, body=%s).", recorder.Code, recorder.Body)
+		t.FailNow()
+	}
+	for _, providerHealth := range healthResponse.Providers {
+		if providerHealth.Status != manager.HEALTH_STATUS_OK {
+			t.Errorf("Unexpected provider health: %v", providerHealth)
+			t.FailNow()
+		}
+	}
+
+	failingProvider := failingCacheProvider{}
+	manager.RegisterProvider(failingProvider)
+	defer manager.UnregisterProvider(failingProvider)
+	healthResponse = HealthResponse{}
+	recorder = doRequest(frontend, "GET", "/readyz", "")
+	json.Unmarshal(recorder.Body.Bytes(), &healthResponse)
+	if recorder.Code != http.StatusServiceUnavailable || healthResponse.Status != HEALTH_STATUS_NOT_READY {
+		t.Errorf("Unexpected response (status=%d, body=%s).", recorder.Code, recorder.Body)
+		t.FailNow()
+	}
+	for _, providerHealth := range healthResponse.Providers {
+		if providerHealth.Name == failingProvider.Name() && (providerHealth.Status != manager.HEALTH_STATUS_FAILING || providerHealth.Error != "connection refused") {
+			t.Errorf("Unexpected provider health: %v", providerHealth)
+			t.FailNow()
+		}
+	}
+}
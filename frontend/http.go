@@ -0,0 +1,376 @@
+package frontend
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go_idcenter/auth"
+	"go_idcenter/base"
+	"go_idcenter/manager"
+	"go_idcenter/metrics"
+	"go_idcenter/ratelimit"
+	"log/slog"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	FORMAT_JSON = "json"
+	FORMAT_TEXT = "text"
+	// The header of the request id, which is generated if the client does not give a valid one.
+	REQUEST_ID_HEADER = "X-Request-Id"
+)
+
+// The machine-readable error codes of the http api.
+const (
+	ERROR_CODE_INVALID_GROUP  = "invalid_group"
+	ERROR_CODE_INVALID_ID     = "invalid_id"
+	ERROR_CODE_INVALID_OP     = "invalid_op"
+	ERROR_CODE_NOT_FOUND      = "not_found"
+	ERROR_CODE_NOT_CONFIGURED = "not_configured"
+	ERROR_CODE_UNAVAILABLE    = "unavailable"
+	ERROR_CODE_INTERNAL_ERROR = "internal_error"
+	ERROR_CODE_NOT_ACCEPTABLE = "not_acceptable"
+	ERROR_CODE_UNAUTHORIZED   = "unauthorized"
+	ERROR_CODE_FORBIDDEN      = "forbidden"
+	ERROR_CODE_RATE_LIMITED   = "rate_limited"
+	ERROR_CODE_EXHAUSTED      = "exhausted"
+	ERROR_CODE_TIMEOUT        = "timeout"
+	ERROR_CODE_CANCELED       = "canceled"
+)
+
+const (
+	// The non-standard status of the request whose client is gone, like nginx.
+	STATUS_CLIENT_CLOSED_REQUEST = 499
+)
+
+type HttpError struct {
+	Status  int    `json:"-"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+func (self *HttpError) Error() string {
+	return fmt.Sprintf("%s: %s", self.Code, self.Message)
+}
+
+// The ids are encoded in json strings, because the ids (e.g. the obfuscated ones) may
+// exceed the integers which are exact in JavaScript, i.e. 2^53.
+type IdResponse struct {
+	Group string `json:"group"`
+	Id    uint64 `json:"id,string"`
+}
+
+type ClearResponse struct {
+	Group   string `json:"group"`
+	Cleared bool   `json:"cleared"`
+}
+
+type DecodeResponse struct {
+	Group      string `json:"group"`
+	Id         uint64 `json:"id,string"`
+	OriginalId uint64 `json:"original_id,string"`
+}
+
+type ValidateResponse struct {
+	Group string `json:"group"`
+	Id    uint64 `json:"id,string"`
+	Valid bool   `json:"valid"`
+}
+
+// The http frontend serves the id center by json (default) or plain text.
+type HttpFrontend struct {
+	// The authenticator of clients. The authentication is disabled if it is nil.
+	Authenticator *auth.Authenticator
+	// The rate limiter of the allocations by clients and groups. No limit if it is nil.
+	RateLimiter *ratelimit.Limiter
+	manager     *manager.IdCenterManager
+	mux         *http.ServeMux
+}
+
+func NewHttpFrontend(idCenterManager *manager.IdCenterManager) *HttpFrontend {
+	frontend := &HttpFrontend{manager: idCenterManager, mux: http.NewServeMux()}
+	frontend.mux.HandleFunc("/id", frontend.doForId)
+	frontend.mux.HandleFunc("/id/validate", frontend.doForValidation)
+	frontend.mux.HandleFunc(V1_PREFIX, frontend.doForV1)
+	frontend.mux.HandleFunc("/metrics", frontend.doForMetrics)
+	frontend.mux.HandleFunc("/healthz", frontend.doForLiveness)
+	frontend.mux.HandleFunc("/readyz", frontend.doForReadiness)
+	frontend.mux.HandleFunc("/", frontend.doForNotFound)
+	return frontend
+}
+
+// ServeHTTP serves the request with the request id, which is echoed by the header REQUEST_ID_HEADER
+// and carried by the context of request for correlating the logs.
+func (self *HttpFrontend) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	requestId := r.Header.Get(REQUEST_ID_HEADER)
+	if !base.IsValidRequestId(requestId) {
+		requestId = base.NewRequestId()
+	}
+	w.Header().Set(REQUEST_ID_HEADER, requestId)
+	self.mux.ServeHTTP(w, r.WithContext(base.WithRequestId(r.Context(), requestId)))
+}
+
+func (self *HttpFrontend) doForId(w http.ResponseWriter, r *http.Request) {
+	group := r.FormValue("group")
+	op := r.FormValue("op")
+	start := time.Now()
+	logger := base.Log(base.SUBSYSTEM_FRONTEND)
+	logger.DebugContext(r.Context(), "Receive a request for id.", base.LOG_FIELD_GROUP, group, base.LOG_FIELD_OP, op)
+	if len(group) == 0 {
+		writeError(w, r, &HttpError{http.StatusBadRequest, ERROR_CODE_INVALID_GROUP, "The group name is empty!"})
+		return
+	}
+	permission := auth.PERMISSION_ALLOCATE
+	// Decoding reveals the original ids, i.e. the order and the volume of the issued ones.
+	if op == "clear" || op == "decode" {
+		permission = auth.PERMISSION_ADMIN
+	}
+	if !self.authorize(w, r, permission, group) {
+		return
+	}
+	switch op {
+	case "", "get":
+		if !self.limit(w, r, group, 1) {
+			return
+		}
+		id, err := self.manager.GetIdContext(r.Context(), group)
+		if err == nil && id == 0 {
+			err = &HttpError{http.StatusServiceUnavailable, ERROR_CODE_UNAVAILABLE, "No id is available now!"}
+		}
+		if err != nil {
+			writeError(w, r, toHttpError(r, err, "Get id error"))
+			return
+		}
+		logger.InfoContext(r.Context(), "The id is got.", base.LOG_FIELD_GROUP, group, base.LOG_FIELD_OP, "get", base.LOG_FIELD_LATENCY, base.Latency(start))
+		writeResponse(w, r, IdResponse{Group: group, Id: id}, id)
+	case "clear":
+		if r.Method != "POST" {
+			writeMethodNotAllowed(w, r, "POST")
+			return
+		}
+		result, err := self.manager.ClearContext(r.Context(), group)
+		if err != nil {
+			writeError(w, r, toHttpError(r, err, "Clear id group error"))
+			return
+		}
+		writeResponse(w, r, ClearResponse{Group: group, Cleared: result}, result)
+	case "decode":
+		id, httpErr := parseId(r)
+		if httpErr != nil {
+			writeError(w, r, httpErr)
+			return
+		}
+		originalId, err := self.manager.Decode(group, id)
+		if err != nil {
+			writeError(w, r, &HttpError{http.StatusBadRequest, ERROR_CODE_INVALID_ID, err.Error()})
+			return
+		}
+		writeResponse(w, r, DecodeResponse{Group: group, Id: id, OriginalId: originalId}, originalId)
+	default:
+		writeError(w, r, &HttpError{http.StatusBadRequest, ERROR_CODE_INVALID_OP, fmt.Sprintf("Unsupported op '%s'!", op)})
+	}
+}
+
+func (self *HttpFrontend) doForValidation(w http.ResponseWriter, r *http.Request) {
+	group := r.FormValue("group")
+	base.Log(base.SUBSYSTEM_FRONTEND).DebugContext(r.Context(), "Receive a request for id validation.", base.LOG_FIELD_GROUP, group, "id", r.FormValue("id"))
+	if len(group) == 0 {
+		writeError(w, r, &HttpError{http.StatusBadRequest, ERROR_CODE_INVALID_GROUP, "The group name is empty!"})
+		return
+	}
+	if !self.authorize(w, r, auth.PERMISSION_ALLOCATE, group) {
+		return
+	}
+	id, httpErr := parseId(r)
+	if httpErr != nil {
+		writeError(w, r, httpErr)
+		return
+	}
+	if self.manager.Groups[group].CheckDigitScheme == nil {
+		errorMsg := fmt.Sprintf("The group '%s' has no check digit scheme!", group)
+		writeError(w, r, &HttpError{http.StatusNotFound, ERROR_CODE_NOT_CONFIGURED, errorMsg})
+		return
+	}
+	valid, err := self.manager.Validate(group, id)
+	if err != nil {
+		writeError(w, r, toHttpError(r, err, "Validate id error"))
+		return
+	}
+	writeResponse(w, r, ValidateResponse{Group: group, Id: id, Valid: valid}, valid)
+}
+
+func (self *HttpFrontend) doForNotFound(w http.ResponseWriter, r *http.Request) {
+	writeError(w, r, &HttpError{http.StatusNotFound, ERROR_CODE_NOT_FOUND, fmt.Sprintf("The path '%s' is not found!", r.URL.Path)})
+}
+
+// authorize checks the token of request for the permission on group before the manager is invoked.
+// The error is written and the attempt is audited if it is denied.
+func (self *HttpFrontend) authorize(w http.ResponseWriter, r *http.Request, permission string, group string) bool {
+	if self.Authenticator == nil {
+		return true
+	}
+	client, err := self.Authenticator.Authorize(auth.TokenFromRequest(r), r.TLS, permission, group)
+	if err == nil {
+		return true
+	}
+	auth.Audit(r.Context(), client, r.RemoteAddr, permission, group, err)
+	if err == auth.ErrUnauthenticated {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="idcenter"`)
+		writeError(w, r, &HttpError{http.StatusUnauthorized, ERROR_CODE_UNAUTHORIZED, err.Error()})
+		return false
+	}
+	writeError(w, r, &HttpError{http.StatusForbidden, ERROR_CODE_FORBIDDEN, fmt.Sprintf("The %s operation on group '%s' is not allowed!", permission, group)})
+	return false
+}
+
+// doForMetrics exposes the metrics of all groups, which needs the admin permission if the authentication is enabled.
+func (self *HttpFrontend) doForMetrics(w http.ResponseWriter, r *http.Request) {
+	if !self.authorize(w, r, auth.PERMISSION_ADMIN, auth.ALL_GROUPS) {
+		return
+	}
+	metrics.Handler().ServeHTTP(w, r)
+}
+
+// limit takes the tokens of count ids of the client and the group of request from the rate limiter.
+// The error is written with the header 'Retry-After' if it is rejected.
+func (self *HttpFrontend) limit(w http.ResponseWriter, r *http.Request, group string, count uint64) bool {
+	if self.RateLimiter == nil {
+		return true
+	}
+	client := self.clientIdentity(r)
+	ok, wait := self.RateLimiter.AllowN(client, group, count)
+	if ok {
+		return true
+	}
+	retryAfter := int(math.Max(1, math.Ceil(wait.Seconds())))
+	base.Log(base.SUBSYSTEM_FRONTEND).WarnContext(r.Context(), "The request is rate limited.", "client", client, base.LOG_FIELD_GROUP, group, "retry_after", retryAfter)
+	w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+	writeError(w, r, &HttpError{http.StatusTooManyRequests, ERROR_CODE_RATE_LIMITED, fmt.Sprintf("Too many requests! Retry after %d seconds.", retryAfter)})
+	return false
+}
+
+// clientIdentity returns the name of the authenticated client, or the remote host if the authentication is disabled.
+func (self *HttpFrontend) clientIdentity(r *http.Request) string {
+	if self.Authenticator != nil {
+		if client := self.Authenticator.Identify(auth.TokenFromRequest(r), r.TLS); client != nil {
+			return client.Name
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+func parseId(r *http.Request) (uint64, *HttpError) {
+	literal := r.FormValue("id")
+	id, err := strconv.ParseUint(literal, 10, 64)
+	if err != nil {
+		return 0, &HttpError{http.StatusBadRequest, ERROR_CODE_INVALID_ID, fmt.Sprintf("The id '%s' is INVALID!", literal)}
+	}
+	return id, nil
+}
+
+// toHttpError converts the error of manager to the http one by its kind. The server errors are logged
+// at the error level, and the others at the warning level.
+func toHttpError(r *http.Request, err error, errorMsgPrefix string) *HttpError {
+	var httpErr *HttpError
+	if errors.As(err, &httpErr) {
+		return httpErr
+	}
+	httpErr = &HttpError{http.StatusInternalServerError, ERROR_CODE_INTERNAL_ERROR, err.Error()}
+	switch {
+	case errors.Is(err, base.ErrTimeout):
+		httpErr.Status, httpErr.Code = http.StatusGatewayTimeout, ERROR_CODE_TIMEOUT
+	case errors.Is(err, base.ErrCanceled):
+		httpErr.Status, httpErr.Code = STATUS_CLIENT_CLOSED_REQUEST, ERROR_CODE_CANCELED
+	case errors.Is(err, base.ErrInvalidGroupName):
+		httpErr.Status, httpErr.Code = http.StatusBadRequest, ERROR_CODE_INVALID_GROUP
+	case errors.Is(err, base.ErrInvalidArgument):
+		httpErr.Status, httpErr.Code = http.StatusBadRequest, ERROR_CODE_INVALID_REQUEST
+	case errors.Is(err, base.ErrGroupNotFound):
+		httpErr.Status, httpErr.Code = http.StatusNotFound, ERROR_CODE_GROUP_NOT_FOUND
+	case errors.Is(err, base.ErrConflict):
+		httpErr.Status, httpErr.Code = http.StatusConflict, ERROR_CODE_CONFLICT
+	case errors.Is(err, base.ErrExhausted):
+		httpErr.Status, httpErr.Code = http.StatusConflict, ERROR_CODE_EXHAUSTED
+	case errors.Is(err, base.ErrStorageUnavailable), errors.Is(err, base.ErrCacheUnavailable), errors.Is(err, base.ErrShutDown):
+		httpErr.Status, httpErr.Code = http.StatusServiceUnavailable, ERROR_CODE_UNAVAILABLE
+	}
+	level := slog.LevelWarn
+	if httpErr.Status >= http.StatusInternalServerError {
+		level = slog.LevelError
+	}
+	base.Log(base.SUBSYSTEM_FRONTEND).Log(r.Context(), level, errorMsgPrefix, base.LOG_FIELD_PATH, r.URL.Path, base.LOG_FIELD_ERROR, err.Error(), base.LOG_FIELD_ERROR_CLASS, base.ErrorClass(err))
+	return httpErr
+}
+
+// negotiateFormat chooses the response format by the parameter 'format' or the header 'Accept'.
+func negotiateFormat(r *http.Request) (string, bool) {
+	switch r.FormValue("format") {
+	case FORMAT_JSON:
+		return FORMAT_JSON, true
+	case FORMAT_TEXT:
+		return FORMAT_TEXT, true
+	case "":
+	default:
+		return FORMAT_JSON, false
+	}
+	accept := r.Header.Get("Accept")
+	if len(accept) == 0 {
+		return FORMAT_JSON, true
+	}
+	for _, part := range strings.Split(accept, ",") {
+		mediaType := strings.TrimSpace(strings.SplitN(part, ";", 2)[0])
+		switch mediaType {
+		case "application/json", "application/*", "*/*":
+			return FORMAT_JSON, true
+		case "text/plain", "text/*":
+			return FORMAT_TEXT, true
+		}
+	}
+	return FORMAT_JSON, false
+}
+
+// writeResponse writes the content in json, or the literal of value in plain text.
+func writeResponse(w http.ResponseWriter, r *http.Request, content interface{}, value interface{}) {
+	format, ok := negotiateFormat(r)
+	if !ok {
+		writeError(w, r, &HttpError{http.StatusNotAcceptable, ERROR_CODE_NOT_ACCEPTABLE, "Only json and plain text are supported!"})
+		return
+	}
+	if format == FORMAT_TEXT {
+		writeBody(w, http.StatusOK, "text/plain; charset=utf-8", []byte(fmt.Sprintf("%v", value)))
+		return
+	}
+	body, err := json.Marshal(content)
+	if err != nil {
+		writeError(w, r, toHttpError(r, err, "Json marshalling error"))
+		return
+	}
+	writeBody(w, http.StatusOK, "application/json", body)
+}
+
+func writeError(w http.ResponseWriter, r *http.Request, httpErr *HttpError) {
+	if format, _ := negotiateFormat(r); format == FORMAT_TEXT {
+		writeBody(w, httpErr.Status, "text/plain; charset=utf-8", []byte(httpErr.Error()))
+		return
+	}
+	body, _ := json.Marshal(httpErr)
+	writeBody(w, httpErr.Status, "application/json", body)
+}
+
+func writeBody(w http.ResponseWriter, status int, contentType string, body []byte) {
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+	w.WriteHeader(status)
+	if _, err := w.Write(body); err != nil {
+		base.Logger().Errorf("Writing response error (status=%d, body=%s): %s\n", status, body, err)
+	}
+}
@@ -0,0 +1,283 @@
+package frontend
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go_idcenter/auth"
+	"go_idcenter/base"
+	"go_idcenter/manager"
+	"go_idcenter/ratelimit"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func doRequest(handler http.Handler, method string, url string, accept string) *httptest.ResponseRecorder {
+	request := httptest.NewRequest(method, url, nil)
+	if len(accept) > 0 {
+		request.Header.Set("Accept", accept)
+	}
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, request)
+	return recorder
+}
+
+func TestHttpFrontend(t *testing.T) {
+	scheme, _ := manager.ParseCheckDigitScheme("luhn")
+	groupConfigs := map[string]manager.GroupConfig{"checked": manager.GroupConfig{CheckDigitScheme: scheme}}
+	idCenterManager, unregister := newTestManager(t, groupConfigs)
+	defer unregister()
+	frontend := NewHttpFrontend(idCenterManager)
+
+	// Get id in json
+	recorder := doRequest(frontend, "GET", "/id?group=http_frontend_test", "")
+	if recorder.Code != http.StatusOK || recorder.Header().Get("Content-Type") != "application/json" {
+		t.Errorf("Unexpected response (status=%d, body=%s).", recorder.Code, recorder.Body)
+		t.FailNow()
+	}
+	// The id is in a json string, which is exact beyond 2^53 in JavaScript.
+	if body := recorder.Body.String(); body != `{"group":"http_frontend_test","id":"1"}` {
+		t.Errorf("Unexpected json body: %s", body)
+	}
+	var idResponse IdResponse
+	if err := json.Unmarshal(recorder.Body.Bytes(), &idResponse); err != nil {
+		t.Errorf("Json unmarshalling error: %s", err)
+		t.FailNow()
+	}
+	if idResponse.Group != "http_frontend_test" || idResponse.Id != 1 {
+		t.Errorf("Unexpected id response: %v", idResponse)
+	}
+
+	// Get id in plain text
+	recorder = doRequest(frontend, "GET", "/id?group=http_frontend_test", "text/plain")
+	if recorder.Code != http.StatusOK || recorder.Body.String() != "2" {
+		t.Errorf("Unexpected response (status=%d, body=%s).", recorder.Code, recorder.Body)
+	}
+	recorder = doRequest(frontend, "GET", "/id?group=http_frontend_test&format=text", "application/json")
+	if recorder.Code != http.StatusOK || recorder.Body.String() != "3" {
+		t.Errorf("Unexpected response (status=%d, body=%s).", recorder.Code, recorder.Body)
+	}
+
+	// Errors
+	expectedErrors := []struct {
+		url    string
+		accept string
+		status int
+		code   string
+	}{
+		{"/id", "", http.StatusBadRequest, ERROR_CODE_INVALID_GROUP},
+		{"/id?group=http_frontend_test&op=unknown", "", http.StatusBadRequest, ERROR_CODE_INVALID_OP},
+		{"/id?group=http_frontend_test", "image/png", http.StatusNotAcceptable, ERROR_CODE_NOT_ACCEPTABLE},
+		{"/id/validate?group=http_frontend_test&id=18", "", http.StatusNotFound, ERROR_CODE_NOT_CONFIGURED},
+		{"/id/validate?group=checked&id=abc", "", http.StatusBadRequest, ERROR_CODE_INVALID_ID},
+		{"/unknown", "", http.StatusNotFound, ERROR_CODE_NOT_FOUND},
+	}
+	for _, expectedError := range expectedErrors {
+		recorder = doRequest(frontend, "GET", expectedError.url, expectedError.accept)
+		var httpErr HttpError
+		if err := json.Unmarshal(recorder.Body.Bytes(), &httpErr); err != nil {
+			t.Errorf("Json unmarshalling error (url=%s): %s", expectedError.url, err)
+			continue
+		}
+		if recorder.Code != expectedError.status || httpErr.Code != expectedError.code {
+			t.Errorf("Unexpected error response (url=%s, status=%d, body=%s).", expectedError.url, recorder.Code, recorder.Body)
+		}
+	}
+
+	// Validate
+	recorder = doRequest(frontend, "GET", "/id/validate?group=checked&id=18", "")
+	var validateResponse ValidateResponse
+	if err := json.Unmarshal(recorder.Body.Bytes(), &validateResponse); err != nil || !validateResponse.Valid {
+		t.Errorf("Unexpected response (status=%d, body=%s).", recorder.Code, recorder.Body)
+	}
+	recorder = doRequest(frontend, "GET", "/id/validate?group=checked&id=19", "text/plain")
+	if recorder.Code != http.StatusOK || recorder.Body.String() != "false" {
+		t.Errorf("Unexpected response (status=%d, body=%s).", recorder.Code, recorder.Body)
+	}
+
+	// Clear
+	recorder = doRequest(frontend, "GET", "/id?group=http_frontend_test&op=clear", "")
+	if recorder.Code != http.StatusMethodNotAllowed || recorder.Header().Get("Allow") != "POST" {
+		t.Errorf("Unexpected response (status=%d, body=%s).", recorder.Code, recorder.Body)
+	}
+	recorder = doRequest(frontend, "POST", "/id?group=http_frontend_test&op=clear", "")
+	var clearResponse ClearResponse
+	if err := json.Unmarshal(recorder.Body.Bytes(), &clearResponse); err != nil || !clearResponse.Cleared {
+		t.Errorf("Unexpected response (status=%d, body=%s).", recorder.Code, recorder.Body)
+	}
+}
+
+func TestMetrics(t *testing.T) {
+	idCenterManager, unregister := newTestManager(t, nil)
+	defer unregister()
+	frontend := NewHttpFrontend(idCenterManager)
+	for i := 0; i < 12; i++ {
+		doRequest(frontend, "GET", "/id?group=metrics_test", "")
+	}
+	recorder := doRequest(frontend, "GET", "/metrics", "")
+	if recorder.Code != http.StatusOK {
+		t.Errorf("Unexpected response (status=%d, body=%s).", recorder.Code, recorder.Body)
+		t.FailNow()
+	}
+	body := recorder.Body.String()
+	// The step is 10, so the cache is refilled twice.
+	expectedLines := []string{
+		`idcenter_ids_issued_total{group="metrics_test"} 12`,
+		`idcenter_refills_total{group="metrics_test"} 2`,
+		`idcenter_cache_misses_total{group="metrics_test"} 2`,
+		`idcenter_operation_duration_seconds_count{operation="propel"}`,
+	}
+	for _, expectedLine := range expectedLines {
+		if !strings.Contains(body, expectedLine) {
+			t.Errorf("The metrics do not contain '%s': %s", expectedLine, body)
+			t.FailNow()
+		}
+	}
+}
+
+func TestHttpAuthorization(t *testing.T) {
+	idCenterManager, unregister := newTestManager(t, nil)
+	defer unregister()
+	frontend := NewHttpFrontend(idCenterManager)
+	frontend.Authenticator = auth.NewAuthenticator()
+	frontend.Authenticator.AddClient("ops", "admin-token-0123456789", auth.ROLE_ADMIN, []string{auth.ALL_GROUPS})
+	frontend.Authenticator.AddClient("orders", "allocate-token-0123456789", auth.ROLE_ALLOCATE, []string{"order"})
+	doAuthorizedRequest := func(method string, url string, body string, token string) *httptest.ResponseRecorder {
+		request := httptest.NewRequest(method, url, strings.NewReader(body))
+		if len(token) > 0 {
+			request.Header.Set("Authorization", "Bearer "+token)
+		}
+		recorder := httptest.NewRecorder()
+		frontend.ServeHTTP(recorder, request)
+		return recorder
+	}
+	cases := []struct {
+		method string
+		url    string
+		body   string
+		token  string
+		status int
+	}{
+		{"GET", "/id?group=order", "", "", http.StatusUnauthorized},
+		{"GET", "/id?group=order", "", "unknown-token-0123456789", http.StatusUnauthorized},
+		{"GET", "/id?group=order", "", "allocate-token-0123456789", http.StatusOK},
+		{"GET", "/id?group=user", "", "allocate-token-0123456789", http.StatusForbidden},
+		{"POST", "/id?group=order&op=clear", "", "allocate-token-0123456789", http.StatusForbidden},
+		{"GET", "/id?group=order&op=decode&id=1", "", "allocate-token-0123456789", http.StatusForbidden},
+		{"POST", "/v1/groups/order/ids", `{"count":2}`, "allocate-token-0123456789", http.StatusOK},
+		{"POST", "/v1/groups/order/ranges", `{"size":5}`, "allocate-token-0123456789", http.StatusOK},
+		{"GET", "/v1/groups/order", "", "allocate-token-0123456789", http.StatusForbidden},
+		{"GET", "/v1/groups", "", "allocate-token-0123456789", http.StatusForbidden},
+		{"POST", "/v1/groups", `{"name":"order2","start":1,"step":10}`, "allocate-token-0123456789", http.StatusForbidden},
+		{"DELETE", "/v1/groups/order", "", "allocate-token-0123456789", http.StatusForbidden},
+		{"GET", "/v1/groups", "", "admin-token-0123456789", http.StatusOK},
+		{"GET", "/id?group=order&op=decode&id=1", "", "admin-token-0123456789", http.StatusOK},
+		{"POST", "/id?group=order&op=clear", "", "admin-token-0123456789", http.StatusOK},
+		{"GET", "/healthz", "", "", http.StatusOK},
+		{"GET", "/readyz", "", "", http.StatusOK},
+		{"GET", "/metrics", "", "", http.StatusUnauthorized},
+		{"GET", "/metrics", "", "allocate-token-0123456789", http.StatusForbidden},
+		{"GET", "/metrics", "", "admin-token-0123456789", http.StatusOK},
+	}
+	for _, c := range cases {
+		recorder := doAuthorizedRequest(c.method, c.url, c.body, c.token)
+		if recorder.Code != c.status {
+			t.Errorf("Unexpected response of %s %s by token '%s' (status=%d, body=%s).", c.method, c.url, c.token, recorder.Code, recorder.Body)
+		}
+		if recorder.Code == http.StatusUnauthorized && len(recorder.Header().Get("WWW-Authenticate")) == 0 {
+			t.Errorf("The header WWW-Authenticate is missing.")
+		}
+	}
+	var httpErr HttpError
+	recorder := doAuthorizedRequest("GET", "/id?group=user", "", "allocate-token-0123456789")
+	json.Unmarshal(recorder.Body.Bytes(), &httpErr)
+	if httpErr.Code != ERROR_CODE_FORBIDDEN {
+		t.Errorf("Unexpected error code '%s'.", httpErr.Code)
+	}
+}
+
+func TestHttpRateLimit(t *testing.T) {
+	idCenterManager, unregister := newTestManager(t, nil)
+	defer unregister()
+	frontend := NewHttpFrontend(idCenterManager)
+	frontend.RateLimiter = ratelimit.NewLimiter()
+	frontend.RateLimiter.SetLimit(ratelimit.KIND_GROUP, "limited", ratelimit.Limit{Rate: 1, Burst: 10})
+
+	for i := 0; i < 2; i++ {
+		if recorder := doRequest(frontend, "GET", "/id?group=limited", ""); recorder.Code != http.StatusOK {
+			t.Errorf("Unexpected response (status=%d, body=%s).", recorder.Code, recorder.Body)
+			t.FailNow()
+		}
+	}
+	// The batch is charged by its count of ids.
+	if recorder := doJsonRequest(frontend, "POST", "/v1/groups/limited/ids", `{"count":5}`, nil); recorder.Code != http.StatusOK {
+		t.Errorf("Unexpected response (status=%d, body=%s).", recorder.Code, recorder.Body)
+		t.FailNow()
+	}
+	recorder := doJsonRequest(frontend, "POST", "/v1/groups/limited/ids", `{"count":5}`, nil)
+	if recorder.Code != http.StatusTooManyRequests || recorder.Header().Get("Retry-After") != "2" {
+		t.Errorf("Unexpected response (status=%d, retry_after=%s, body=%s).", recorder.Code, recorder.Header().Get("Retry-After"), recorder.Body)
+		t.FailNow()
+	}
+	if recorder := doRequest(frontend, "GET", "/id?group=unlimited", ""); recorder.Code != http.StatusOK {
+		t.Errorf("The other group should not be limited (status=%d, body=%s).", recorder.Code, recorder.Body)
+	}
+	var limitListResponse LimitListResponse
+	recorder = doJsonRequest(frontend, "GET", "/v1/limits", "", &limitListResponse)
+	if recorder.Code != http.StatusOK || len(limitListResponse.Limits) != 1 {
+		t.Errorf("Unexpected response (status=%d, body=%s).", recorder.Code, recorder.Body)
+		t.FailNow()
+	}
+	usage := limitListResponse.Limits[0]
+	if usage.Kind != ratelimit.KIND_GROUP || usage.Key != "limited" || usage.Allowed != 3 || usage.Rejected != 1 {
+		t.Errorf("Unexpected usage: %v", usage)
+	}
+}
+
+func TestHttpRequestId(t *testing.T) {
+	idCenterManager, unregister := newTestManager(t, nil)
+	defer unregister()
+	frontend := NewHttpFrontend(idCenterManager)
+
+	request := httptest.NewRequest("GET", "/id?group=request_id_test", nil)
+	request.Header.Set(REQUEST_ID_HEADER, "client-request-1")
+	recorder := httptest.NewRecorder()
+	frontend.ServeHTTP(recorder, request)
+	if recorder.Code != http.StatusOK || recorder.Header().Get(REQUEST_ID_HEADER) != "client-request-1" {
+		t.Errorf("The request id should be echoed (status=%d, request_id=%s).", recorder.Code, recorder.Header().Get(REQUEST_ID_HEADER))
+	}
+	recorder = doRequest(frontend, "GET", "/id?group=request_id_test", "")
+	if requestId := recorder.Header().Get(REQUEST_ID_HEADER); len(requestId) != 16 {
+		t.Errorf("The request id '%s' should be generated.", requestId)
+	}
+}
+
+func TestToHttpError(t *testing.T) {
+	request := httptest.NewRequest("GET", "/id?group=http_error_test", nil)
+	cause := errors.New("test")
+	testCases := []struct {
+		err    error
+		status int
+		code   string
+	}{
+		{base.NewError(base.ErrInvalidGroupName, base.OP_GET_ID, "", nil), http.StatusBadRequest, ERROR_CODE_INVALID_GROUP},
+		{base.NewError(base.ErrGroupNotFound, base.OP_GET, "http_error_test", nil), http.StatusNotFound, ERROR_CODE_GROUP_NOT_FOUND},
+		{base.NewError(base.ErrConflict, base.OP_BUILD_INFO, "http_error_test", cause), http.StatusConflict, ERROR_CODE_CONFLICT},
+		{base.NewError(base.ErrExhausted, base.OP_PROPEL, "http_error_test", nil), http.StatusConflict, ERROR_CODE_EXHAUSTED},
+		{base.UnavailableError(base.ErrStorageUnavailable, base.OP_PROPEL, "http_error_test", cause), http.StatusServiceUnavailable, ERROR_CODE_UNAVAILABLE},
+		{base.NewError(base.ErrShutDown, base.OP_GET_ID, "http_error_test", nil), http.StatusServiceUnavailable, ERROR_CODE_UNAVAILABLE},
+		{base.NewError(base.ErrTimeout, base.OP_POP, "http_error_test", cause), http.StatusGatewayTimeout, ERROR_CODE_TIMEOUT},
+		{base.NewError(base.ErrCanceled, base.OP_POP, "http_error_test", cause), STATUS_CLIENT_CLOSED_REQUEST, ERROR_CODE_CANCELED},
+		{fmt.Errorf("Clear Failing: %w", base.NewError(base.ErrGroupNotFound, base.OP_CLEAR, "http_error_test", nil)), http.StatusNotFound, ERROR_CODE_GROUP_NOT_FOUND},
+		{cause, http.StatusInternalServerError, ERROR_CODE_INTERNAL_ERROR},
+	}
+	for _, testCase := range testCases {
+		httpErr := toHttpError(request, testCase.err, "Test error")
+		if httpErr.Status != testCase.status || httpErr.Code != testCase.code {
+			t.Errorf("The error '%s' should be %d (%s), but %d (%s).", testCase.err, testCase.status, testCase.code, httpErr.Status, httpErr.Code)
+			t.FailNow()
+		}
+	}
+}
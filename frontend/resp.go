@@ -0,0 +1,388 @@
+package frontend
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"go_idcenter/auth"
+	"go_idcenter/base"
+	"go_idcenter/manager"
+	"go_idcenter/ratelimit"
+	"io"
+	"math"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	RESP_MAX_BULK_LENGTH = 64 * 1024
+	RESP_MAX_ARRAY_SIZE  = 1024
+	RESP_MAX_LINE_LENGTH = 64 * 1024
+)
+
+// The RESP frontend speaks the Redis protocol, so that any Redis client can get ids:
+//
+//	INCR <group>           the next id of group (a bulk string if it exceeds the signed 64-bit integer)
+//	INCRBY <group> <n>     the next n ids of group, in an array
+//	IDINFO <group>         the info of group, in an array of field-value pairs like HGETALL
+//	AUTH [<user>] <token>  the token of the following commands on the connection (the user is ignored)
+//	PING, QUIT, COMMAND
+//
+// If the authentication is enabled, INCR and INCRBY need the allocate permission and IDINFO
+// needs the admin one, of the client given by AUTH or the client certificate of TLS connection.
+type RespFrontend struct {
+	// The authenticator of clients. The authentication is disabled if it is nil.
+	Authenticator *auth.Authenticator
+	// The rate limiter of the allocations by clients and groups. No limit if it is nil.
+	RateLimiter *ratelimit.Limiter
+	manager     *manager.IdCenterManager
+	sign        sync.Mutex
+	listener    net.Listener
+	conns       map[net.Conn]bool
+	connWait    sync.WaitGroup
+	closed      bool
+	// The context of requests, which is cancelled by Close, so that the requests in flight give up.
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+func NewRespFrontend(idCenterManager *manager.IdCenterManager) *RespFrontend {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &RespFrontend{manager: idCenterManager, conns: make(map[net.Conn]bool), ctx: ctx, cancel: cancel}
+}
+
+// Serve accepts the connections of listener until Close is called.
+func (self *RespFrontend) Serve(listener net.Listener) error {
+	self.sign.Lock()
+	self.listener = listener
+	self.sign.Unlock()
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			self.sign.Lock()
+			closed := self.closed
+			self.sign.Unlock()
+			if closed {
+				return nil
+			}
+			return err
+		}
+		self.sign.Lock()
+		if self.closed {
+			self.sign.Unlock()
+			conn.Close()
+			return nil
+		}
+		self.conns[conn] = true
+		self.connWait.Add(1)
+		self.sign.Unlock()
+		go self.serveConn(conn)
+	}
+}
+
+// Shutdown stops accepting connections and reading requests, and waits for the requests
+// read to be replied until ctx is done. The connections are closed at last.
+func (self *RespFrontend) Shutdown(ctx context.Context) error {
+	self.sign.Lock()
+	self.closed = true
+	if self.listener != nil {
+		self.listener.Close()
+	}
+	for conn := range self.conns {
+		conn.SetReadDeadline(time.Now())
+	}
+	self.sign.Unlock()
+	done := make(chan struct{})
+	go func() {
+		self.connWait.Wait()
+		close(done)
+	}()
+	var err error
+	select {
+	case <-done:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+	self.Close()
+	return err
+}
+
+func (self *RespFrontend) Close() error {
+	self.sign.Lock()
+	defer self.sign.Unlock()
+	self.closed = true
+	self.cancel()
+	for conn := range self.conns {
+		conn.Close()
+	}
+	if self.listener == nil {
+		return nil
+	}
+	return self.listener.Close()
+}
+
+func (self *RespFrontend) serveConn(conn net.Conn) {
+	defer func() {
+		defer self.connWait.Done()
+		self.sign.Lock()
+		delete(self.conns, conn)
+		self.sign.Unlock()
+		conn.Close()
+	}()
+	reader := bufio.NewReader(conn)
+	writer := bufio.NewWriter(conn)
+	session := &respSession{conn: conn}
+	for {
+		args, err := readRespCommand(reader)
+		if err != nil {
+			if err != io.EOF && !self.isClosed() {
+				base.Logger().Warnf("Reading RESP command error (remote=%s): %s\n", conn.RemoteAddr(), err)
+				writeRespError(writer, "ERR Protocol error: "+err.Error())
+				writer.Flush()
+			}
+			return
+		}
+		if len(args) == 0 {
+			continue
+		}
+		quit := self.execute(session, writer, args)
+		// Flush only when the pipelined commands are all executed.
+		if reader.Buffered() == 0 || quit {
+			if err := writer.Flush(); err != nil {
+				base.Logger().Warnf("Writing RESP reply error (remote=%s): %s\n", conn.RemoteAddr(), err)
+				return
+			}
+		}
+		if quit {
+			return
+		}
+	}
+}
+
+func (self *RespFrontend) isClosed() bool {
+	self.sign.Lock()
+	defer self.sign.Unlock()
+	return self.closed
+}
+
+// The state of RESP connection.
+type respSession struct {
+	conn net.Conn
+	// The token given by AUTH.
+	token string
+}
+
+// authorize writes the error reply and returns false if the session has not the permission on group.
+func (self *RespFrontend) authorize(session *respSession, writer *bufio.Writer, permission string, group string) bool {
+	err := authorizeConn(self.ctx, self.Authenticator, session.conn, session.token, permission, group)
+	if err == nil {
+		return true
+	}
+	if err == auth.ErrUnauthenticated {
+		writeRespError(writer, "NOAUTH Authentication required.")
+		return false
+	}
+	writeRespError(writer, fmt.Sprintf("NOPERM The %s operation on group '%s' is not allowed!", permission, group))
+	return false
+}
+
+// limit writes the error reply and returns false if the count ids of session on group are rate limited.
+func (self *RespFrontend) limit(session *respSession, writer *bufio.Writer, group string, count uint64) bool {
+	retryAfter := limitConn(self.ctx, self.RateLimiter, self.Authenticator, session.conn, session.token, group, count)
+	if retryAfter == 0 {
+		return true
+	}
+	writeRespError(writer, fmt.Sprintf("ERR Too many requests! Retry after %d seconds.", retryAfter))
+	return false
+}
+
+// execute runs the command and writes the reply. The result is true if the connection should be closed.
+func (self *RespFrontend) execute(session *respSession, writer *bufio.Writer, args []string) bool {
+	command := strings.ToUpper(args[0])
+	switch command {
+	case "AUTH":
+		if len(args) != 2 && len(args) != 3 {
+			writeRespArgumentError(writer, command)
+			break
+		}
+		token := args[len(args)-1]
+		if self.Authenticator != nil && self.Authenticator.Authenticate(token) == nil {
+			auth.Audit(self.ctx, nil, session.conn.RemoteAddr().String(), "authenticate", "", auth.ErrUnauthenticated)
+			writeRespError(writer, "WRONGPASS invalid username-password pair or user is disabled.")
+			break
+		}
+		session.token = token
+		writer.WriteString("+OK\r\n")
+	case "PING":
+		if len(args) > 1 {
+			writeRespBulk(writer, args[1])
+		} else {
+			writer.WriteString("+PONG\r\n")
+		}
+	case "QUIT":
+		writer.WriteString("+OK\r\n")
+		return true
+	case "COMMAND":
+		writer.WriteString("*0\r\n")
+	case "INCR":
+		if len(args) != 2 {
+			writeRespArgumentError(writer, command)
+			break
+		}
+		if !self.authorize(session, writer, auth.PERMISSION_ALLOCATE, args[1]) || !self.limit(session, writer, args[1], 1) {
+			break
+		}
+		id, err := self.manager.GetIdContext(self.ctx, args[1])
+		if err == nil && id == 0 {
+			err = errors.New("No id is available now!")
+		}
+		if err != nil {
+			writeRespError(writer, "ERR "+err.Error())
+			break
+		}
+		writeRespId(writer, id)
+	case "INCRBY":
+		if len(args) != 3 {
+			writeRespArgumentError(writer, command)
+			break
+		}
+		count, err := strconv.Atoi(args[2])
+		if err != nil || count <= 0 || count > manager.MAX_ID_COUNT {
+			writeRespError(writer, fmt.Sprintf("ERR the count must be an integer in [1, %d]", manager.MAX_ID_COUNT))
+			break
+		}
+		if !self.authorize(session, writer, auth.PERMISSION_ALLOCATE, args[1]) || !self.limit(session, writer, args[1], uint64(count)) {
+			break
+		}
+		ids, err := self.manager.GetIdsContext(self.ctx, args[1], count)
+		if err != nil {
+			writeRespError(writer, "ERR "+err.Error())
+			break
+		}
+		writer.WriteString("*" + strconv.Itoa(len(ids)) + "\r\n")
+		for _, id := range ids {
+			writeRespId(writer, id)
+		}
+	case "IDINFO":
+		if len(args) != 2 {
+			writeRespArgumentError(writer, command)
+			break
+		}
+		if !self.authorize(session, writer, auth.PERMISSION_ADMIN, args[1]) {
+			break
+		}
+		groupInfo, err := self.manager.GetGroupContext(self.ctx, args[1])
+		if err != nil {
+			writeRespError(writer, "ERR "+err.Error())
+			break
+		}
+		if groupInfo == nil {
+			writer.WriteString("*0\r\n")
+			break
+		}
+		fields := []string{
+			"name", groupInfo.Name,
+			"start", strconv.FormatUint(groupInfo.Start, 10),
+			"step", strconv.FormatUint(uint64(groupInfo.Step), 10),
+			"offset", strconv.FormatUint(groupInfo.Offset, 10),
+			"increment", strconv.FormatUint(uint64(groupInfo.Increment), 10),
+			"bound", strconv.FormatUint(groupInfo.Bound, 10),
+			"count", strconv.FormatUint(groupInfo.Count, 10),
+			"begin", strconv.FormatUint(groupInfo.Range.Begin, 10),
+			"end", strconv.FormatUint(groupInfo.Range.End, 10),
+			"last_modified", groupInfo.LastModified.Format("2006-01-02 15:04:05.000"),
+		}
+		writer.WriteString("*" + strconv.Itoa(len(fields)) + "\r\n")
+		for _, field := range fields {
+			writeRespBulk(writer, field)
+		}
+	default:
+		writeRespError(writer, fmt.Sprintf("ERR unknown command '%s'", args[0]))
+	}
+	return false
+}
+
+// readRespCommand reads a command in the form of RESP array of bulk strings, or an inline command.
+func readRespCommand(reader *bufio.Reader) ([]string, error) {
+	line, err := readRespLine(reader)
+	if err != nil {
+		return nil, err
+	}
+	if len(line) == 0 || line[0] != '*' {
+		return strings.Fields(line), nil
+	}
+	size, err := strconv.Atoi(line[1:])
+	if err != nil || size < 0 || size > RESP_MAX_ARRAY_SIZE {
+		return nil, fmt.Errorf("invalid multibulk length '%s'", line[1:])
+	}
+	args := make([]string, 0, size)
+	for i := 0; i < size; i++ {
+		line, err := readRespLine(reader)
+		if err != nil {
+			return nil, err
+		}
+		if len(line) == 0 || line[0] != '$' {
+			return nil, fmt.Errorf("expected '$', got '%s'", line)
+		}
+		length, err := strconv.Atoi(line[1:])
+		if err != nil || length < 0 || length > RESP_MAX_BULK_LENGTH {
+			return nil, fmt.Errorf("invalid bulk length '%s'", line[1:])
+		}
+		bulk := make([]byte, length+2)
+		if _, err := io.ReadFull(reader, bulk); err != nil {
+			return nil, err
+		}
+		if bulk[length] != '\r' || bulk[length+1] != '\n' {
+			return nil, errors.New("bulk string is not terminated by CRLF")
+		}
+		args = append(args, string(bulk[:length]))
+	}
+	return args, nil
+}
+
+// readRespLine reads a line of at most RESP_MAX_LINE_LENGTH bytes, which is read before the authentication.
+func readRespLine(reader *bufio.Reader) (string, error) {
+	var line []byte
+	for {
+		fragment, err := reader.ReadSlice('\n')
+		if len(line)+len(fragment) > RESP_MAX_LINE_LENGTH {
+			return "", fmt.Errorf("too big line (more than %d bytes)", RESP_MAX_LINE_LENGTH)
+		}
+		line = append(line, fragment...)
+		if err == bufio.ErrBufferFull {
+			continue
+		}
+		if err != nil {
+			if err == io.EOF && len(line) > 0 {
+				return "", io.ErrUnexpectedEOF
+			}
+			return "", err
+		}
+		return strings.TrimRight(string(line), "\r\n"), nil
+	}
+}
+
+// writeRespId writes the id as an integer, or as a bulk string if it exceeds the signed 64-bit integer of RESP.
+func writeRespId(writer *bufio.Writer, id uint64) {
+	if id > math.MaxInt64 {
+		writeRespBulk(writer, strconv.FormatUint(id, 10))
+		return
+	}
+	writer.WriteString(":" + strconv.FormatUint(id, 10) + "\r\n")
+}
+
+func writeRespBulk(writer *bufio.Writer, value string) {
+	writer.WriteString("$" + strconv.Itoa(len(value)) + "\r\n" + value + "\r\n")
+}
+
+func writeRespError(writer *bufio.Writer, message string) {
+	writer.WriteString("-" + strings.NewReplacer("\r", " ", "\n", " ").Replace(message) + "\r\n")
+}
+
+func writeRespArgumentError(writer *bufio.Writer, command string) {
+	writeRespError(writer, fmt.Sprintf("ERR wrong number of arguments for '%s' command", strings.ToLower(command)))
+}
@@ -0,0 +1,192 @@
+package frontend
+
+import (
+	"bufio"
+	"go_idcenter/auth"
+	"go_idcenter/ratelimit"
+	"net"
+	"strings"
+	"testing"
+)
+
+func TestRespFrontend(t *testing.T) {
+	idCenterManager, unregister := newTestManager(t, nil)
+	defer unregister()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Errorf("Listen error: %s", err)
+		t.FailNow()
+	}
+	frontend := NewRespFrontend(idCenterManager)
+	go frontend.Serve(listener)
+	defer frontend.Close()
+	conn, err := net.Dial("tcp", listener.Addr().String())
+	if err != nil {
+		t.Errorf("Dial error: %s", err)
+		t.FailNow()
+	}
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+
+	// The pipelined commands, in RESP and inline.
+	commands := "*2\r\n$4\r\nINCR\r\n$9\r\nresp_test\r\n" +
+		"*3\r\n$6\r\nincrby\r\n$9\r\nresp_test\r\n$1\r\n3\r\n" +
+		"PING\r\n" +
+		"INCR\r\n" +
+		"*2\r\n$6\r\nIDINFO\r\n$9\r\nresp_test\r\n" +
+		"UNKNOWN resp_test\r\n" +
+		"QUIT\r\n"
+	if _, err := conn.Write([]byte(commands)); err != nil {
+		t.Errorf("Write error: %s", err)
+		t.FailNow()
+	}
+	expectedLines := []string{
+		":1",
+		"*3", ":2", ":3", ":4",
+		"+PONG",
+		"-ERR wrong number of arguments for 'incr' command",
+		"*20", "$4", "name", "$9", "resp_test",
+	}
+	for _, expectedLine := range expectedLines {
+		line, err := readRespLine(reader)
+		if err != nil {
+			t.Errorf("Read error: %s", err)
+			t.FailNow()
+		}
+		if line != expectedLine {
+			t.Errorf("The reply line '%s' is not equals '%s'.", line, expectedLine)
+			t.FailNow()
+		}
+	}
+	for i := 0; i < 36; i++ {
+		readRespLine(reader)
+	}
+	for _, expectedLine := range []string{"-ERR unknown command 'UNKNOWN'", "+OK"} {
+		line, err := readRespLine(reader)
+		if err != nil || line != expectedLine {
+			t.Errorf("The reply line '%s' is not equals '%s'. (%v)", line, expectedLine, err)
+		}
+	}
+	if _, err := readRespLine(reader); err == nil {
+		t.Error("The connection is not closed after QUIT!")
+	}
+}
+
+func TestRespTooBigLine(t *testing.T) {
+	idCenterManager, unregister := newTestManager(t, nil)
+	defer unregister()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Errorf("Listen error: %s", err)
+		t.FailNow()
+	}
+	frontend := NewRespFrontend(idCenterManager)
+	go frontend.Serve(listener)
+	defer frontend.Close()
+	conn, err := net.Dial("tcp", listener.Addr().String())
+	if err != nil {
+		t.Errorf("Dial error: %s", err)
+		t.FailNow()
+	}
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	go conn.Write([]byte("INCR " + strings.Repeat("a", RESP_MAX_LINE_LENGTH) + "\r\n"))
+	line, err := readRespLine(reader)
+	if err != nil || !strings.HasPrefix(line, "-ERR Protocol error: too big line") {
+		t.Errorf("The reply line '%s' is not the error of too big line. (%v)", line, err)
+		t.FailNow()
+	}
+	if _, err := readRespLine(reader); err == nil {
+		t.Error("The connection is not closed after the too big line!")
+	}
+}
+
+func TestRespAuthorization(t *testing.T) {
+	idCenterManager, unregister := newTestManager(t, nil)
+	defer unregister()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Errorf("Listen error: %s", err)
+		t.FailNow()
+	}
+	frontend := NewRespFrontend(idCenterManager)
+	frontend.Authenticator = auth.NewAuthenticator()
+	frontend.Authenticator.AddClient("orders", "allocate-token-0123456789", auth.ROLE_ALLOCATE, []string{"order"})
+	go frontend.Serve(listener)
+	defer frontend.Close()
+	conn, err := net.Dial("tcp", listener.Addr().String())
+	if err != nil {
+		t.Errorf("Dial error: %s", err)
+		t.FailNow()
+	}
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	commands := "INCR order\r\n" +
+		"AUTH unknown-token-0123456789\r\n" +
+		"INCR order\r\n" +
+		"AUTH default allocate-token-0123456789\r\n" +
+		"INCR order\r\n" +
+		"INCR other\r\n" +
+		"IDINFO order\r\n" +
+		"PING\r\n"
+	if _, err := conn.Write([]byte(commands)); err != nil {
+		t.Errorf("Write error: %s", err)
+		t.FailNow()
+	}
+	expectedLines := []string{
+		"-NOAUTH Authentication required.",
+		"-WRONGPASS invalid username-password pair or user is disabled.",
+		"-NOAUTH Authentication required.",
+		"+OK",
+		":1",
+		"-NOPERM The allocate operation on group 'other' is not allowed!",
+		"-NOPERM The admin operation on group 'order' is not allowed!",
+		"+PONG",
+	}
+	for _, expectedLine := range expectedLines {
+		line, err := readRespLine(reader)
+		if err != nil || line != expectedLine {
+			t.Errorf("The reply line '%s' is not equals '%s'. (%v)", line, expectedLine, err)
+			t.FailNow()
+		}
+	}
+}
+
+func TestRespRateLimit(t *testing.T) {
+	idCenterManager, unregister := newTestManager(t, nil)
+	defer unregister()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Errorf("Listen error: %s", err)
+		t.FailNow()
+	}
+	frontend := NewRespFrontend(idCenterManager)
+	frontend.RateLimiter = ratelimit.NewLimiter()
+	frontend.RateLimiter.SetLimit(ratelimit.KIND_GROUP, "limited", ratelimit.Limit{Rate: 1, Burst: 8})
+	go frontend.Serve(listener)
+	defer frontend.Close()
+	conn, err := net.Dial("tcp", listener.Addr().String())
+	if err != nil {
+		t.Errorf("Dial error: %s", err)
+		t.FailNow()
+	}
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	// The batch is charged by its count of ids.
+	if _, err := conn.Write([]byte("INCRBY limited 5\r\nINCRBY limited 5\r\nINCR limited\r\n")); err != nil {
+		t.Errorf("Write error: %s", err)
+		t.FailNow()
+	}
+	expectedLines := []string{
+		"*5", ":1", ":2", ":3", ":4", ":5",
+		"-ERR Too many requests! Retry after 2 seconds.",
+		":6",
+	}
+	for _, expectedLine := range expectedLines {
+		line, err := readRespLine(reader)
+		if err != nil || line != expectedLine {
+			t.Errorf("The reply line '%s' is not equals '%s'. (%v)", line, expectedLine, err)
+			t.FailNow()
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package frontend
+
+import (
+	"errors"
+	"fmt"
+	"go_idcenter/base"
+	"net"
+	"os"
+	"time"
+)
+
+const (
+	DEFAULT_SOCKET_MODE = 0660
+)
+
+// ListenUnix listens on the unix domain socket of path, and sets the permissions of socket file to mode.
+//
+// The socket file is created with the permissions of mode at most (see listenUnix), so that
+// no one else can connect between listening and setting the mode.
+//
+// The stale socket file, which is left by the process exited abnormally, is removed
+// before listening. But the socket which is still served by another process is not.
+func ListenUnix(path string, mode os.FileMode) (net.Listener, error) {
+	if err := removeStaleSocket(path); err != nil {
+		return nil, err
+	}
+	listener, err := listenUnix(path, mode)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(path, mode); err != nil {
+		listener.Close()
+		return nil, err
+	}
+	return listener, nil
+}
+
+func removeStaleSocket(path string) error {
+	fileInfo, err := os.Lstat(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if fileInfo.Mode()&os.ModeSocket == 0 {
+		errorMsg := fmt.Sprintf("The file '%s' exists and is not a socket!", path)
+		base.Logger().Errorln(errorMsg)
+		return errors.New(errorMsg)
+	}
+	conn, err := net.DialTimeout("unix", path, time.Second)
+	if err == nil {
+		conn.Close()
+		errorMsg := fmt.Sprintf("The socket '%s' is in use by another process!", path)
+		base.Logger().Errorln(errorMsg)
+		return errors.New(errorMsg)
+	}
+	base.Logger().Warnf("Removing the stale socket '%s'...\n", path)
+	return os.Remove(path)
+}
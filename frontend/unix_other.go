@@ -0,0 +1,14 @@
+//go:build !unix
+
+package frontend
+
+import (
+	"net"
+	"os"
+)
+
+// listenUnix creates the socket file of path, whose permissions are set by the caller, because
+// there is no umask on this platform.
+func listenUnix(path string, mode os.FileMode) (net.Listener, error) {
+	return net.Listen("unix", path)
+}
@@ -0,0 +1,42 @@
+package frontend
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestListenUnix(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "idcenter.sock")
+	listener, err := ListenUnix(path, 0600)
+	if err != nil {
+		t.Errorf("Listen error: %s", err)
+		t.FailNow()
+	}
+	fileInfo, err := os.Stat(path)
+	if err != nil || fileInfo.Mode().Perm() != 0600 {
+		t.Errorf("The socket file %v (err=%v) should have the mode 0600.", fileInfo, err)
+		t.FailNow()
+	}
+	if _, err := ListenUnix(path, 0600); err == nil {
+		t.Errorf("The socket in use should not be removed.")
+		t.FailNow()
+	}
+	// Leave a stale socket file like the process exited abnormally.
+	listener.(*net.UnixListener).SetUnlinkOnClose(false)
+	listener.Close()
+	listener, err = ListenUnix(path, 0660)
+	if err != nil {
+		t.Errorf("The stale socket should be removed, but: %s", err)
+		t.FailNow()
+	}
+	listener.Close()
+
+	regularPath := filepath.Join(t.TempDir(), "regular")
+	os.WriteFile(regularPath, nil, 0644)
+	if _, err := ListenUnix(regularPath, 0660); err == nil {
+		t.Errorf("The regular file should not be removed.")
+		t.FailNow()
+	}
+}
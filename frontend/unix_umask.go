@@ -0,0 +1,23 @@
+//go:build unix
+
+package frontend
+
+import (
+	"net"
+	"os"
+	"sync"
+	"syscall"
+)
+
+// The umask is of the process, so the listeners which change it are serialized.
+var umaskSign sync.Mutex
+
+// listenUnix creates the socket file of path under the umask which clears the permissions
+// not in mode, so that the socket is never accessible by the others before its mode is set.
+func listenUnix(path string, mode os.FileMode) (net.Listener, error) {
+	umaskSign.Lock()
+	defer umaskSign.Unlock()
+	oldMask := syscall.Umask(int(^mode.Perm() & os.ModePerm))
+	defer syscall.Umask(oldMask)
+	return net.Listen("unix", path)
+}
@@ -0,0 +1,438 @@
+package frontend
+
+import (
+	"encoding/json"
+	"fmt"
+	"go_idcenter/auth"
+	"go_idcenter/base"
+	"go_idcenter/manager"
+	"go_idcenter/ratelimit"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	V1_PREFIX = "/v1/"
+)
+
+const (
+	ERROR_CODE_INVALID_REQUEST    = "invalid_request"
+	ERROR_CODE_METHOD_NOT_ALLOWED = "method_not_allowed"
+	ERROR_CODE_GROUP_NOT_FOUND    = "group_not_found"
+	ERROR_CODE_CONFLICT           = "conflict"
+)
+
+type GroupResponse struct {
+	Name         string    `json:"name"`
+	Start        uint64    `json:"start"`
+	Step         uint32    `json:"step"`
+	Offset       uint64    `json:"offset"`
+	Increment    uint32    `json:"increment"`
+	Bound        uint64    `json:"bound"`
+	Count        uint64    `json:"count"`
+	Begin        uint64    `json:"begin"`
+	End          uint64    `json:"end"`
+	LastModified time.Time `json:"last_modified"`
+}
+
+type GroupListResponse struct {
+	Groups []GroupResponse `json:"groups"`
+}
+
+type IdsResponse struct {
+	Group string `json:"group"`
+	Ids   IdList `json:"ids"`
+}
+
+// The ids which are encoded in an array of json strings, like the id of IdResponse.
+type IdList []uint64
+
+func (self IdList) MarshalJSON() ([]byte, error) {
+	literals := make([]string, len(self))
+	for i, id := range self {
+		literals[i] = strconv.FormatUint(id, 10)
+	}
+	return json.Marshal(literals)
+}
+
+func (self *IdList) UnmarshalJSON(data []byte) error {
+	var literals []string
+	if err := json.Unmarshal(data, &literals); err != nil {
+		return err
+	}
+	ids := make(IdList, len(literals))
+	for i, literal := range literals {
+		id, err := strconv.ParseUint(literal, 10, 64)
+		if err != nil {
+			return fmt.Errorf("The id '%s' is INVALID!", literal)
+		}
+		ids[i] = id
+	}
+	*self = ids
+	return nil
+}
+
+type RangeResponse struct {
+	Group     string `json:"group"`
+	Begin     uint64 `json:"begin"`
+	End       uint64 `json:"end"`
+	Increment uint32 `json:"increment"`
+}
+
+type CreateGroupRequest struct {
+	Name  string `json:"name"`
+	Start uint64 `json:"start"`
+	Step  uint32 `json:"step"`
+}
+
+// The absent field of update request keeps the current value.
+type UpdateGroupRequest struct {
+	Step  *uint32 `json:"step"`
+	Bound *uint64 `json:"bound"`
+}
+
+type IdsRequest struct {
+	Count int `json:"count"`
+}
+
+type RangeRequest struct {
+	Size uint64 `json:"size"`
+}
+
+type ForwardRequest struct {
+	Next uint64 `json:"next"`
+}
+
+type LimitListResponse struct {
+	Limits []ratelimit.Usage `json:"limits"`
+}
+
+func newGroupResponse(groupInfo *base.GroupInfo) GroupResponse {
+	return GroupResponse{
+		Name:         groupInfo.Name,
+		Start:        groupInfo.Start,
+		Step:         groupInfo.Step,
+		Offset:       groupInfo.Offset,
+		Increment:    groupInfo.Increment,
+		Bound:        groupInfo.Bound,
+		Count:        groupInfo.Count,
+		Begin:        groupInfo.Range.Begin,
+		End:          groupInfo.Range.End,
+		LastModified: groupInfo.LastModified,
+	}
+}
+
+// doForV1 routes the requests of the versioned api:
+//
+//	GET    /v1/groups                list groups
+//	POST   /v1/groups                create group
+//	GET    /v1/groups/<name>         read group
+//	PATCH  /v1/groups/<name>         update the step and the bound of group
+//	DELETE /v1/groups/<name>         delete (clear) group
+//	POST   /v1/groups/<name>/ids     fetch ids
+//	POST   /v1/groups/<name>/ranges  reserve range
+//	POST   /v1/groups/<name>/forward move group forward, so that the later ids are not less than next
+//	GET    /v1/limits                the current usage of rate limits
+//
+// If the authentication is enabled, fetching ids and reserving ranges need the allocate permission
+// on the group, listing groups and limits needs the admin permission on all groups, and the others
+// need the admin one. Fetching ids and reserving ranges are rate limited.
+func (self *HttpFrontend) doForV1(w http.ResponseWriter, r *http.Request) {
+	base.Log(base.SUBSYSTEM_FRONTEND).DebugContext(r.Context(), "Receive a v1 request.", "method", r.Method, base.LOG_FIELD_PATH, r.URL.Path)
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, V1_PREFIX), "/"), "/")
+	if parts[0] == "limits" && len(parts) == 1 {
+		if r.Method != "GET" {
+			writeMethodNotAllowed(w, r, "GET")
+			return
+		}
+		if !self.authorize(w, r, auth.PERMISSION_ADMIN, auth.ALL_GROUPS) {
+			return
+		}
+		self.listLimits(w, r)
+		return
+	}
+	if parts[0] != "groups" || len(parts) > 3 {
+		self.doForNotFound(w, r)
+		return
+	}
+	if len(parts) == 1 {
+		switch r.Method {
+		case "GET":
+			if !self.authorize(w, r, auth.PERMISSION_ADMIN, auth.ALL_GROUPS) {
+				return
+			}
+			self.listGroups(w, r)
+		case "POST":
+			self.createGroup(w, r)
+		default:
+			writeMethodNotAllowed(w, r, "GET, POST")
+		}
+		return
+	}
+	group := parts[1]
+	if len(group) == 0 {
+		writeError(w, r, &HttpError{http.StatusBadRequest, ERROR_CODE_INVALID_GROUP, "The group name is empty!"})
+		return
+	}
+	permission := auth.PERMISSION_ADMIN
+	if len(parts) == 3 && (parts[2] == "ids" || parts[2] == "ranges") {
+		permission = auth.PERMISSION_ALLOCATE
+	}
+	if !self.authorize(w, r, permission, group) {
+		return
+	}
+	if len(parts) == 2 {
+		switch r.Method {
+		case "GET":
+			self.getGroup(w, r, group)
+		case "PATCH":
+			self.updateGroup(w, r, group)
+		case "DELETE":
+			self.deleteGroup(w, r, group)
+		default:
+			writeMethodNotAllowed(w, r, "GET, PATCH, DELETE")
+		}
+		return
+	}
+	switch parts[2] {
+	case "ids":
+		if r.Method != "POST" {
+			writeMethodNotAllowed(w, r, "POST")
+			return
+		}
+		self.fetchIds(w, r, group)
+	case "ranges":
+		if r.Method != "POST" {
+			writeMethodNotAllowed(w, r, "POST")
+			return
+		}
+		self.reserveRange(w, r, group)
+	case "forward":
+		if r.Method != "POST" {
+			writeMethodNotAllowed(w, r, "POST")
+			return
+		}
+		self.forwardGroup(w, r, group)
+	default:
+		self.doForNotFound(w, r)
+	}
+}
+
+func (self *HttpFrontend) listGroups(w http.ResponseWriter, r *http.Request) {
+	groupInfos, err := self.manager.ListGroupsContext(r.Context())
+	if err != nil {
+		writeError(w, r, toHttpError(r, err, "List groups error"))
+		return
+	}
+	groups := make([]GroupResponse, 0, len(groupInfos))
+	for i := range groupInfos {
+		groups = append(groups, newGroupResponse(&groupInfos[i]))
+	}
+	writeJson(w, r, http.StatusOK, GroupListResponse{Groups: groups})
+}
+
+func (self *HttpFrontend) createGroup(w http.ResponseWriter, r *http.Request) {
+	var request CreateGroupRequest
+	if httpErr := readJson(r, &request); httpErr != nil {
+		writeError(w, r, httpErr)
+		return
+	}
+	if len(request.Name) == 0 || strings.Contains(request.Name, "/") {
+		writeError(w, r, &HttpError{http.StatusBadRequest, ERROR_CODE_INVALID_GROUP, fmt.Sprintf("The group name '%s' is INVALID!", request.Name)})
+		return
+	}
+	if !self.authorize(w, r, auth.PERMISSION_ADMIN, request.Name) {
+		return
+	}
+	ok, err := self.manager.CreateGroupContext(r.Context(), request.Name, request.Start, request.Step)
+	if err != nil {
+		writeError(w, r, toHttpError(r, err, "Create group error"))
+		return
+	}
+	if !ok {
+		writeError(w, r, &HttpError{http.StatusConflict, ERROR_CODE_CONFLICT, fmt.Sprintf("The group '%s' already exists!", request.Name)})
+		return
+	}
+	groupInfo, err := self.manager.GetGroupContext(r.Context(), request.Name)
+	if err != nil {
+		writeError(w, r, toHttpError(r, err, "Get group error"))
+		return
+	}
+	if groupInfo == nil {
+		writeGroupNotFound(w, r, request.Name)
+		return
+	}
+	writeJson(w, r, http.StatusCreated, newGroupResponse(groupInfo))
+}
+
+func (self *HttpFrontend) getGroup(w http.ResponseWriter, r *http.Request, group string) {
+	groupInfo, err := self.manager.GetGroupContext(r.Context(), group)
+	if err != nil {
+		writeError(w, r, toHttpError(r, err, "Get group error"))
+		return
+	}
+	if groupInfo == nil {
+		writeGroupNotFound(w, r, group)
+		return
+	}
+	writeJson(w, r, http.StatusOK, newGroupResponse(groupInfo))
+}
+
+func (self *HttpFrontend) updateGroup(w http.ResponseWriter, r *http.Request, group string) {
+	var request UpdateGroupRequest
+	if httpErr := readJson(r, &request); httpErr != nil {
+		writeError(w, r, httpErr)
+		return
+	}
+	groupInfo, err := self.manager.GetGroupContext(r.Context(), group)
+	if err != nil {
+		writeError(w, r, toHttpError(r, err, "Get group error"))
+		return
+	}
+	if groupInfo == nil {
+		writeGroupNotFound(w, r, group)
+		return
+	}
+	step, bound := groupInfo.Step, groupInfo.Bound
+	if request.Step != nil {
+		step = *request.Step
+	}
+	if request.Bound != nil {
+		bound = *request.Bound
+	}
+	if step == 0 {
+		writeError(w, r, &HttpError{http.StatusBadRequest, ERROR_CODE_INVALID_REQUEST, "The step must be positive!"})
+		return
+	}
+	ok, err := self.manager.UpdateGroupContext(r.Context(), group, step, bound)
+	if err != nil {
+		writeError(w, r, toHttpError(r, err, "Update group error"))
+		return
+	}
+	if ok {
+		groupInfo, err = self.manager.GetGroupContext(r.Context(), group)
+		if err != nil {
+			writeError(w, r, toHttpError(r, err, "Get group error"))
+			return
+		}
+	}
+	if groupInfo == nil {
+		writeGroupNotFound(w, r, group)
+		return
+	}
+	writeJson(w, r, http.StatusOK, newGroupResponse(groupInfo))
+}
+
+func (self *HttpFrontend) deleteGroup(w http.ResponseWriter, r *http.Request, group string) {
+	result, err := self.manager.ClearContext(r.Context(), group)
+	if err != nil {
+		writeError(w, r, toHttpError(r, err, "Clear id group error"))
+		return
+	}
+	writeJson(w, r, http.StatusOK, ClearResponse{Group: group, Cleared: result})
+}
+
+func (self *HttpFrontend) fetchIds(w http.ResponseWriter, r *http.Request, group string) {
+	request := IdsRequest{Count: 1}
+	if httpErr := readJson(r, &request); httpErr != nil {
+		writeError(w, r, httpErr)
+		return
+	}
+	if request.Count <= 0 || request.Count > manager.MAX_ID_COUNT {
+		writeError(w, r, &HttpError{http.StatusBadRequest, ERROR_CODE_INVALID_REQUEST, fmt.Sprintf("The count must be in [1, %d]!", manager.MAX_ID_COUNT)})
+		return
+	}
+	if !self.limit(w, r, group, uint64(request.Count)) {
+		return
+	}
+	ids, err := self.manager.GetIdsContext(r.Context(), group, request.Count)
+	if err != nil {
+		writeError(w, r, toHttpError(r, err, "Get ids error"))
+		return
+	}
+	writeJson(w, r, http.StatusOK, IdsResponse{Group: group, Ids: ids})
+}
+
+func (self *HttpFrontend) reserveRange(w http.ResponseWriter, r *http.Request, group string) {
+	var request RangeRequest
+	if httpErr := readJson(r, &request); httpErr != nil {
+		writeError(w, r, httpErr)
+		return
+	}
+	if request.Size == 0 {
+		writeError(w, r, &HttpError{http.StatusBadRequest, ERROR_CODE_INVALID_REQUEST, "The size must be positive!"})
+		return
+	}
+	if !self.limit(w, r, group, request.Size) {
+		return
+	}
+	idRange, err := self.manager.ReserveRangeContext(r.Context(), group, request.Size)
+	if err == nil && idRange == nil {
+		err = &HttpError{http.StatusServiceUnavailable, ERROR_CODE_UNAVAILABLE, "No range is available now!"}
+	}
+	if err != nil {
+		writeError(w, r, toHttpError(r, err, "Reserve range error"))
+		return
+	}
+	writeJson(w, r, http.StatusOK, RangeResponse{Group: group, Begin: idRange.Begin, End: idRange.End, Increment: idRange.Increment})
+}
+
+func (self *HttpFrontend) forwardGroup(w http.ResponseWriter, r *http.Request, group string) {
+	var request ForwardRequest
+	if httpErr := readJson(r, &request); httpErr != nil {
+		writeError(w, r, httpErr)
+		return
+	}
+	if request.Next == 0 {
+		writeError(w, r, &HttpError{http.StatusBadRequest, ERROR_CODE_INVALID_REQUEST, "The next id must be positive!"})
+		return
+	}
+	ok, err := self.manager.ForwardGroupContext(r.Context(), group, request.Next)
+	if err != nil {
+		writeError(w, r, toHttpError(r, err, "Forward group error"))
+		return
+	}
+	if !ok {
+		writeGroupNotFound(w, r, group)
+		return
+	}
+	self.getGroup(w, r, group)
+}
+
+func (self *HttpFrontend) listLimits(w http.ResponseWriter, r *http.Request) {
+	limits := []ratelimit.Usage{}
+	if self.RateLimiter != nil {
+		limits = self.RateLimiter.Usage()
+	}
+	writeJson(w, r, http.StatusOK, LimitListResponse{Limits: limits})
+}
+
+// readJson decodes the json body of request into v. The empty body keeps v unchanged.
+func readJson(r *http.Request, v interface{}) *HttpError {
+	decoder := json.NewDecoder(r.Body)
+	if err := decoder.Decode(v); err != nil && err != io.EOF {
+		return &HttpError{http.StatusBadRequest, ERROR_CODE_INVALID_REQUEST, fmt.Sprintf("The request body is INVALID! (%s)", err)}
+	}
+	return nil
+}
+
+func writeJson(w http.ResponseWriter, r *http.Request, status int, content interface{}) {
+	body, err := json.Marshal(content)
+	if err != nil {
+		writeError(w, r, toHttpError(r, err, "Json marshalling error"))
+		return
+	}
+	writeBody(w, status, "application/json", body)
+}
+
+func writeMethodNotAllowed(w http.ResponseWriter, r *http.Request, allowedMethods string) {
+	w.Header().Set("Allow", allowedMethods)
+	writeError(w, r, &HttpError{http.StatusMethodNotAllowed, ERROR_CODE_METHOD_NOT_ALLOWED, fmt.Sprintf("The method '%s' is not allowed!", r.Method)})
+}
+
+func writeGroupNotFound(w http.ResponseWriter, r *http.Request, group string) {
+	writeError(w, r, &HttpError{http.StatusNotFound, ERROR_CODE_GROUP_NOT_FOUND, fmt.Sprintf("The group '%s' is not found!", group)})
+}
@@ -0,0 +1,109 @@
+package frontend
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func doJsonRequest(handler http.Handler, method string, url string, body string, v interface{}) *httptest.ResponseRecorder {
+	request := httptest.NewRequest(method, url, strings.NewReader(body))
+	request.Header.Set("Content-Type", "application/json")
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, request)
+	if v != nil {
+		json.Unmarshal(recorder.Body.Bytes(), v)
+	}
+	return recorder
+}
+
+func TestV1Api(t *testing.T) {
+	idCenterManager, unregister := newTestManager(t, nil)
+	defer unregister()
+	frontend := NewHttpFrontend(idCenterManager)
+	group := "v1_api_test"
+
+	// Create & Read
+	var groupResponse GroupResponse
+	recorder := doJsonRequest(frontend, "POST", "/v1/groups", `{"name":"v1_api_test","start":100,"step":5}`, &groupResponse)
+	if recorder.Code != http.StatusCreated || groupResponse.Name != group || groupResponse.Start != 100 || groupResponse.Step != 5 {
+		t.Errorf("Unexpected response (status=%d, body=%s).", recorder.Code, recorder.Body)
+		t.FailNow()
+	}
+	recorder = doJsonRequest(frontend, "POST", "/v1/groups", `{"name":"v1_api_test"}`, nil)
+	if recorder.Code != http.StatusConflict {
+		t.Errorf("Unexpected response (status=%d, body=%s).", recorder.Code, recorder.Body)
+	}
+	recorder = doJsonRequest(frontend, "GET", "/v1/groups/"+group, "", &groupResponse)
+	if recorder.Code != http.StatusOK || groupResponse.Name != group {
+		t.Errorf("Unexpected response (status=%d, body=%s).", recorder.Code, recorder.Body)
+	}
+	recorder = doJsonRequest(frontend, "GET", "/v1/groups/v1_api_test_nonexistent", "", nil)
+	if recorder.Code != http.StatusNotFound {
+		t.Errorf("Unexpected response (status=%d, body=%s).", recorder.Code, recorder.Body)
+	}
+	var groupListResponse GroupListResponse
+	recorder = doJsonRequest(frontend, "GET", "/v1/groups", "", &groupListResponse)
+	if recorder.Code != http.StatusOK || len(groupListResponse.Groups) != 1 {
+		t.Errorf("Unexpected response (status=%d, body=%s).", recorder.Code, recorder.Body)
+	}
+
+	// Fetch ids & Reserve range
+	var idsResponse IdsResponse
+	recorder = doJsonRequest(frontend, "POST", "/v1/groups/"+group+"/ids", `{"count":3}`, &idsResponse)
+	if recorder.Code != http.StatusOK || len(idsResponse.Ids) != 3 || idsResponse.Ids[0] != 100 || idsResponse.Ids[2] != 102 {
+		t.Errorf("Unexpected response (status=%d, body=%s).", recorder.Code, recorder.Body)
+	}
+	if !strings.Contains(recorder.Body.String(), `"ids":["100","101","102"]`) {
+		t.Errorf("The ids should be in json strings: %s", recorder.Body)
+	}
+	var rangeResponse RangeResponse
+	recorder = doJsonRequest(frontend, "POST", "/v1/groups/"+group+"/ranges", `{"size":10}`, &rangeResponse)
+	if recorder.Code != http.StatusOK || rangeResponse.Begin != 105 || rangeResponse.End != 115 {
+		t.Errorf("Unexpected response (status=%d, body=%s).", recorder.Code, recorder.Body)
+	}
+	recorder = doJsonRequest(frontend, "GET", "/v1/groups/"+group+"/ids", "", nil)
+	if recorder.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Unexpected response (status=%d, body=%s).", recorder.Code, recorder.Body)
+	}
+
+	// Update
+	recorder = doJsonRequest(frontend, "PATCH", "/v1/groups/"+group, `{"bound":1000}`, &groupResponse)
+	if recorder.Code != http.StatusOK || groupResponse.Bound != 1000 || groupResponse.Step != 5 {
+		t.Errorf("Unexpected response (status=%d, body=%s).", recorder.Code, recorder.Body)
+	}
+	recorder = doJsonRequest(frontend, "PATCH", "/v1/groups/"+group, `{"step":0}`, nil)
+	if recorder.Code != http.StatusBadRequest {
+		t.Errorf("Unexpected response (status=%d, body=%s).", recorder.Code, recorder.Body)
+	}
+
+	// Forward
+	recorder = doJsonRequest(frontend, "POST", "/v1/groups/"+group+"/forward", `{"next":500}`, nil)
+	if recorder.Code != http.StatusOK {
+		t.Errorf("Unexpected response (status=%d, body=%s).", recorder.Code, recorder.Body)
+	}
+	recorder = doJsonRequest(frontend, "POST", "/v1/groups/"+group+"/ids", `{"count":1}`, &idsResponse)
+	if recorder.Code != http.StatusOK || len(idsResponse.Ids) != 1 || idsResponse.Ids[0] != 500 {
+		t.Errorf("Unexpected response (status=%d, body=%s).", recorder.Code, recorder.Body)
+	}
+	recorder = doJsonRequest(frontend, "POST", "/v1/groups/v1_api_test_nonexistent/forward", `{"next":500}`, nil)
+	if recorder.Code != http.StatusNotFound {
+		t.Errorf("Unexpected response (status=%d, body=%s).", recorder.Code, recorder.Body)
+	}
+
+	// Delete
+	recorder = doJsonRequest(frontend, "GET", "/v1/groups/"+group+"/unknown", "", nil)
+	if recorder.Code != http.StatusNotFound {
+		t.Errorf("Unexpected response (status=%d, body=%s).", recorder.Code, recorder.Body)
+	}
+	recorder = doJsonRequest(frontend, "DELETE", "/v1/groups/"+group, "", nil)
+	if recorder.Code != http.StatusOK {
+		t.Errorf("Unexpected response (status=%d, body=%s).", recorder.Code, recorder.Body)
+	}
+	recorder = doJsonRequest(frontend, "GET", "/v1/groups/"+group, "", nil)
+	if recorder.Code != http.StatusNotFound {
+		t.Errorf("Unexpected response (status=%d, body=%s).", recorder.Code, recorder.Body)
+	}
+}
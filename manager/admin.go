@@ -0,0 +1,172 @@
+package manager
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go_idcenter/base"
+)
+
+const (
+	MAX_ID_COUNT = 1000
+)
+
+// CreateGroup builds the info of group. The result is false if the group already exists.
+func (self *IdCenterManager) CreateGroup(group string, start uint64, step uint32) (bool, error) {
+	return self.CreateGroupContext(context.Background(), group, start, step)
+}
+
+// CreateGroupContext is CreateGroup which gives up when ctx is done.
+func (self *IdCenterManager) CreateGroupContext(ctx context.Context, group string, start uint64, step uint32) (ok bool, err error) {
+	defer recoverPanic(base.OP_BUILD_INFO, group, &err)
+	if len(group) == 0 {
+		return false, base.NewError(base.ErrInvalidGroupName, base.OP_BUILD_INFO, group, nil)
+	}
+	group = self.resolveGroup(group)
+	storageProvider, err := self.getStorageProvider(base.OP_BUILD_INFO, group)
+	if err != nil {
+		return false, err
+	}
+	return self.buildGroup(ctx, storageProviderContext(storageProvider), group, start, step)
+}
+
+// GetGroup returns the info of group, or nil if the group does not exist.
+func (self *IdCenterManager) GetGroup(group string) (*base.GroupInfo, error) {
+	return self.GetGroupContext(context.Background(), group)
+}
+
+// GetGroupContext is GetGroup which gives up when ctx is done.
+func (self *IdCenterManager) GetGroupContext(ctx context.Context, group string) (groupInfo *base.GroupInfo, err error) {
+	defer recoverPanic(base.OP_GET, group, &err)
+	if len(group) == 0 {
+		return nil, base.NewError(base.ErrInvalidGroupName, base.OP_GET, group, nil)
+	}
+	group = self.resolveGroup(group)
+	storageProvider, err := self.getStorageProvider(base.OP_GET, group)
+	if err != nil {
+		return nil, err
+	}
+	return storageProviderContext(storageProvider).GetContext(ctx, group)
+}
+
+func (self *IdCenterManager) ListGroups() ([]base.GroupInfo, error) {
+	return self.ListGroupsContext(context.Background())
+}
+
+// ListGroupsContext is ListGroups which gives up when ctx is done.
+func (self *IdCenterManager) ListGroupsContext(ctx context.Context) (groupInfos []base.GroupInfo, err error) {
+	defer recoverPanic(base.OP_LIST, "", &err)
+	storageProvider, err := self.getStorageProvider(base.OP_LIST, "")
+	if err != nil {
+		return nil, err
+	}
+	return storageProviderContext(storageProvider).ListContext(ctx)
+}
+
+// UpdateGroup changes the step and the bound of group. The result is false if the group does not exist.
+func (self *IdCenterManager) UpdateGroup(group string, step uint32, bound uint64) (bool, error) {
+	return self.UpdateGroupContext(context.Background(), group, step, bound)
+}
+
+// UpdateGroupContext is UpdateGroup which gives up when ctx is done.
+func (self *IdCenterManager) UpdateGroupContext(ctx context.Context, group string, step uint32, bound uint64) (ok bool, err error) {
+	defer recoverPanic(base.OP_UPDATE, group, &err)
+	if len(group) == 0 {
+		return false, base.NewError(base.ErrInvalidGroupName, base.OP_UPDATE, group, nil)
+	}
+	group = self.resolveGroup(group)
+	storageProvider, err := self.getStorageProvider(base.OP_UPDATE, group)
+	if err != nil {
+		return false, err
+	}
+	return storageProviderContext(storageProvider).UpdateContext(ctx, group, step, bound)
+}
+
+// ForwardGroup moves the group forward, so that the ids got later are not less than next.
+// The cached ids are dropped. The result is false if the group does not exist.
+func (self *IdCenterManager) ForwardGroup(group string, next uint64) (bool, error) {
+	return self.ForwardGroupContext(context.Background(), group, next)
+}
+
+// ForwardGroupContext is ForwardGroup which gives up when ctx is done.
+func (self *IdCenterManager) ForwardGroupContext(ctx context.Context, group string, next uint64) (ok bool, err error) {
+	defer recoverPanic(base.OP_FORWARD, group, &err)
+	if len(group) == 0 {
+		return false, base.NewError(base.ErrInvalidGroupName, base.OP_FORWARD, group, nil)
+	}
+	group = self.resolveGroup(group)
+	storageProvider, err := self.getStorageProvider(base.OP_FORWARD, group)
+	if err != nil {
+		return false, err
+	}
+	cacheProvider, err := self.getCacheProvider(base.OP_FORWARD, group)
+	if err != nil {
+		return false, err
+	}
+	ok, err = storageProviderContext(storageProvider).ForwardContext(ctx, group, next)
+	if err != nil || !ok {
+		return ok, err
+	}
+	if _, err := cacheProviderContext(cacheProvider).ClearContext(ctx, group); err != nil {
+		return true, err
+	}
+	return true, nil
+}
+
+// ReserveRange reserves a range of size ids of group. The ids in range are neither cached nor obfuscated.
+func (self *IdCenterManager) ReserveRange(group string, size uint64) (*base.IdRange, error) {
+	return self.ReserveRangeContext(context.Background(), group, size)
+}
+
+// ReserveRangeContext is ReserveRange which gives up when ctx is done.
+func (self *IdCenterManager) ReserveRangeContext(ctx context.Context, group string, size uint64) (idRange *base.IdRange, err error) {
+	defer recoverPanic(base.OP_RESERVE, group, &err)
+	if len(group) == 0 {
+		return nil, base.NewError(base.ErrInvalidGroupName, base.OP_RESERVE, group, nil)
+	}
+	// The issued ids of the obfuscated or check-digit group are not the raw ones, so a raw range could collide with them.
+	if groupConfig := self.Groups[group]; groupConfig.Obfuscator != nil || groupConfig.CheckDigitScheme != nil {
+		errorMsg := fmt.Sprintf("IdCenter: The ids of group '%s' are obfuscated or carry a check digit, so its raw range can not be reserved!", group)
+		return nil, base.NewError(base.ErrConflict, base.OP_RESERVE, group, errors.New(errorMsg))
+	}
+	if !self.enter() {
+		return nil, base.NewError(base.ErrShutDown, base.OP_RESERVE, group, nil)
+	}
+	defer self.inFlight.Done()
+	group = self.resolveGroup(group)
+	sp, err := self.getStorageProvider(base.OP_RESERVE, group)
+	if err != nil {
+		return nil, err
+	}
+	storageProvider := storageProviderContext(sp)
+	if err := self.ensureGroup(ctx, storageProvider, group); err != nil {
+		return nil, err
+	}
+	offset, increment := self.alignment()
+	return storageProvider.ReserveContext(ctx, group, size, offset, increment)
+}
+
+// GetIds returns count ids of group, which are same as the ones got by GetId.
+func (self *IdCenterManager) GetIds(group string, count int) ([]uint64, error) {
+	return self.GetIdsContext(context.Background(), group, count)
+}
+
+// GetIdsContext is GetIds which gives up when ctx is done, and whose logs carry the request id of ctx.
+func (self *IdCenterManager) GetIdsContext(ctx context.Context, group string, count int) ([]uint64, error) {
+	if count <= 0 || count > MAX_ID_COUNT {
+		errorMsg := fmt.Sprintf("IdCenter: The count '%d' is not in [1, %d]!", count, MAX_ID_COUNT)
+		return nil, base.NewError(base.ErrInvalidArgument, base.OP_GET_ID, group, errors.New(errorMsg))
+	}
+	ids := make([]uint64, 0, count)
+	for i := 0; i < count; i++ {
+		id, err := self.GetIdContext(ctx, group)
+		if err != nil {
+			return ids, err
+		}
+		if id == 0 {
+			return ids, base.NewError(base.ErrCacheUnavailable, base.OP_GET_ID, group, nil)
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
@@ -0,0 +1,103 @@
+package manager
+
+import (
+	"errors"
+	"fmt"
+	"go_idcenter/base"
+	"math"
+	"strings"
+)
+
+// The check digit scheme appends check digit(s) to the decimal form of id,
+// so that the mistyped id can be detected.
+type CheckDigitScheme interface {
+	Name() string
+	Append(id uint64) (uint64, error)
+	// Strip removes the check digit(s) of the id, and reports whether they are valid.
+	Strip(id uint64) (uint64, bool)
+}
+
+type checkDigitScheme struct {
+	name    string
+	width   uint64
+	compute func(id uint64) uint64
+}
+
+var dammTable = [10][10]uint8{
+	{0, 3, 1, 7, 5, 9, 8, 6, 4, 2},
+	{7, 0, 9, 2, 1, 5, 4, 8, 6, 3},
+	{4, 2, 0, 6, 8, 7, 1, 3, 5, 9},
+	{1, 7, 5, 0, 9, 8, 3, 4, 2, 6},
+	{6, 1, 2, 3, 0, 4, 5, 9, 7, 8},
+	{3, 6, 7, 4, 2, 0, 9, 5, 8, 1},
+	{5, 8, 6, 9, 7, 2, 0, 1, 3, 4},
+	{8, 9, 4, 5, 3, 6, 2, 0, 1, 7},
+	{9, 4, 3, 8, 6, 1, 7, 2, 0, 5},
+	{2, 5, 8, 1, 4, 3, 6, 7, 9, 0},
+}
+
+var luhnScheme = &checkDigitScheme{name: "luhn", width: 10, compute: luhnCheckDigit}
+var dammScheme = &checkDigitScheme{name: "damm", width: 10, compute: dammCheckDigit}
+var mod97Scheme = &checkDigitScheme{name: "mod97-10", width: 100, compute: mod97CheckDigits}
+
+func ParseCheckDigitScheme(literal string) (CheckDigitScheme, error) {
+	switch strings.ToLower(strings.TrimSpace(literal)) {
+	case "":
+		return nil, nil
+	case "luhn":
+		return luhnScheme, nil
+	case "damm":
+		return dammScheme, nil
+	case "mod97-10", "iso7064":
+		return mod97Scheme, nil
+	}
+	errorMsg := fmt.Sprintf("IdCenter: Unsupported check digit scheme '%s'!", literal)
+	return nil, errors.New(errorMsg)
+}
+
+func (self *checkDigitScheme) Name() string {
+	return self.name
+}
+
+func (self *checkDigitScheme) Append(id uint64) (uint64, error) {
+	if id > (math.MaxUint64-(self.width-1))/self.width {
+		errorMsg := fmt.Sprintf("IdCenter: The id '%d' is too large to append check digit(s) of '%s'!", id, self.name)
+		return 0, base.NewError(base.ErrInvalidArgument, base.OP_GET_ID, "", errors.New(errorMsg))
+	}
+	return id*self.width + self.compute(id), nil
+}
+
+func (self *checkDigitScheme) Strip(id uint64) (uint64, bool) {
+	originalId := id / self.width
+	return originalId, self.compute(originalId) == id%self.width
+}
+
+func luhnCheckDigit(id uint64) uint64 {
+	var sum uint64
+	double := true
+	for ; id > 0; id /= 10 {
+		digit := id % 10
+		if double {
+			digit *= 2
+			if digit > 9 {
+				digit -= 9
+			}
+		}
+		sum += digit
+		double = !double
+	}
+	return (10 - sum%10) % 10
+}
+
+func dammCheckDigit(id uint64) uint64 {
+	var interim uint8
+	for _, digit := range fmt.Sprintf("%d", id) {
+		interim = dammTable[interim][digit-'0']
+	}
+	return uint64(interim)
+}
+
+// mod97CheckDigits computes the check digits of ISO 7064 MOD 97-10.
+func mod97CheckDigits(id uint64) uint64 {
+	return 98 - (id%97)*100%97
+}
@@ -0,0 +1,48 @@
+package manager
+
+import (
+	"math"
+	"testing"
+)
+
+func TestCheckDigitScheme(t *testing.T) {
+	expectedIds := map[string][][]uint64{
+		"luhn":     [][]uint64{{7992739871, 79927398713}, {1, 18}},
+		"damm":     [][]uint64{{572, 5724}, {1, 13}},
+		"mod97-10": [][]uint64{{123456, 12345676}, {1, 195}},
+	}
+	for name, pairs := range expectedIds {
+		scheme, err := ParseCheckDigitScheme(name)
+		if err != nil {
+			t.Errorf("Parse check digit scheme error: %s", err)
+			t.FailNow()
+		}
+		if scheme.Name() != name {
+			t.Errorf("The scheme name '%s' is not equals '%s'.", scheme.Name(), name)
+		}
+		for _, pair := range pairs {
+			id, err := scheme.Append(pair[0])
+			if err != nil {
+				t.Errorf("Append check digit error (scheme=%s): %s", name, err)
+				t.FailNow()
+			}
+			if id != pair[1] {
+				t.Errorf("The id '%d' is not equals '%d'. (scheme=%s)", id, pair[1], name)
+			}
+			originalId, ok := scheme.Strip(id)
+			if !ok || originalId != pair[0] {
+				t.Errorf("Strip check digit of id '%d' is failing. (scheme=%s)", id, name)
+			}
+			// A single mistyped digit must be detected.
+			if _, ok := scheme.Strip(id + 10); ok {
+				t.Errorf("The mistyped id '%d' is valid. (scheme=%s)", id+10, name)
+			}
+		}
+		if _, err := scheme.Append(math.MaxUint64 / 10); err == nil {
+			t.Errorf("The overflow is not detected. (scheme=%s)", name)
+		}
+	}
+	if _, err := ParseCheckDigitScheme("verhoeff"); err == nil {
+		t.Error("The scheme 'verhoeff' should be unsupported!")
+	}
+}
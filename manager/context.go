@@ -0,0 +1,109 @@
+package manager
+
+import (
+	"context"
+	"go_idcenter/base"
+)
+
+// cacheProviderContext returns the cache provider whose operations give up when ctx is done. The provider
+// which does not implement base.ContextCacheProvider is only checked before its operations.
+func cacheProviderContext(cacheProvider base.CacheProvider) base.ContextCacheProvider {
+	if contextCacheProvider, ok := cacheProvider.(base.ContextCacheProvider); ok {
+		return contextCacheProvider
+	}
+	return checkedCacheProvider{cacheProvider}
+}
+
+// storageProviderContext returns the storage provider whose operations give up when ctx is done. The provider
+// which does not implement base.ContextStorageProvider is only checked before its operations.
+func storageProviderContext(storageProvider base.StorageProvider) base.ContextStorageProvider {
+	if contextStorageProvider, ok := storageProvider.(base.ContextStorageProvider); ok {
+		return contextStorageProvider
+	}
+	return checkedStorageProvider{storageProvider}
+}
+
+type checkedCacheProvider struct {
+	base.CacheProvider
+}
+
+func (self checkedCacheProvider) BuildListContext(ctx context.Context, group string, idRange base.IdRange, seed int64) (bool, error) {
+	if err := base.CheckContext(ctx, base.OP_BUILD_LIST, group); err != nil {
+		return false, err
+	}
+	return self.BuildList(group, idRange, seed)
+}
+
+func (self checkedCacheProvider) PopContext(ctx context.Context, group string) (uint64, error) {
+	if err := base.CheckContext(ctx, base.OP_POP, group); err != nil {
+		return 0, err
+	}
+	return self.Pop(group)
+}
+
+func (self checkedCacheProvider) ClearContext(ctx context.Context, group string) (bool, error) {
+	if err := base.CheckContext(ctx, base.OP_CLEAR, group); err != nil {
+		return false, err
+	}
+	return self.Clear(group)
+}
+
+type checkedStorageProvider struct {
+	base.StorageProvider
+}
+
+func (self checkedStorageProvider) BuildInfoContext(ctx context.Context, group string, start uint64, step uint32, offset uint64, increment uint32) (bool, error) {
+	if err := base.CheckContext(ctx, base.OP_BUILD_INFO, group); err != nil {
+		return false, err
+	}
+	return self.BuildInfo(group, start, step, offset, increment)
+}
+
+func (self checkedStorageProvider) GetContext(ctx context.Context, group string) (*base.GroupInfo, error) {
+	if err := base.CheckContext(ctx, base.OP_GET, group); err != nil {
+		return nil, err
+	}
+	return self.Get(group)
+}
+
+func (self checkedStorageProvider) ListContext(ctx context.Context) ([]base.GroupInfo, error) {
+	if err := base.CheckContext(ctx, base.OP_LIST, ""); err != nil {
+		return nil, err
+	}
+	return self.List()
+}
+
+func (self checkedStorageProvider) UpdateContext(ctx context.Context, group string, step uint32, bound uint64) (bool, error) {
+	if err := base.CheckContext(ctx, base.OP_UPDATE, group); err != nil {
+		return false, err
+	}
+	return self.Update(group, step, bound)
+}
+
+func (self checkedStorageProvider) PropelContext(ctx context.Context, group string, offset uint64, increment uint32) (*base.IdRange, error) {
+	if err := base.CheckContext(ctx, base.OP_PROPEL, group); err != nil {
+		return nil, err
+	}
+	return self.Propel(group, offset, increment)
+}
+
+func (self checkedStorageProvider) ReserveContext(ctx context.Context, group string, size uint64, offset uint64, increment uint32) (*base.IdRange, error) {
+	if err := base.CheckContext(ctx, base.OP_RESERVE, group); err != nil {
+		return nil, err
+	}
+	return self.Reserve(group, size, offset, increment)
+}
+
+func (self checkedStorageProvider) ForwardContext(ctx context.Context, group string, next uint64) (bool, error) {
+	if err := base.CheckContext(ctx, base.OP_FORWARD, group); err != nil {
+		return false, err
+	}
+	return self.Forward(group, next)
+}
+
+func (self checkedStorageProvider) ClearContext(ctx context.Context, group string) (bool, error) {
+	if err := base.CheckContext(ctx, base.OP_CLEAR, group); err != nil {
+		return false, err
+	}
+	return self.Clear(group)
+}
@@ -0,0 +1,89 @@
+package manager
+
+import (
+	"context"
+	"errors"
+	"go_idcenter/base"
+	"testing"
+	"time"
+)
+
+// The storage provider whose propel blocks until ctx is done.
+type blockingStorageProvider struct {
+	base.StorageProvider
+}
+
+func (self blockingStorageProvider) BuildInfoContext(ctx context.Context, group string, start uint64, step uint32, offset uint64, increment uint32) (bool, error) {
+	return self.BuildInfo(group, start, step, offset, increment)
+}
+
+func (self blockingStorageProvider) GetContext(ctx context.Context, group string) (*base.GroupInfo, error) {
+	return self.Get(group)
+}
+
+func (self blockingStorageProvider) ListContext(ctx context.Context) ([]base.GroupInfo, error) {
+	return self.List()
+}
+
+func (self blockingStorageProvider) UpdateContext(ctx context.Context, group string, step uint32, bound uint64) (bool, error) {
+	return self.Update(group, step, bound)
+}
+
+func (self blockingStorageProvider) PropelContext(ctx context.Context, group string, offset uint64, increment uint32) (*base.IdRange, error) {
+	<-ctx.Done()
+	return nil, base.CheckContext(ctx, base.OP_PROPEL, group)
+}
+
+func (self blockingStorageProvider) ReserveContext(ctx context.Context, group string, size uint64, offset uint64, increment uint32) (*base.IdRange, error) {
+	return self.Reserve(group, size, offset, increment)
+}
+
+func (self blockingStorageProvider) ForwardContext(ctx context.Context, group string, next uint64) (bool, error) {
+	return self.Forward(group, next)
+}
+
+func (self blockingStorageProvider) ClearContext(ctx context.Context, group string) (bool, error) {
+	return self.Clear(group)
+}
+
+func TestGetIdContext(t *testing.T) {
+	cp := NewMemoryCacheProvider("Memory Cache Provider (" + t.Name() + ")")
+	sp := NewMemoryStorageProvider("Memory Storage Provider (" + t.Name() + ")")
+	bsp := blockingStorageProvider{NewMemoryStorageProvider("Blocking Storage Provider (" + t.Name() + ")")}
+	RegisterProvider(cp)
+	RegisterProvider(sp)
+	RegisterProvider(bsp)
+	defer UnregisterProvider(cp)
+	defer UnregisterProvider(sp)
+	defer UnregisterProvider(bsp)
+
+	// The provider without the context variants is checked before its operations.
+	idCenterManager := &IdCenterManager{CacheProviderName: cp.Name(), StorageProviderName: sp.Name(), Start: 1, Step: 10}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := idCenterManager.GetIdContext(ctx, "context_test"); !errors.Is(err, context.Canceled) {
+		t.Errorf("The error '%v' should be context.Canceled.", err)
+		t.FailNow()
+	}
+	if _, err := idCenterManager.CreateGroupContext(ctx, "context_test", 1, 10); !errors.Is(err, context.Canceled) {
+		t.Errorf("The error '%v' should be context.Canceled.", err)
+		t.FailNow()
+	}
+	if id, err := idCenterManager.GetIdContext(context.Background(), "context_test"); err != nil || id != 1 {
+		t.Errorf("The id '%d' is not equals '1'. (%v)", id, err)
+		t.FailNow()
+	}
+
+	// The provider with the context variants gives up when the deadline passes.
+	idCenterManager = &IdCenterManager{CacheProviderName: cp.Name(), StorageProviderName: bsp.Name(), Start: 1, Step: 10}
+	ctx, cancel = context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if _, err := idCenterManager.GetIdContext(ctx, "blocking_context_test"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("The error '%v' should be context.DeadlineExceeded.", err)
+		t.FailNow()
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("The propelling is not given up in time. (elapsed=%s)", elapsed)
+	}
+}
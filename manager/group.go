@@ -0,0 +1,149 @@
+package manager
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The sequence of a periodic group restarts in every period. The storage row of
+// such group is keyed by the group name plus the period key, e.g. 'order@20131018'.
+type Period uint8
+
+const (
+	PERIOD_NONE Period = iota
+	PERIOD_DAILY
+	PERIOD_MONTHLY
+	PERIOD_YEARLY
+)
+
+const (
+	PERIOD_SEPARATOR    = "@"
+	GROUP_CONFIG_PREFIX = "group."
+)
+
+type GroupConfig struct {
+	Period           Period
+	Obfuscator       Obfuscator
+	CheckDigitScheme CheckDigitScheme
+	ShuffleSeed      int64 // The ids in each segment are issued in random order if it is not zero.
+}
+
+func ParsePeriod(literal string) (Period, error) {
+	switch strings.ToLower(strings.TrimSpace(literal)) {
+	case "", "none":
+		return PERIOD_NONE, nil
+	case "daily", "day":
+		return PERIOD_DAILY, nil
+	case "monthly", "month":
+		return PERIOD_MONTHLY, nil
+	case "yearly", "year":
+		return PERIOD_YEARLY, nil
+	}
+	errorMsg := fmt.Sprintf("IdCenter: Unsupported period '%s'!", literal)
+	return PERIOD_NONE, errors.New(errorMsg)
+}
+
+func (self Period) String() string {
+	switch self {
+	case PERIOD_DAILY:
+		return "daily"
+	case PERIOD_MONTHLY:
+		return "monthly"
+	case PERIOD_YEARLY:
+		return "yearly"
+	}
+	return "none"
+}
+
+// Key returns the literal of the period which the time t belongs to.
+func (self Period) Key(t time.Time) string {
+	switch self {
+	case PERIOD_DAILY:
+		return t.Format("20060102")
+	case PERIOD_MONTHLY:
+		return t.Format("200601")
+	case PERIOD_YEARLY:
+		return t.Format("2006")
+	}
+	return ""
+}
+
+// Previous returns a time which belongs to the period just before the one of t.
+func (self Period) Previous(t time.Time) time.Time {
+	year, month, day := t.Date()
+	switch self {
+	case PERIOD_DAILY:
+		return time.Date(year, month, day, 0, 0, 0, 0, t.Location()).Add(-time.Nanosecond)
+	case PERIOD_MONTHLY:
+		return time.Date(year, month, 1, 0, 0, 0, 0, t.Location()).Add(-time.Nanosecond)
+	case PERIOD_YEARLY:
+		return time.Date(year, time.January, 1, 0, 0, 0, 0, t.Location()).Add(-time.Nanosecond)
+	}
+	return t
+}
+
+func periodicGroupName(group string, period Period, t time.Time) string {
+	return group + PERIOD_SEPARATOR + period.Key(t)
+}
+
+// LoadGroupConfigs collects the per-group options of the config, which are in the form
+// 'group.<group name>.<option>=<value>', and the options are:
+//
+//	period            none (default), daily, monthly or yearly
+//	obfuscation_key   the key of the obfuscator of ids
+//	check_digit       the check digit scheme: luhn, damm or mod97-10
+//	shuffle_seed      the non-zero seed of issuing the ids in each segment in random order
+//
+// The obfuscation and the check digit can not be both enabled for a group.
+func LoadGroupConfigs(dict map[string]string) (map[string]GroupConfig, error) {
+	groupConfigs := make(map[string]GroupConfig)
+	for key, value := range dict {
+		if !strings.HasPrefix(key, GROUP_CONFIG_PREFIX) {
+			continue
+		}
+		index := strings.LastIndex(key, ".")
+		if index <= len(GROUP_CONFIG_PREFIX) {
+			return nil, fmt.Errorf("The group name in config key '%s' is empty!", key)
+		}
+		group := key[len(GROUP_CONFIG_PREFIX):index]
+		option := key[index+1:]
+		groupConfig := groupConfigs[group]
+		switch option {
+		case "period":
+			period, err := ParsePeriod(value)
+			if err != nil {
+				return nil, err
+			}
+			groupConfig.Period = period
+		case "obfuscation_key":
+			if len(value) == 0 {
+				return nil, fmt.Errorf("The obfuscation key of group '%s' is empty!", group)
+			}
+			groupConfig.Obfuscator = NewFeistelObfuscator(value)
+		case "check_digit":
+			scheme, err := ParseCheckDigitScheme(value)
+			if err != nil {
+				return nil, err
+			}
+			groupConfig.CheckDigitScheme = scheme
+		case "shuffle_seed":
+			seed, err := strconv.ParseInt(value, 10, 64)
+			if err != nil || seed == 0 {
+				return nil, fmt.Errorf("The shuffle seed '%s' of group '%s' is INVALID!", value, group)
+			}
+			groupConfig.ShuffleSeed = seed
+		default:
+			return nil, fmt.Errorf("Unknown group option '%s'! (key=%s)", option, key)
+		}
+		groupConfigs[group] = groupConfig
+	}
+	for group, groupConfig := range groupConfigs {
+		if groupConfig.Obfuscator != nil && groupConfig.CheckDigitScheme != nil {
+			return nil, fmt.Errorf("The obfuscation and the check digit can not be both enabled for group '%s'!", group)
+		}
+	}
+	return groupConfigs, nil
+}
@@ -0,0 +1,132 @@
+package manager
+
+import (
+	"errors"
+	"go_idcenter/base"
+	"testing"
+	"time"
+)
+
+func TestPeriod(t *testing.T) {
+	now := time.Date(2013, time.March, 1, 0, 0, 0, 0, time.Local)
+	expectedKeys := map[Period][]string{
+		PERIOD_DAILY:   []string{"20130301", "20130228"},
+		PERIOD_MONTHLY: []string{"201303", "201302"},
+		PERIOD_YEARLY:  []string{"2013", "2012"},
+	}
+	for period, keys := range expectedKeys {
+		parsedPeriod, err := ParsePeriod(period.String())
+		if err != nil {
+			t.Errorf("Parse period error: %s", err)
+			t.FailNow()
+		}
+		if parsedPeriod != period {
+			t.Errorf("The parsed period '%v' is not equals '%v'.", parsedPeriod, period)
+		}
+		if key := period.Key(now); key != keys[0] {
+			t.Errorf("The key '%s' of period '%v' is not equals '%s'.", key, period, keys[0])
+		}
+		if key := period.Key(period.Previous(now)); key != keys[1] {
+			t.Errorf("The previous key '%s' of period '%v' is not equals '%s'.", key, period, keys[1])
+		}
+	}
+	if _, err := ParsePeriod("weekly"); err == nil {
+		t.Error("The period 'weekly' should be unsupported!")
+	}
+	if name := periodicGroupName("order", PERIOD_DAILY, now); name != "order@20130301" {
+		t.Errorf("Unexpected periodic group name '%s'.", name)
+	}
+}
+
+func TestRetireGroup(t *testing.T) {
+	registry := NewRegistry()
+	cp := NewMemoryCacheProvider("Memory Cache Provider")
+	sp := NewMemoryStorageProvider("Memory Storage Provider")
+	registry.Register(cp)
+	registry.Register(sp)
+	groupConfigs := map[string]GroupConfig{"retire_test": GroupConfig{Period: PERIOD_DAILY}}
+	idCenterManager := &IdCenterManager{Registry: registry, CacheProviderName: cp.Name(), StorageProviderName: sp.Name(),
+		Start: 1, Step: 10, Groups: groupConfigs, RetireDelay: 10 * time.Millisecond}
+	now := time.Now()
+	lastGroup := periodicGroupName("retire_test", PERIOD_DAILY, PERIOD_DAILY.Previous(now))
+	issued := make(map[uint64]bool)
+	for i := 0; i < 5; i++ {
+		id, err := idCenterManager.GetId(lastGroup)
+		if err != nil {
+			t.Errorf("GetId error: %s", err)
+			t.FailNow()
+		}
+		issued[id] = true
+	}
+
+	// The group of the last period is retired after the delay, without blocking the current one.
+	if _, err := idCenterManager.GetId("retire_test"); err != nil {
+		t.Errorf("GetId error: %s", err)
+		t.FailNow()
+	}
+	if groupInfo, _ := sp.Get(lastGroup); groupInfo == nil || groupInfo.Bound != 0 {
+		t.Errorf("The group of the last period should not be retired before the delay: %v", groupInfo)
+		t.FailNow()
+	}
+	time.Sleep(50 * time.Millisecond)
+	groupInfo, err := sp.Get(lastGroup)
+	if err != nil || groupInfo == nil || groupInfo.Bound != groupInfo.Range.End {
+		t.Errorf("The group of the last period should be sealed, but %v (%v).", groupInfo, err)
+		t.FailNow()
+	}
+
+	// The late request of the last period is never served from the start again.
+	id, err := idCenterManager.GetId(lastGroup)
+	if !errors.Is(err, base.ErrExhausted) || issued[id] {
+		t.Errorf("The retired group should be exhausted, but %d (%v).", id, err)
+		t.FailNow()
+	}
+}
+
+func TestLoadGroupConfigs(t *testing.T) {
+	cases := []struct {
+		name  string
+		dict  map[string]string
+		valid bool
+		check func(groupConfigs map[string]GroupConfig) bool
+	}{
+		{"no group", map[string]string{"id_start": "1"}, true, func(groupConfigs map[string]GroupConfig) bool {
+			return len(groupConfigs) == 0
+		}},
+		{"all options", map[string]string{
+			"group.order.period":         "daily",
+			"group.order.shuffle_seed":   "42",
+			"group.order.check_digit":    "luhn",
+			"group.user.obfuscation_key": "user_key",
+			"group.v1.user.shuffle_seed": "7",
+			"rate_limit.group.order":     "100",
+		}, true, func(groupConfigs map[string]GroupConfig) bool {
+			order, user, dotted := groupConfigs["order"], groupConfigs["user"], groupConfigs["v1.user"]
+			return len(groupConfigs) == 3 && order.Period == PERIOD_DAILY && order.ShuffleSeed == 42 &&
+				order.CheckDigitScheme != nil && order.Obfuscator == nil && user.Obfuscator != nil && dotted.ShuffleSeed == 7
+		}},
+		{"obfuscation and check digit", map[string]string{"group.order.obfuscation_key": "order_key", "group.order.check_digit": "luhn"}, false, nil},
+		{"empty group name", map[string]string{"group..period": "daily"}, false, nil},
+		{"unknown option", map[string]string{"group.order.unknown": "1"}, false, nil},
+		{"invalid period", map[string]string{"group.order.period": "hourly"}, false, nil},
+		{"empty obfuscation key", map[string]string{"group.order.obfuscation_key": ""}, false, nil},
+		{"invalid check digit", map[string]string{"group.order.check_digit": "verhoeff"}, false, nil},
+		{"invalid shuffle seed", map[string]string{"group.order.shuffle_seed": "0"}, false, nil},
+	}
+	for _, c := range cases {
+		groupConfigs, err := LoadGroupConfigs(c.dict)
+		if !c.valid {
+			if err == nil {
+				t.Errorf("The group config of case '%s' should be INVALID.", c.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Load group configs error of case '%s': %s", c.name, err)
+			continue
+		}
+		if !c.check(groupConfigs) {
+			t.Errorf("The group configs of case '%s' are unexpected: %v", c.name, groupConfigs)
+		}
+	}
+}
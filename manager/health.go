@@ -0,0 +1,129 @@
+package manager
+
+import (
+	"context"
+	"fmt"
+	"go_idcenter/base"
+	"sort"
+	"time"
+)
+
+const (
+	HEALTH_STATUS_OK      = "ok"
+	HEALTH_STATUS_FAILING = "failing"
+	// The provider does not implement base.HealthChecker.
+	HEALTH_STATUS_UNKNOWN = "unknown"
+)
+
+const (
+	PROVIDER_TYPE_CACHE   = "cache"
+	PROVIDER_TYPE_STORAGE = "storage"
+)
+
+const (
+	DEFAULT_HEALTH_CHECK_TIMEOUT = 2 * time.Second
+)
+
+type ProviderHealth struct {
+	Name    string
+	Type    string
+	Status  string
+	Error   string
+	Latency time.Duration
+}
+
+// CheckProviders checks the health of all providers in the default registry (see Registry.CheckProviders).
+func CheckProviders(timeout time.Duration) []ProviderHealth {
+	return defaultRegistry.CheckProviders(timeout)
+}
+
+// CheckProviders checks the health of all providers in the registry of the manager (see Registry.CheckProviders).
+func (self *IdCenterManager) CheckProviders(timeout time.Duration) []ProviderHealth {
+	return self.registry().CheckProviders(timeout)
+}
+
+// CheckProviders checks the health of all registered providers concurrently. The check which
+// does not finish within timeout is failing, and is given up if the provider implements
+// base.ContextHealthChecker.
+func (self *Registry) CheckProviders(timeout time.Duration) []ProviderHealth {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	type checkedProvider struct {
+		provider     base.Provider
+		providerType string
+	}
+	var providers []checkedProvider
+	cacheProviders, storageProviders := self.providers()
+	for _, cacheProvider := range cacheProviders {
+		providers = append(providers, checkedProvider{cacheProvider, PROVIDER_TYPE_CACHE})
+	}
+	for _, storageProvider := range storageProviders {
+		providers = append(providers, checkedProvider{storageProvider, PROVIDER_TYPE_STORAGE})
+	}
+	type checkResult struct {
+		index  int
+		health ProviderHealth
+	}
+	results := make([]ProviderHealth, len(providers))
+	finished := make([]bool, len(providers))
+	resultChan := make(chan checkResult, len(providers))
+	pending := 0
+	for i, p := range providers {
+		results[i] = ProviderHealth{Name: p.provider.Name(), Type: p.providerType, Status: HEALTH_STATUS_UNKNOWN}
+		healthChecker, ok := p.provider.(base.HealthChecker)
+		if !ok {
+			finished[i] = true
+			continue
+		}
+		pending++
+		go func(i int, health ProviderHealth) {
+			start := time.Now()
+			err := checkHealth(ctx, healthChecker)
+			health.Latency = time.Since(start)
+			health.Status = HEALTH_STATUS_OK
+			if err != nil {
+				health.Status = HEALTH_STATUS_FAILING
+				health.Error = err.Error()
+			}
+			resultChan <- checkResult{i, health}
+		}(i, results[i])
+	}
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	for ; pending > 0; pending-- {
+		select {
+		case result := <-resultChan:
+			finished[result.index] = true
+			results[result.index] = result.health
+		case <-timer.C:
+			for i := range results {
+				if !finished[i] {
+					results[i].Status = HEALTH_STATUS_FAILING
+					results[i].Error = fmt.Sprintf("The health check is timed out! (timeout=%s)", timeout)
+					results[i].Latency = timeout
+				}
+			}
+			pending = 1
+		}
+	}
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Type != results[j].Type {
+			return results[i].Type < results[j].Type
+		}
+		return results[i].Name < results[j].Name
+	})
+	return results
+}
+
+// checkHealth calls the health checker, taking its panic as the failure.
+func checkHealth(ctx context.Context, healthChecker base.HealthChecker) (err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("The health check panics: %v", p)
+		}
+	}()
+	if contextHealthChecker, ok := healthChecker.(base.ContextHealthChecker); ok {
+		return contextHealthChecker.CheckHealthContext(ctx)
+	}
+	return healthChecker.CheckHealth()
+}
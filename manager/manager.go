@@ -1,252 +1,427 @@
 package manager
 
 import (
-	"bytes"
+	"context"
 	"errors"
 	"fmt"
 	"go_idcenter/base"
-	"reflect"
+	"go_idcenter/metrics"
 	"runtime/debug"
+	"sync"
+	"time"
 )
 
 const (
 	_ = iota
 	DEFAULT_START
-	DEFAULT_STEP = 1000
+	DEFAULT_STEP      = 1000
+	DEFAULT_OFFSET    = 1
+	DEFAULT_INCREMENT = 1
+	// The delay of retiring the group of the last period after the switching, so that the requests
+	// which resolved the last period before the switching are done.
+	DEFAULT_RETIRE_DELAY = time.Minute
 )
 
-var cacheProviderMap = make(map[string]base.CacheProvider)
-var storageProviderMap = make(map[string]base.StorageProvider)
-
+// RegisterProvider registers provider in the default registry, which is used by the managers without their own one.
 func RegisterProvider(provider base.Provider) error {
-	defer func() {
-		if err := recover(); err != nil {
-			debug.PrintStack()
-			errorMsg := fmt.Sprintf("Occur FATAL error when register provider (provider=%v): %s", provider, err)
-			base.Logger().Fatalln(errorMsg)
-		}
-	}()
-	if provider == nil {
-		panicMsg := "IdCenter: The provider is nil!\n"
-		base.Logger().Fatal(panicMsg)
-		panic(panicMsg)
-	}
-	name := provider.Name()
-	if len(name) == 0 {
-		panicMsg := "IdCenter: The name of provider is nil!\n"
-		base.Logger().Fatal(panicMsg)
-		panic(panicMsg)
-	}
-	switch t := interface{}(provider).(type) {
-	case base.CacheProvider:
-		if _, contains := cacheProviderMap[name]; contains {
-			errorMsg := fmt.Sprintf("IdCenter: Repetitive cache provider name '%s'!\n", name)
-			base.Logger().Error(errorMsg)
-			return errors.New(errorMsg)
-		}
-		cp, ok := interface{}(provider).(base.CacheProvider)
-		if !ok {
-			errorMsg := fmt.Sprintf("IdCenter: Incorrect cache provider type! (name '%s')\n", name)
-			base.Logger().Error(errorMsg)
-			return errors.New(errorMsg)
-		}
-		cacheProviderMap[name] = cp
-	case base.StorageProvider:
-		if _, contains := storageProviderMap[name]; contains {
-			errorMsg := fmt.Sprintf("IdCenter: Repetitive storage provider name '%s'!\n", name)
-			base.Logger().Error(errorMsg)
-			return errors.New(errorMsg)
-		}
-		sp, ok := interface{}(provider).(base.StorageProvider)
-		if !ok {
-			errorMsg := fmt.Sprintf("IdCenter: Incorrect cache provider type! (name '%s')\n", name)
-			base.Logger().Error(errorMsg)
-			return errors.New(errorMsg)
-		}
-		storageProviderMap[name] = sp
-	default:
-		panicMsg := fmt.Sprintf("IdCenter: Unexpected Provider type '%v'! (name=%s)\n", t, name)
-		base.Logger().Fatal(panicMsg)
-		panic(panicMsg)
-	}
-	return nil
+	return defaultRegistry.Register(provider)
 }
 
-func UnregisterProvider(provider base.Provider) {
-	defer func() {
-		if err := recover(); err != nil {
-			debug.PrintStack()
-			errorMsg := fmt.Sprintf("Occur FATAL error when unregister provider (provider=%v): %s", provider, err)
-			base.Logger().Fatalln(errorMsg)
-		}
-	}()
-	if provider == nil {
-		panicMsg := "IdCenter: The provider is nil!\n"
-		base.Logger().Fatal(panicMsg)
-		panic(panicMsg)
-	}
-	name := provider.Name()
-	if len(name) == 0 {
-		panicMsg := "IdCenter: The name of provider is nil!\n"
-		base.Logger().Fatal(panicMsg)
-		panic(panicMsg)
-	}
-	switch t := interface{}(provider).(type) {
-	case base.CacheProvider:
-		_, contains := cacheProviderMap[name]
-		if contains {
-			delete(cacheProviderMap, name)
-		} else {
-			base.Logger().Warnf("IdCenter: The cache Provider named '%s' is NOTEXISTENT!\n", name)
-		}
-	case base.StorageProvider:
-		_, contains := storageProviderMap[name]
-		if contains {
-			delete(storageProviderMap, name)
-		} else {
-			base.Logger().Warnf("IdCenter: The storage Provider named '%s' is NOTEXISTENT!\n", name)
-		}
-	default:
-		panicMsg := fmt.Sprintf("IdCenter: Unexpected Provider type '%v'! (name=%s)\n", t, name)
-		base.Logger().Fatal(panicMsg)
-		panic(panicMsg)
+// UnregisterProvider removes provider from the default registry.
+func UnregisterProvider(provider base.Provider) error {
+	return defaultRegistry.Unregister(provider)
+}
+
+// recoverPanic is deferred by the exported functions as the last-resort guard. It recovers the panic,
+// logs it with the stack, and sets *errp to the error of op on group whose kind is base.ErrInternal.
+func recoverPanic(op string, group string, errp *error) {
+	p := recover()
+	if p == nil {
+		return
 	}
-	return
+	err := base.NewError(base.ErrInternal, op, group, fmt.Errorf("panic: %v", p))
+	base.Log(base.SUBSYSTEM_MANAGER).Error("The panic is recovered.", base.LOG_FIELD_OP, op, base.LOG_FIELD_GROUP, group,
+		base.LOG_FIELD_ERROR, err.Error(), "stack", string(debug.Stack()))
+	*errp = err
 }
 
 type IdCenterManager struct {
+	// The registry of providers, or the default one (see RegisterProvider) if nil.
+	Registry            *Registry
 	CacheProviderName   string
 	StorageProviderName string
 	Start               uint64
 	Step                uint32
+	Offset              uint64 // The offset of ids of this instance, in [1, Increment].
+	Increment           uint32 // The increment of ids, which is shared by all instances.
+	Groups              map[string]GroupConfig
+	RetireDelay         time.Duration // The delay of retiring the group of the last period, DEFAULT_RETIRE_DELAY if zero.
+	periodSign          sync.Mutex
+	periodGroups        map[string]string
+	stateSign           sync.RWMutex
+	shutDown            bool
+	inFlight            sync.WaitGroup
 }
 
 func (self *IdCenterManager) GetId(group string) (uint64, error) {
-	defer func() {
-		if err := recover(); err != nil {
-			debug.PrintStack()
-			errorMsg := fmt.Sprintf("Occur FATAL error when get id (group=%v): %s", group, err)
-			base.Logger().Fatalln(errorMsg)
+	return self.GetIdContext(context.Background(), group)
+}
+
+// GetIdContext is GetId which gives up when ctx is done, and whose logs carry the request id of ctx.
+func (self *IdCenterManager) GetIdContext(ctx context.Context, group string) (uint64, error) {
+	if !self.enter() {
+		return 0, base.NewError(base.ErrShutDown, base.OP_GET_ID, group, nil)
+	}
+	defer self.inFlight.Done()
+	defer metrics.ObserveDuration(metrics.OP_GET_ID, time.Now())
+	id, err := self.getId(ctx, group)
+	if err != nil || id == 0 {
+		return id, err
+	}
+	metrics.IdsIssued.Inc(group)
+	groupConfig := self.Groups[group]
+	if groupConfig.Obfuscator != nil {
+		id = groupConfig.Obfuscator.Encode(id)
+	}
+	if groupConfig.CheckDigitScheme != nil {
+		return groupConfig.CheckDigitScheme.Append(id)
+	}
+	return id, nil
+}
+
+// Decode returns the original id of the one which is got from the group.
+func (self *IdCenterManager) Decode(group string, id uint64) (uint64, error) {
+	if len(group) == 0 {
+		return 0, base.NewError(base.ErrInvalidGroupName, base.OP_DECODE, group, nil)
+	}
+	groupConfig := self.Groups[group]
+	if groupConfig.CheckDigitScheme != nil {
+		originalId, ok := groupConfig.CheckDigitScheme.Strip(id)
+		if !ok {
+			errorMsg := fmt.Sprintf("IdCenter: The check digit of id '%d' is INVALID!", id)
+			return 0, base.NewError(base.ErrInvalidArgument, base.OP_DECODE, group, errors.New(errorMsg))
 		}
-	}()
-	cacheProvider := self.getCacheProvider()
-	storageProvider := self.getStorageProvider()
-	id, err := cacheProvider.Pop(group)
+		id = originalId
+	}
+	if groupConfig.Obfuscator != nil {
+		id = groupConfig.Obfuscator.Decode(id)
+	}
+	return id, nil
+}
+
+// Validate checks the check digit of the id which is got from the group.
+func (self *IdCenterManager) Validate(group string, id uint64) (bool, error) {
+	if len(group) == 0 {
+		return false, base.NewError(base.ErrInvalidGroupName, base.OP_VALIDATE, group, nil)
+	}
+	scheme := self.Groups[group].CheckDigitScheme
+	if scheme == nil {
+		errorMsg := fmt.Sprintf("IdCenter: The group '%s' has no check digit scheme!", group)
+		return false, base.NewError(base.ErrInvalidArgument, base.OP_VALIDATE, group, errors.New(errorMsg))
+	}
+	_, ok := scheme.Strip(id)
+	return ok, nil
+}
+
+func (self *IdCenterManager) getId(ctx context.Context, group string) (id uint64, err error) {
+	defer recoverPanic(base.OP_GET_ID, group, &err)
+	logger := base.Log(base.SUBSYSTEM_MANAGER)
+	originalGroup := group
+	group = self.resolveGroup(group)
+	cp, err := self.getCacheProvider(base.OP_GET_ID, group)
 	if err != nil {
-		switch err.(type) {
-		case *base.EmptyListError:
-			warningMsg := fmt.Sprintf("Warning: The list of group '%s' is empty.", group)
-			base.Logger().Warn(warningMsg)
+		return 0, err
+	}
+	sp, err := self.getStorageProvider(base.OP_GET_ID, group)
+	if err != nil {
+		return 0, err
+	}
+	cacheProvider := cacheProviderContext(cp)
+	storageProvider := storageProviderContext(sp)
+	id, err = self.pop(ctx, cacheProvider, group)
+	if err != nil {
+		var emptyListErr *base.EmptyListError
+		switch {
+		case errors.As(err, &emptyListErr):
+			metrics.CacheMisses.Inc(originalGroup)
+			logger.DebugContext(ctx, "The list of group is empty.", base.LOG_FIELD_GROUP, group)
 		default:
-			errorMsg := fmt.Sprintf("Occur error when pop id for group '%s': %s\n", group, err.Error())
-			base.Logger().Error(errorMsg)
 			return 0, err
 		}
 	}
 	if id > 0 {
 		return id, nil
 	}
-	base.Logger().Infof("Prepare check & build id list for group '%s'...\n", group)
-	groupInfo, err := storageProvider.Get(group)
-	if err != nil {
-		errorMsg := fmt.Sprintf("Occur error when get group (name='%s') info : %s\n", group, err.Error())
-		base.Logger().Error(errorMsg)
+	logger.InfoContext(ctx, "Prepare check & build id list of group.", base.LOG_FIELD_GROUP, group)
+	if err := self.ensureGroup(ctx, storageProvider, group); err != nil {
 		return 0, err
 	}
-	if groupInfo == nil {
-		currentStart := self.Start
-		if currentStart <= 0 {
-			currentStart = DEFAULT_START
-		}
-		currentStep := self.Step
-		if currentStep <= 0 {
-			currentStep = DEFAULT_STEP
-		}
-		ok, err := storageProvider.BuildInfo(group, currentStart, currentStep)
-		if err != nil {
-			errorMsg := fmt.Sprintf("Occur error when initialize group '%s': %s", group, err.Error())
-			base.Logger().Errorln(errorMsg)
-			return 0, err
-		}
-		if !ok {
-			warnMsg := fmt.Sprintf("Building group info is FAILING. Maybe the group already exists. (group=%v)", group)
-			base.Logger().Warnln(warnMsg)
-		}
-	}
-	idRange, err := storageProvider.Propel(group)
+	propelStart := time.Now()
+	offset, increment := self.alignment()
+	idRange, err := storageProvider.PropelContext(ctx, group, offset, increment)
+	metrics.ObserveDuration(metrics.OP_PROPEL, propelStart)
+	logProviderCall(ctx, storageProvider, metrics.OP_PROPEL, group, propelStart, err)
 	if err != nil {
-		errorMsg := fmt.Sprintf("Occur error when propel id for group '%s': %s\n", group, err.Error())
-		base.Logger().Error(errorMsg)
+		metrics.CountProviderError(storageProvider.Name(), metrics.OP_PROPEL, err)
 		return 0, err
 	}
-	currentBegin := idRange.Begin
-	currentEnd := idRange.End
-	ok, err := cacheProvider.BuildList(group, currentBegin, currentEnd)
+	metrics.Refills.Inc(originalGroup)
+	buildStart := time.Now()
+	ok, err := cacheProvider.BuildListContext(ctx, group, *idRange, self.segmentSeed(originalGroup, idRange.Begin))
+	metrics.ObserveDuration(metrics.OP_BUILD_LIST, buildStart)
+	logProviderCall(ctx, cacheProvider, metrics.OP_BUILD_LIST, group, buildStart, err)
 	if err != nil {
-		errorMsg := fmt.Sprintf("Occur error when build id list for group '%s': %s\n", group, err.Error())
-		base.Logger().Error(errorMsg)
+		metrics.CountProviderError(cacheProvider.Name(), metrics.OP_BUILD_LIST, err)
 		return 0, err
 	}
 	if !ok {
-		warnMsg := fmt.Sprintf("Building id list is FAILING. (group=%v)", group)
-		base.Logger().Warnln(warnMsg)
+		logger.WarnContext(ctx, "Building id list is FAILING.", base.LOG_FIELD_GROUP, group, base.LOG_FIELD_PROVIDER, cacheProvider.Name())
 	}
-	id, err = cacheProvider.Pop(group)
+	id, err = self.pop(ctx, cacheProvider, group)
 	if err != nil {
-		switch err.(type) {
-		case *base.EmptyListError:
-			warningMsg := fmt.Sprintf("Warning: The list of group '%s' is empty.", group)
-			base.Logger().Warn(warningMsg)
+		var emptyListErr *base.EmptyListError
+		switch {
+		case errors.As(err, &emptyListErr):
+			logger.WarnContext(ctx, "The list of group is empty after building.", base.LOG_FIELD_GROUP, group)
 		default:
-			errorMsg := fmt.Sprintf("Occur error when pop id for group '%s': %s\n", group, err.Error())
-			base.Logger().Error(errorMsg)
 			return 0, err
 		}
 	}
 	return id, nil
 }
 
+// pop pops the id from cache, observing the latency and counting the error other than the empty list.
+func (self *IdCenterManager) pop(ctx context.Context, cacheProvider base.ContextCacheProvider, group string) (uint64, error) {
+	start := time.Now()
+	defer metrics.ObserveDuration(metrics.OP_POP, start)
+	id, err := cacheProvider.PopContext(ctx, group)
+	var emptyListErr *base.EmptyListError
+	if errors.As(err, &emptyListErr) {
+		return id, err
+	}
+	logProviderCall(ctx, cacheProvider, metrics.OP_POP, group, start, err)
+	if err != nil {
+		metrics.CountProviderError(cacheProvider.Name(), metrics.OP_POP, err)
+	}
+	return id, err
+}
+
+// logProviderCall logs the call of provider which is started at start, at the debug level, or at the error level if it fails.
+func logProviderCall(ctx context.Context, provider base.Provider, op string, group string, start time.Time, err error) {
+	logger := base.Log(base.SUBSYSTEM_PROVIDER)
+	if err == nil {
+		logger.DebugContext(ctx, "The provider call is done.", base.LOG_FIELD_PROVIDER, provider.Name(), base.LOG_FIELD_OP, op,
+			base.LOG_FIELD_GROUP, group, base.LOG_FIELD_LATENCY, base.Latency(start))
+		return
+	}
+	logger.ErrorContext(ctx, "The provider call is FAILING.", base.LOG_FIELD_PROVIDER, provider.Name(), base.LOG_FIELD_OP, op,
+		base.LOG_FIELD_GROUP, group, base.LOG_FIELD_LATENCY, base.Latency(start), base.LOG_FIELD_ERROR, err.Error(),
+		base.LOG_FIELD_ERROR_CLASS, base.ErrorClass(err))
+}
+
 func (self *IdCenterManager) Clear(group string) (bool, error) {
-	defer func() {
-		if err := recover(); err != nil {
-			debug.PrintStack()
-			errorMsg := fmt.Sprintf("Occur FATAL error when clear (group=%v): %s", group, err)
-			base.Logger().Fatalln(errorMsg)
+	return self.ClearContext(context.Background(), group)
+}
+
+// ClearContext is Clear which gives up when ctx is done.
+func (self *IdCenterManager) ClearContext(ctx context.Context, group string) (ok bool, err error) {
+	defer recoverPanic(base.OP_CLEAR, group, &err)
+	group = self.resolveGroup(group)
+	storageProvider, err := self.getStorageProvider(base.OP_CLEAR, group)
+	if err != nil {
+		return false, err
+	}
+	cacheProvider, err := self.getCacheProvider(base.OP_CLEAR, group)
+	if err != nil {
+		return false, err
+	}
+	spResult, spErr := storageProviderContext(storageProvider).ClearContext(ctx, group)
+	cpResult, cpErr := cacheProviderContext(cacheProvider).ClearContext(ctx, group)
+	if spErr != nil {
+		spErr = fmt.Errorf("%s: %w", storageProvider.Name(), spErr)
+	}
+	if cpErr != nil {
+		cpErr = fmt.Errorf("%s: %w", cacheProvider.Name(), cpErr)
+	}
+	if err := errors.Join(spErr, cpErr); err != nil {
+		return false, fmt.Errorf("Clear Failing: %w", err)
+	}
+	return (spResult && cpResult), nil
+}
+
+// ensureGroup builds the info of group by the default parameters if it does not exist.
+func (self *IdCenterManager) ensureGroup(ctx context.Context, storageProvider base.ContextStorageProvider, group string) error {
+	groupInfo, err := storageProvider.GetContext(ctx, group)
+	if err != nil {
+		errorMsg := fmt.Sprintf("Occur error when get group (name='%s') info : %s\n", group, err.Error())
+		base.Logger().Error(errorMsg)
+		return err
+	}
+	if groupInfo != nil {
+		return nil
+	}
+	ok, err := self.buildGroup(ctx, storageProvider, group, self.Start, self.Step)
+	// The group which is built by another instance meanwhile is used.
+	if err != nil && !errors.Is(err, base.ErrConflict) {
+		return err
+	}
+	if !ok {
+		warnMsg := fmt.Sprintf("Building group info is FAILING. Maybe the group already exists. (group=%v)", group)
+		base.Logger().Warnln(warnMsg)
+	}
+	return nil
+}
+
+func (self *IdCenterManager) buildGroup(ctx context.Context, storageProvider base.ContextStorageProvider, group string, start uint64, step uint32) (bool, error) {
+	currentStart := start
+	if currentStart <= 0 {
+		currentStart = DEFAULT_START
+	}
+	currentStep := step
+	if currentStep <= 0 {
+		currentStep = DEFAULT_STEP
+	}
+	currentOffset, currentIncrement := self.alignment()
+	ok, err := storageProvider.BuildInfoContext(ctx, group, currentStart, currentStep, currentOffset, currentIncrement)
+	if err != nil {
+		errorMsg := fmt.Sprintf("Occur error when initialize group '%s': %s", group, err.Error())
+		base.Logger().Errorln(errorMsg)
+		return false, err
+	}
+	return ok, nil
+}
+
+// alignment returns the offset and the increment of ids of this instance, which the propelled ranges are aligned with.
+func (self *IdCenterManager) alignment() (uint64, uint32) {
+	offset := self.Offset
+	if offset <= 0 {
+		offset = DEFAULT_OFFSET
+	}
+	increment := self.Increment
+	if increment <= 0 {
+		increment = DEFAULT_INCREMENT
+	}
+	return offset, increment
+}
+
+// resolveGroup returns the name of the group which is actually used by providers.
+// The group of the last period is retired after the retire delay when the period of a periodic group switches.
+func (self *IdCenterManager) resolveGroup(group string) string {
+	groupConfig, contains := self.Groups[group]
+	if !contains || groupConfig.Period == PERIOD_NONE {
+		return group
+	}
+	now := time.Now()
+	currentGroup := periodicGroupName(group, groupConfig.Period, now)
+	self.periodSign.Lock()
+	if self.periodGroups == nil {
+		self.periodGroups = make(map[string]string)
+	}
+	lastGroup, contains := self.periodGroups[group]
+	if lastGroup == currentGroup {
+		self.periodSign.Unlock()
+		return currentGroup
+	}
+	if !contains {
+		lastGroup = periodicGroupName(group, groupConfig.Period, groupConfig.Period.Previous(now))
+	}
+	self.periodGroups[group] = currentGroup
+	self.periodSign.Unlock()
+	retireDelay := self.RetireDelay
+	if retireDelay <= 0 {
+		retireDelay = DEFAULT_RETIRE_DELAY
+	}
+	time.AfterFunc(retireDelay, func() {
+		self.retireGroup(lastGroup)
+	})
+	return currentGroup
+}
+
+// segmentSeed returns the seed of the shuffling of the segment which begins with begin.
+// Every segment of a group is shuffled by a different permutation.
+func (self *IdCenterManager) segmentSeed(group string, begin uint64) int64 {
+	seed := self.Groups[group].ShuffleSeed
+	if seed == 0 {
+		return 0
+	}
+	segmentSeed := seed ^ int64(begin*0x9e3779b97f4a7c15)
+	if segmentSeed == 0 {
+		segmentSeed = seed
+	}
+	return segmentSeed
+}
+
+// retireGroup seals the group of the last period by bounding it at the end of its range, and drops its
+// cached ids. The row is kept rather than cleared, so that it is never built again from the start by a
+// late request or by the instance whose clock lags, which would issue the same ids twice.
+func (self *IdCenterManager) retireGroup(group string) {
+	if !self.enter() {
+		return
+	}
+	defer self.inFlight.Done()
+	base.Logger().Infof("Retire the group '%s' of last period...\n", group)
+	storageProvider, err := self.getStorageProvider(base.OP_UPDATE, group)
+	if err != nil {
+		return
+	}
+	cacheProvider, err := self.getCacheProvider(base.OP_CLEAR, group)
+	if err != nil {
+		return
+	}
+	groupInfo, err := storageProvider.Get(group)
+	if err != nil {
+		base.Logger().Warnf("Retiring the group '%s' is FAILING: %s\n", group, err)
+		return
+	}
+	if groupInfo != nil {
+		bound := groupInfo.Start
+		if groupInfo.Count > 0 {
+			bound = groupInfo.Range.End
 		}
-	}()
-	storageProvider := self.getStorageProvider()
-	spResult, spErr := storageProvider.Clear(group)
-	cacheProvider := self.getCacheProvider()
-	cpResult, cpErr := cacheProvider.Clear(group)
-	if spErr != nil || cpErr != nil {
-		var errorMsgBuffer bytes.Buffer
-		errorMsgBuffer.WriteString("Clear Failing:")
-		if spErr != nil {
-			errorMsgBuffer.WriteString(fmt.Sprintf("%s: %s; ", reflect.TypeOf(storageProvider).Name(), spErr))
+		if bound == 0 {
+			bound = 1
 		}
-		if cpErr != nil {
-			errorMsgBuffer.WriteString(fmt.Sprintf("%s: %s; ", reflect.TypeOf(cacheProvider).Name(), cpErr))
+		if groupInfo.Bound == 0 || groupInfo.Bound > bound {
+			if _, err := storageProvider.Update(group, groupInfo.Step, bound); err != nil {
+				base.Logger().Warnf("Sealing the group '%s' is FAILING: %s\n", group, err)
+				return
+			}
 		}
-		errorMsgBuffer.WriteString("\n")
-		return false, errors.New(errorMsgBuffer.String())
 	}
-	return (spResult && cpResult), nil
+	if _, err := cacheProvider.Clear(group); err != nil {
+		base.Logger().Warnf("Retiring the group '%s' in cache is FAILING: %s\n", group, err)
+	}
+}
+
+func (self *IdCenterManager) registry() *Registry {
+	if self.Registry == nil {
+		return defaultRegistry
+	}
+	return self.Registry
 }
 
-func (self *IdCenterManager) getCacheProvider() base.CacheProvider {
-	cacheProvider, contains := cacheProviderMap[self.CacheProviderName]
+// getCacheProvider returns the registered cache provider of the manager, or the error of op on group
+// whose kind is base.ErrProviderNotFound.
+func (self *IdCenterManager) getCacheProvider(op string, group string) (base.CacheProvider, error) {
+	cacheProvider, contains := self.registry().CacheProvider(self.CacheProviderName)
 	if !contains {
-		panicMsg := fmt.Sprintf("IdCenter: The cache Provider named '%s' is NOTEXISTENT! Please register the provider.\n", self.CacheProviderName)
-		panic(panicMsg)
+		err := base.NewError(base.ErrProviderNotFound, op, group,
+			fmt.Errorf("The cache provider named '%s' is NOTEXISTENT! Please register the provider.", self.CacheProviderName))
+		base.Logger().Errorln(err)
+		return nil, err
 	}
-	return cacheProvider
+	return cacheProvider, nil
 }
 
-func (self *IdCenterManager) getStorageProvider() base.StorageProvider {
-	storageProvider, contains := storageProviderMap[self.StorageProviderName]
+// getStorageProvider returns the registered storage provider of the manager, or the error of op on group
+// whose kind is base.ErrProviderNotFound.
+func (self *IdCenterManager) getStorageProvider(op string, group string) (base.StorageProvider, error) {
+	storageProvider, contains := self.registry().StorageProvider(self.StorageProviderName)
 	if !contains {
-		panicMsg := fmt.Sprintf("IdCenter: The storage Provider named '%s' is NOTEXISTENT! Please register the provider.\n", self.StorageProviderName)
-		panic(panicMsg)
+		err := base.NewError(base.ErrProviderNotFound, op, group,
+			fmt.Errorf("The storage provider named '%s' is NOTEXISTENT! Please register the provider.", self.StorageProviderName))
+		base.Logger().Errorln(err)
+		return nil, err
 	}
-	return storageProvider
+	return storageProvider, nil
 }
@@ -139,7 +139,11 @@ func registerProvidersForTest() (base.CacheProvider, base.StorageProvider, error
 		Password: "haolin",
 		PoolSize: uint16(3),
 	}
-	msp := NewMysqlStorageProvider(storageParameter)
+	msp, err := NewMysqlStorageProvider(storageParameter)
+	if err != nil {
+		errorMsg := fmt.Sprintf("MySQL Storage provider initialization error: %s", err)
+		return nil, nil, errors.New(errorMsg)
+	}
 	err = RegisterProvider(interface{}(msp).(base.Provider))
 	if err != nil {
 		errorMsg := fmt.Sprintf("MySQL Storage provider register error: %s", err)
@@ -147,3 +151,164 @@ func registerProvidersForTest() (base.CacheProvider, base.StorageProvider, error
 	}
 	return rcp, msp, nil
 }
+
+// The cache provider whose pop panics.
+type panickingCacheProvider struct {
+	base.CacheProvider
+}
+
+func (self panickingCacheProvider) Pop(group string) (uint64, error) {
+	panic("unexpected nil")
+}
+
+func TestManagerErrors(t *testing.T) {
+	if err := RegisterProvider(nil); err == nil {
+		t.Errorf("Registering the nil provider should fail.")
+		t.FailNow()
+	}
+	if err := RegisterProvider(NewMemoryCacheProvider("")); err == nil {
+		t.Errorf("Registering the provider without name should fail.")
+		t.FailNow()
+	}
+	sp := NewMemoryStorageProvider("Memory Storage Provider (" + t.Name() + ")")
+	pcp := panickingCacheProvider{NewMemoryCacheProvider("Panicking Cache Provider (" + t.Name() + ")")}
+	RegisterProvider(sp)
+	RegisterProvider(pcp)
+	defer UnregisterProvider(sp)
+	defer UnregisterProvider(pcp)
+
+	// The provider which is not registered
+	idCenterManager := &IdCenterManager{CacheProviderName: "Nonexistent Cache Provider", StorageProviderName: sp.Name(), Start: 1, Step: 10}
+	if _, err := idCenterManager.GetId("manager_errors_test"); !errors.Is(err, base.ErrProviderNotFound) {
+		t.Errorf("The error should be ErrProviderNotFound, but %v.", err)
+		t.FailNow()
+	}
+	if _, err := idCenterManager.Clear("manager_errors_test"); !errors.Is(err, base.ErrProviderNotFound) {
+		t.Errorf("The error should be ErrProviderNotFound, but %v.", err)
+		t.FailNow()
+	}
+	if _, err := idCenterManager.DrainUnusedIds(); !errors.Is(err, base.ErrProviderNotFound) {
+		t.Errorf("The error should be ErrProviderNotFound, but %v.", err)
+		t.FailNow()
+	}
+
+	// The panic of provider is returned as the error.
+	idCenterManager.CacheProviderName = pcp.Name()
+	if _, err := idCenterManager.GetId("manager_errors_test"); !errors.Is(err, base.ErrInternal) {
+		t.Errorf("The error should be ErrInternal, but %v.", err)
+		t.FailNow()
+	}
+	if ok, err := idCenterManager.CreateGroup("manager_errors_test", 1, 10); !ok || err != nil {
+		t.Errorf("The manager should work after the panic, but %v (%v).", ok, err)
+		t.FailNow()
+	}
+
+	// The invalid arguments of the callers.
+	luhn, _ := ParseCheckDigitScheme("luhn")
+	idCenterManager.Groups = map[string]GroupConfig{"check_digit_errors_test": GroupConfig{CheckDigitScheme: luhn}}
+	if _, err := idCenterManager.GetIds("manager_errors_test", MAX_ID_COUNT+1); !errors.Is(err, base.ErrInvalidArgument) {
+		t.Errorf("The error should be ErrInvalidArgument, but %v.", err)
+		t.FailNow()
+	}
+	if _, err := idCenterManager.Decode("check_digit_errors_test", 19); !errors.Is(err, base.ErrInvalidArgument) {
+		t.Errorf("The error should be ErrInvalidArgument, but %v.", err)
+		t.FailNow()
+	}
+	if _, err := idCenterManager.Validate("manager_errors_test", 18); !errors.Is(err, base.ErrInvalidArgument) {
+		t.Errorf("The error should be ErrInvalidArgument, but %v.", err)
+		t.FailNow()
+	}
+	if _, err := luhn.Append(1 << 63); !errors.Is(err, base.ErrInvalidArgument) {
+		t.Errorf("The error should be ErrInvalidArgument, but %v.", err)
+		t.FailNow()
+	}
+}
+
+func TestAlignmentOfInstances(t *testing.T) {
+	registry := NewRegistry()
+	sp := NewMemoryStorageProvider("Memory Storage Provider")
+	firstCp := NewMemoryCacheProvider("First Memory Cache Provider")
+	secondCp := NewMemoryCacheProvider("Second Memory Cache Provider")
+	for _, provider := range []base.Provider{sp, firstCp, secondCp} {
+		if err := registry.Register(provider); err != nil {
+			t.Errorf("Register error: %s", err)
+			t.FailNow()
+		}
+	}
+	// The group exists before, whose offset and increment are the defaults.
+	group := "alignment_test"
+	if ok, err := sp.BuildInfo(group, 1, 10, DEFAULT_OFFSET, DEFAULT_INCREMENT); !ok || err != nil {
+		t.Errorf("BuildInfo is Failing! (%v)", err)
+		t.FailNow()
+	}
+	managers := []*IdCenterManager{
+		{Registry: registry, CacheProviderName: firstCp.Name(), StorageProviderName: sp.Name(), Offset: 1, Increment: 2},
+		{Registry: registry, CacheProviderName: secondCp.Name(), StorageProviderName: sp.Name(), Offset: 2, Increment: 2},
+	}
+	issued := make(map[uint64]int)
+	for i := 0; i < 100; i++ {
+		for index, idCenterManager := range managers {
+			id, err := idCenterManager.GetId(group)
+			if err != nil {
+				t.Errorf("GetId error: %s", err)
+				t.FailNow()
+			}
+			if other, contains := issued[id]; contains {
+				t.Errorf("The id %d is issued by both the instances %d and %d.", id, other, index)
+				t.FailNow()
+			}
+			if id%2 != uint64(index+1)%2 {
+				t.Errorf("The id %d does not belong to the offset of instance %d.", id, index)
+				t.FailNow()
+			}
+			issued[id] = index
+		}
+		if i%7 == 0 {
+			idRange, err := managers[i%2].ReserveRange(group, 3)
+			if err != nil {
+				t.Errorf("ReserveRange error: %s", err)
+				t.FailNow()
+			}
+			for _, id := range idRange.Ids() {
+				if other, contains := issued[id]; contains {
+					t.Errorf("The reserved id %d is issued by the instance %d.", id, other)
+					t.FailNow()
+				}
+				issued[id] = i % 2
+			}
+		}
+	}
+}
+
+func TestReserveRangeOfEncodedGroups(t *testing.T) {
+	registry := NewRegistry()
+	cp := NewMemoryCacheProvider("Memory Cache Provider")
+	sp := NewMemoryStorageProvider("Memory Storage Provider")
+	registry.Register(cp)
+	registry.Register(sp)
+	luhn, err := ParseCheckDigitScheme("luhn")
+	if err != nil {
+		t.Errorf("ParseCheckDigitScheme error: %s", err)
+		t.FailNow()
+	}
+	groupConfigs := map[string]GroupConfig{
+		"check_digit_reserve_test": GroupConfig{CheckDigitScheme: luhn},
+		"obfuscated_reserve_test":  GroupConfig{Obfuscator: NewFeistelObfuscator("id_center_reserve_test")},
+	}
+	idCenterManager := &IdCenterManager{Registry: registry, CacheProviderName: cp.Name(), StorageProviderName: sp.Name(),
+		Start: 1, Step: 10, Groups: groupConfigs}
+	for group := range groupConfigs {
+		if _, err := idCenterManager.GetId(group); err != nil {
+			t.Errorf("GetId error: %s", err)
+			t.FailNow()
+		}
+		if idRange, err := idCenterManager.ReserveRange(group, 3); !errors.Is(err, base.ErrConflict) {
+			t.Errorf("Reserving the range %v of group '%s' should fail with ErrConflict, but %v.", idRange, group, err)
+			t.FailNow()
+		}
+	}
+	if _, err := idCenterManager.ReserveRange("plain_reserve_test", 3); err != nil {
+		t.Errorf("ReserveRange error: %s", err)
+		t.FailNow()
+	}
+}
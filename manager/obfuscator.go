@@ -0,0 +1,58 @@
+package manager
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+)
+
+const (
+	FEISTEL_ROUNDS = 8
+)
+
+// The obfuscator maps the sequential ids of group to non-enumerable ones.
+// It must be bijective so that the obfuscated ids are still unique.
+type Obfuscator interface {
+	Encode(id uint64) uint64
+	Decode(code uint64) uint64
+}
+
+// The feistel obfuscator is a keyed balanced feistel permutation over 64 bits.
+type feistelObfuscator struct {
+	roundKeys [FEISTEL_ROUNDS]uint32
+}
+
+func NewFeistelObfuscator(key string) Obfuscator {
+	digest := sha256.Sum256([]byte(key))
+	obfuscator := feistelObfuscator{}
+	for i := 0; i < FEISTEL_ROUNDS; i++ {
+		obfuscator.roundKeys[i] = binary.BigEndian.Uint32(digest[i*4 : i*4+4])
+	}
+	return &obfuscator
+}
+
+func (self *feistelObfuscator) Encode(id uint64) uint64 {
+	left, right := uint32(id>>32), uint32(id)
+	for i := 0; i < FEISTEL_ROUNDS; i++ {
+		left, right = right, left^feistelRound(right, self.roundKeys[i])
+	}
+	return uint64(left)<<32 | uint64(right)
+}
+
+func (self *feistelObfuscator) Decode(code uint64) uint64 {
+	left, right := uint32(code>>32), uint32(code)
+	for i := FEISTEL_ROUNDS - 1; i >= 0; i-- {
+		left, right = right^feistelRound(left, self.roundKeys[i]), left
+	}
+	return uint64(left)<<32 | uint64(right)
+}
+
+// feistelRound is the round function, a keyed variant of the murmur3 finalizer.
+func feistelRound(half uint32, key uint32) uint32 {
+	x := half ^ key
+	x ^= x >> 16
+	x *= 0x85ebca6b
+	x ^= x >> 13
+	x *= 0xc2b2ae35
+	x ^= x >> 16
+	return x
+}
@@ -0,0 +1,33 @@
+package manager
+
+import (
+	"testing"
+)
+
+func TestFeistelObfuscator(t *testing.T) {
+	obfuscator := NewFeistelObfuscator("id_center_obfuscator_test")
+	anotherObfuscator := NewFeistelObfuscator("another_id_center_obfuscator_test")
+	codes := make(map[uint64]uint64)
+	ids := []uint64{0, 1, 2, 3, 100, 1 << 32, 1<<64 - 1}
+	for i := uint64(1000); i < 11000; i++ {
+		ids = append(ids, i)
+	}
+	for _, id := range ids {
+		code := obfuscator.Encode(id)
+		if previousId, contains := codes[code]; contains {
+			t.Errorf("The ids '%d' and '%d' have the same code '%d'.", previousId, id, code)
+			t.FailNow()
+		}
+		codes[code] = id
+		if decodedId := obfuscator.Decode(code); decodedId != id {
+			t.Errorf("The decoded id '%d' is not equals '%d'. (code=%d)", decodedId, id, code)
+			t.FailNow()
+		}
+		if id > 0 && anotherObfuscator.Encode(id) == code {
+			t.Errorf("The code of id '%d' is same under different keys.", id)
+		}
+	}
+	if obfuscator.Encode(2) == obfuscator.Encode(1)+1 {
+		t.Error("The codes of adjacent ids are adjacent too!")
+	}
+}
@@ -9,6 +9,10 @@ func NewRedisCacheProvider(parameter provider.RedisParameter) base.CacheProvider
 	return interface{}(*provider.NewRedisCacheProvider(parameter)).(base.CacheProvider)
 }
 
-func NewMysqlStorageProvider(parameter provider.MysqlParameter) base.StorageProvider {
-	return interface{}(*provider.NewMysqlStorageProvider(parameter)).(base.StorageProvider)
+func NewMysqlStorageProvider(parameter provider.MysqlParameter) (base.StorageProvider, error) {
+	msp, err := provider.NewMysqlStorageProvider(parameter)
+	if err != nil {
+		return nil, err
+	}
+	return interface{}(*msp).(base.StorageProvider), nil
 }
@@ -0,0 +1,14 @@
+package manager
+
+import (
+	"go_idcenter/base"
+	"go_idcenter/provider/providertest"
+)
+
+func NewMemoryCacheProvider(name string) base.CacheProvider {
+	return interface{}(providertest.NewMemoryCacheProvider(name)).(base.CacheProvider)
+}
+
+func NewMemoryStorageProvider(name string) base.StorageProvider {
+	return interface{}(providertest.NewMemoryStorageProvider(name)).(base.StorageProvider)
+}
@@ -0,0 +1,137 @@
+package manager
+
+import (
+	"errors"
+	"fmt"
+	"go_idcenter/base"
+	"sync"
+)
+
+// The registry which is used by the package functions, e.g. RegisterProvider.
+var defaultRegistry = NewRegistry()
+
+// Registry keeps the cache and storage providers by their names. It is safe for concurrent use,
+// and the managers of different registries can work in one process independently.
+type Registry struct {
+	sign             sync.RWMutex
+	cacheProviders   map[string]base.CacheProvider
+	storageProviders map[string]base.StorageProvider
+}
+
+func NewRegistry() *Registry {
+	return &Registry{
+		cacheProviders:   make(map[string]base.CacheProvider),
+		storageProviders: make(map[string]base.StorageProvider),
+	}
+}
+
+// Register adds provider, whose name must be unique among the providers of its type.
+func (self *Registry) Register(provider base.Provider) (err error) {
+	defer recoverPanic(base.OP_REGISTER, "", &err)
+	name, err := providerName(provider)
+	if err != nil {
+		return err
+	}
+	self.sign.Lock()
+	defer self.sign.Unlock()
+	switch t := interface{}(provider).(type) {
+	case base.CacheProvider:
+		if _, contains := self.cacheProviders[name]; contains {
+			errorMsg := fmt.Sprintf("IdCenter: Repetitive cache provider name '%s'!\n", name)
+			base.Logger().Error(errorMsg)
+			return errors.New(errorMsg)
+		}
+		self.cacheProviders[name] = t
+	case base.StorageProvider:
+		if _, contains := self.storageProviders[name]; contains {
+			errorMsg := fmt.Sprintf("IdCenter: Repetitive storage provider name '%s'!\n", name)
+			base.Logger().Error(errorMsg)
+			return errors.New(errorMsg)
+		}
+		self.storageProviders[name] = t
+	default:
+		errorMsg := fmt.Sprintf("IdCenter: Unexpected Provider type '%T'! (name=%s)\n", t, name)
+		base.Logger().Error(errorMsg)
+		return errors.New(errorMsg)
+	}
+	return nil
+}
+
+// Unregister removes provider. Removing the unregistered one is only warned.
+func (self *Registry) Unregister(provider base.Provider) (err error) {
+	defer recoverPanic(base.OP_UNREGISTER, "", &err)
+	name, err := providerName(provider)
+	if err != nil {
+		return err
+	}
+	self.sign.Lock()
+	defer self.sign.Unlock()
+	switch t := interface{}(provider).(type) {
+	case base.CacheProvider:
+		_, contains := self.cacheProviders[name]
+		if contains {
+			delete(self.cacheProviders, name)
+		} else {
+			base.Logger().Warnf("IdCenter: The cache Provider named '%s' is NOTEXISTENT!\n", name)
+		}
+	case base.StorageProvider:
+		_, contains := self.storageProviders[name]
+		if contains {
+			delete(self.storageProviders, name)
+		} else {
+			base.Logger().Warnf("IdCenter: The storage Provider named '%s' is NOTEXISTENT!\n", name)
+		}
+	default:
+		errorMsg := fmt.Sprintf("IdCenter: Unexpected Provider type '%T'! (name=%s)\n", t, name)
+		base.Logger().Error(errorMsg)
+		return errors.New(errorMsg)
+	}
+	return nil
+}
+
+// CacheProvider returns the cache provider named name. The result is false if it is not registered.
+func (self *Registry) CacheProvider(name string) (base.CacheProvider, bool) {
+	self.sign.RLock()
+	defer self.sign.RUnlock()
+	cacheProvider, contains := self.cacheProviders[name]
+	return cacheProvider, contains
+}
+
+// StorageProvider returns the storage provider named name. The result is false if it is not registered.
+func (self *Registry) StorageProvider(name string) (base.StorageProvider, bool) {
+	self.sign.RLock()
+	defer self.sign.RUnlock()
+	storageProvider, contains := self.storageProviders[name]
+	return storageProvider, contains
+}
+
+// providers returns the snapshot of the registered cache providers and storage providers.
+func (self *Registry) providers() ([]base.CacheProvider, []base.StorageProvider) {
+	self.sign.RLock()
+	defer self.sign.RUnlock()
+	cacheProviders := make([]base.CacheProvider, 0, len(self.cacheProviders))
+	for _, cacheProvider := range self.cacheProviders {
+		cacheProviders = append(cacheProviders, cacheProvider)
+	}
+	storageProviders := make([]base.StorageProvider, 0, len(self.storageProviders))
+	for _, storageProvider := range self.storageProviders {
+		storageProviders = append(storageProviders, storageProvider)
+	}
+	return cacheProviders, storageProviders
+}
+
+// providerName returns the name of provider, or the error if the provider is nil or its name is empty.
+func providerName(provider base.Provider) (string, error) {
+	if provider == nil {
+		errorMsg := "IdCenter: The provider is nil!\n"
+		base.Logger().Error(errorMsg)
+		return "", errors.New(errorMsg)
+	}
+	name := provider.Name()
+	if len(name) == 0 {
+		errorMsg := "IdCenter: The name of provider is empty!\n"
+		base.Logger().Error(errorMsg)
+		return "", errors.New(errorMsg)
+	}
+	return name, nil
+}
@@ -0,0 +1,63 @@
+package manager
+
+import (
+	"errors"
+	"go_idcenter/base"
+	"testing"
+)
+
+func TestRegistry(t *testing.T) {
+	t.Parallel()
+	newManager := func(start uint64) (*IdCenterManager, *Registry) {
+		registry := NewRegistry()
+		// The same names are used in both registries.
+		cp := NewMemoryCacheProvider("Memory Cache Provider")
+		sp := NewMemoryStorageProvider("Memory Storage Provider")
+		if err := registry.Register(cp); err != nil {
+			t.Errorf("Register error: %s", err)
+			t.FailNow()
+		}
+		if err := registry.Register(sp); err != nil {
+			t.Errorf("Register error: %s", err)
+			t.FailNow()
+		}
+		if err := registry.Register(NewMemoryCacheProvider(cp.Name())); err == nil {
+			t.Errorf("Registering the repetitive name should fail.")
+			t.FailNow()
+		}
+		return &IdCenterManager{Registry: registry, CacheProviderName: cp.Name(), StorageProviderName: sp.Name(), Start: start, Step: 10}, registry
+	}
+	firstManager, firstRegistry := newManager(1)
+	secondManager, _ := newManager(1000)
+	for _, expected := range []struct {
+		manager *IdCenterManager
+		id      uint64
+	}{{firstManager, 1}, {secondManager, 1000}, {firstManager, 2}, {secondManager, 1001}} {
+		id, err := expected.manager.GetId("registry_test")
+		if err != nil || id != expected.id {
+			t.Errorf("The id should be %d, but %d (%v).", expected.id, id, err)
+			t.FailNow()
+		}
+	}
+	if _, contains := defaultRegistry.CacheProvider(firstManager.CacheProviderName); contains {
+		t.Errorf("The provider should not be in the default registry.")
+		t.FailNow()
+	}
+	if healths := firstManager.CheckProviders(DEFAULT_HEALTH_CHECK_TIMEOUT); len(healths) != 2 {
+		t.Errorf("The health of 2 providers should be checked, but %v.", healths)
+		t.FailNow()
+	}
+	cp, _ := firstRegistry.CacheProvider(firstManager.CacheProviderName)
+	if err := firstRegistry.Unregister(cp); err != nil {
+		t.Errorf("Unregister error: %s", err)
+		t.FailNow()
+	}
+	if _, err := firstManager.GetId("registry_test"); !errors.Is(err, base.ErrProviderNotFound) {
+		t.Errorf("The error should be ErrProviderNotFound, but %v.", err)
+		t.FailNow()
+	}
+	if id, err := secondManager.GetId("registry_test"); err != nil || id != 1002 {
+		t.Errorf("The id should be 1002, but %d (%v).", id, err)
+		t.FailNow()
+	}
+}
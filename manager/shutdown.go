@@ -0,0 +1,130 @@
+package manager
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go_idcenter/base"
+	"io"
+	"time"
+)
+
+// The report of the ids which are taken from the storage, but never issued. The ids taken into the
+// process memory are in Groups, and they are the original ones, i.e. neither obfuscated nor appended
+// the check digit. The ids retained by the cache provider outside the process are counted in Retained.
+type UnusedIdsReport struct {
+	Time     time.Time           `json:"time"`
+	Provider string              `json:"provider"`
+	Groups   map[string][]uint64 `json:"groups,omitempty"`
+	Retained map[string]uint64   `json:"retained,omitempty"`
+}
+
+// Shutdown rejects the new allocations, and waits for the ones in flight until ctx is done.
+func (self *IdCenterManager) Shutdown(ctx context.Context) error {
+	self.stateSign.Lock()
+	self.shutDown = true
+	self.stateSign.Unlock()
+	done := make(chan struct{})
+	go func() {
+		self.inFlight.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		base.Logger().Infoln("IdCenter: The allocations in flight are drained.")
+		return nil
+	case <-ctx.Done():
+		errorMsg := fmt.Sprintf("IdCenter: Draining the allocations in flight is interrupted: %s", ctx.Err())
+		base.Logger().Warnln(errorMsg)
+		return errors.New(errorMsg)
+	}
+}
+
+// DrainUnusedIds takes the unused ids out of the cache provider which keeps them in the process memory
+// (base.Drainer), or counts the ones of the groups in storage which the cache provider retains outside
+// the process (base.Retainer), e.g. the lengths of the lists in Redis. The result is nil if the cache
+// provider implements neither.
+func (self *IdCenterManager) DrainUnusedIds() (report *UnusedIdsReport, err error) {
+	defer recoverPanic(base.OP_DRAIN, "", &err)
+	cacheProvider, err := self.getCacheProvider(base.OP_DRAIN, "")
+	if err != nil {
+		return nil, err
+	}
+	if drainer, ok := cacheProvider.(base.Drainer); ok {
+		groups, err := drainer.Drain()
+		if err != nil {
+			return nil, err
+		}
+		return &UnusedIdsReport{Time: time.Now(), Provider: cacheProvider.Name(), Groups: groups}, nil
+	}
+	retainer, ok := cacheProvider.(base.Retainer)
+	if !ok {
+		base.Logger().Infof("IdCenter: The unused ids are unknown to the cache provider '%s'.\n", cacheProvider.Name())
+		return nil, nil
+	}
+	storageProvider, err := self.getStorageProvider(base.OP_DRAIN, "")
+	if err != nil {
+		return nil, err
+	}
+	groupInfos, err := storageProvider.List()
+	if err != nil {
+		return nil, err
+	}
+	retained := make(map[string]uint64)
+	for _, groupInfo := range groupInfos {
+		count, err := retainer.Retained(groupInfo.Name)
+		if err != nil {
+			return nil, err
+		}
+		if count > 0 {
+			retained[groupInfo.Name] = count
+		}
+	}
+	base.Logger().Infof("IdCenter: The unused ids are retained by the cache provider '%s'.\n", cacheProvider.Name())
+	return &UnusedIdsReport{Time: time.Now(), Provider: cacheProvider.Name(), Retained: retained}, nil
+}
+
+// CloseProviders closes the providers in the default registry (see Registry.Close).
+func CloseProviders() error {
+	return defaultRegistry.Close()
+}
+
+// Close closes all the registered providers which implement io.Closer, e.g. to close their connection pools.
+func (self *Registry) Close() error {
+	var errorMsgs []string
+	closeProvider := func(provider base.Provider) {
+		closer, ok := provider.(io.Closer)
+		if !ok {
+			return
+		}
+		if err := closer.Close(); err != nil {
+			errorMsgs = append(errorMsgs, fmt.Sprintf("%s: %s", provider.Name(), err))
+			return
+		}
+		base.Logger().Infof("IdCenter: The provider '%s' is closed.\n", provider.Name())
+	}
+	cacheProviders, storageProviders := self.providers()
+	for _, cacheProvider := range cacheProviders {
+		closeProvider(cacheProvider)
+	}
+	for _, storageProvider := range storageProviders {
+		closeProvider(storageProvider)
+	}
+	if len(errorMsgs) > 0 {
+		errorMsg := fmt.Sprintf("IdCenter: Closing providers is FAILING: %v", errorMsgs)
+		base.Logger().Errorln(errorMsg)
+		return errors.New(errorMsg)
+	}
+	return nil
+}
+
+// enter registers an allocation in flight. The result is false if the manager is shut down.
+func (self *IdCenterManager) enter() bool {
+	self.stateSign.RLock()
+	defer self.stateSign.RUnlock()
+	if self.shutDown {
+		return false
+	}
+	self.inFlight.Add(1)
+	return true
+}
@@ -0,0 +1,98 @@
+package manager
+
+import (
+	"context"
+	"go_idcenter/base"
+	"testing"
+	"time"
+)
+
+// The cache provider whose pop is slow.
+type slowCacheProvider struct {
+	base.CacheProvider
+}
+
+func (self slowCacheProvider) Pop(group string) (uint64, error) {
+	time.Sleep(100 * time.Millisecond)
+	return self.CacheProvider.Pop(group)
+}
+
+func (self slowCacheProvider) Drain() (map[string][]uint64, error) {
+	return self.CacheProvider.(base.Drainer).Drain()
+}
+
+func TestShutdown(t *testing.T) {
+	cp := slowCacheProvider{NewMemoryCacheProvider("Slow Memory Cache Provider")}
+	sp := NewMemoryStorageProvider("Memory Storage Provider (" + t.Name() + ")")
+	RegisterProvider(cp)
+	RegisterProvider(sp)
+	defer UnregisterProvider(cp)
+	defer UnregisterProvider(sp)
+	idCenterManager := &IdCenterManager{CacheProviderName: cp.Name(), StorageProviderName: sp.Name(), Start: 1, Step: 10}
+	if id, err := idCenterManager.GetId("shutdown_test"); err != nil || id != 1 {
+		t.Errorf("The id '%d' is not equals '1'. (%v)", id, err)
+		t.FailNow()
+	}
+	result := make(chan uint64, 1)
+	go func() {
+		id, _ := idCenterManager.GetId("shutdown_test")
+		result <- id
+	}()
+	time.Sleep(20 * time.Millisecond)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := idCenterManager.Shutdown(ctx); err != nil {
+		t.Errorf("Shutdown error: %s", err)
+		t.FailNow()
+	}
+	select {
+	case id := <-result:
+		if id != 2 {
+			t.Errorf("The id in flight '%d' is not equals '2'.", id)
+			t.FailNow()
+		}
+	default:
+		t.Errorf("The id in flight is not drained.")
+		t.FailNow()
+	}
+	if _, err := idCenterManager.GetId("shutdown_test"); err == nil {
+		t.Errorf("The id center should be shut down.")
+		t.FailNow()
+	}
+	report, err := idCenterManager.DrainUnusedIds()
+	if err != nil || report == nil || len(report.Groups["shutdown_test"]) != 8 || report.Groups["shutdown_test"][0] != 3 {
+		t.Errorf("Unexpected report of unused ids: %v (%v)", report, err)
+		t.FailNow()
+	}
+}
+
+// The cache provider which retains the ids outside the process, like the Redis one.
+type retainingCacheProvider struct {
+	base.CacheProvider
+	retained map[string]uint64
+}
+
+func (self retainingCacheProvider) Retained(group string) (uint64, error) {
+	return self.retained[group], nil
+}
+
+func TestDrainRetainedIds(t *testing.T) {
+	cp := retainingCacheProvider{NewMemoryCacheProvider("Retaining Cache Provider"), map[string]uint64{"retained_test": 9}}
+	sp := NewMemoryStorageProvider("Memory Storage Provider (" + t.Name() + ")")
+	RegisterProvider(cp)
+	RegisterProvider(sp)
+	defer UnregisterProvider(cp)
+	defer UnregisterProvider(sp)
+	idCenterManager := &IdCenterManager{CacheProviderName: cp.Name(), StorageProviderName: sp.Name(), Start: 1, Step: 10}
+	for _, group := range []string{"retained_test", "empty_test"} {
+		if _, err := idCenterManager.GetId(group); err != nil {
+			t.Errorf("Get id error: %s", err)
+			t.FailNow()
+		}
+	}
+	report, err := idCenterManager.DrainUnusedIds()
+	if err != nil || report == nil || len(report.Groups) != 0 || len(report.Retained) != 1 || report.Retained["retained_test"] != 9 {
+		t.Errorf("Unexpected report of retained ids: %v (%v)", report, err)
+		t.FailNow()
+	}
+}
@@ -0,0 +1,57 @@
+package metrics
+
+import (
+	"go_idcenter/base"
+	"time"
+)
+
+// The operations of latency histograms.
+const (
+	OP_GET_ID     = "get_id"
+	OP_POP        = "pop"
+	OP_BUILD_LIST = "build_list"
+	OP_PROPEL     = "propel"
+)
+
+// The states of connection pools.
+const (
+	POOL_STATE_SIZE   = "size"
+	POOL_STATE_IN_USE = "in_use"
+)
+
+var (
+	IdsIssued = NewCounterVec("idcenter_ids_issued_total",
+		"The count of ids issued.", "group")
+	Refills = NewCounterVec("idcenter_refills_total",
+		"The count of ranges propelled for refilling the cache.", "group")
+	CacheMisses = NewCounterVec("idcenter_cache_misses_total",
+		"The count of pops from the empty list of cache.", "group")
+	OperationDuration = NewHistogramVec("idcenter_operation_duration_seconds",
+		"The latency of operations.", DEFAULT_BUCKETS, "operation")
+	ProviderErrors = NewCounterVec("idcenter_provider_errors_total",
+		"The count of errors returned by providers.", "provider", "operation", "type")
+	PoolConnections = NewGaugeVec("idcenter_pool_connections",
+		"The connections of pools, by the configured size and the ones in use.", "pool", "state")
+)
+
+func init() {
+	Register(IdsIssued)
+	Register(Refills)
+	Register(CacheMisses)
+	Register(OperationDuration)
+	Register(ProviderErrors)
+	Register(PoolConnections)
+}
+
+// ObserveDuration observes the latency of operation which is started at start.
+func ObserveDuration(operation string, start time.Time) {
+	OperationDuration.Observe(time.Since(start).Seconds(), operation)
+}
+
+// CountProviderError counts the error of provider by its type, e.g. '*errors.errorString' (see base.ErrorClass).
+func CountProviderError(providerName string, operation string, err error) {
+	if err == nil {
+		return
+	}
+	ProviderErrors.Inc(providerName, operation, base.ErrorClass(err))
+}
@@ -0,0 +1,299 @@
+// Package metrics collects the metrics of the id center, and exposes them in the
+// text format of Prometheus.
+package metrics
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	CONTENT_TYPE = "text/plain; version=0.0.4; charset=utf-8"
+)
+
+// The default buckets of latency histograms, in seconds.
+var DEFAULT_BUCKETS = []float64{0.0005, 0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5}
+
+type Collector interface {
+	// WriteText writes the metric in the text format of Prometheus.
+	WriteText(writer io.Writer) error
+}
+
+var registrySign sync.Mutex
+var collectors []Collector
+
+// Register adds the collector, which is exposed by WriteText and Handler.
+func Register(collector Collector) {
+	registrySign.Lock()
+	defer registrySign.Unlock()
+	collectors = append(collectors, collector)
+}
+
+// WriteText writes all the registered metrics in the text format of Prometheus.
+func WriteText(writer io.Writer) error {
+	registrySign.Lock()
+	currentCollectors := append([]Collector(nil), collectors...)
+	registrySign.Unlock()
+	for _, collector := range currentCollectors {
+		if err := collector.WriteText(writer); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Handler serves the registered metrics, e.g. on the path /metrics.
+func Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var buffer bytes.Buffer
+		if err := WriteText(&buffer); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", CONTENT_TYPE)
+		w.Header().Set("Content-Length", strconv.Itoa(buffer.Len()))
+		w.Write(buffer.Bytes())
+	})
+}
+
+// The metric vector keeps a series per combination of label values.
+type vector struct {
+	name       string
+	help       string
+	metricType string
+	labelNames []string
+	sign       sync.Mutex
+	series     map[string]*series
+}
+
+type series struct {
+	labelValues []string
+	value       float64
+	// The counts of buckets (non-cumulative) and the sum, only for histograms.
+	bucketCounts []uint64
+	sum          float64
+}
+
+func newVector(name string, help string, metricType string, labelNames []string) vector {
+	return vector{name: name, help: help, metricType: metricType, labelNames: labelNames, series: make(map[string]*series)}
+}
+
+// getSeries returns the series of labelValues. The lock must be held.
+func (self *vector) getSeries(labelValues []string) *series {
+	if len(labelValues) != len(self.labelNames) {
+		panic(fmt.Sprintf("The metric '%s' needs %d label values, but %d given!", self.name, len(self.labelNames), len(labelValues)))
+	}
+	key := strings.Join(labelValues, "\xff")
+	s, contains := self.series[key]
+	if !contains {
+		s = &series{labelValues: append([]string(nil), labelValues...)}
+		self.series[key] = s
+	}
+	return s
+}
+
+// sortedSeries returns the copies of series in the order of label values. The lock must be held.
+func (self *vector) sortedSeries() []series {
+	keys := make([]string, 0, len(self.series))
+	for key := range self.series {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	result := make([]series, 0, len(keys))
+	for _, key := range keys {
+		s := *self.series[key]
+		s.bucketCounts = append([]uint64(nil), s.bucketCounts...)
+		result = append(result, s)
+	}
+	return result
+}
+
+func (self *vector) writeHeader(writer io.Writer) error {
+	_, err := fmt.Fprintf(writer, "# HELP %s %s\n# TYPE %s %s\n", self.name, escapeHelp(self.help), self.name, self.metricType)
+	return err
+}
+
+func (self *vector) labels(labelValues []string, extraName string, extraValue string) string {
+	if len(labelValues) == 0 && len(extraName) == 0 {
+		return ""
+	}
+	var buffer bytes.Buffer
+	buffer.WriteByte('{')
+	for i, labelName := range self.labelNames {
+		if i > 0 {
+			buffer.WriteByte(',')
+		}
+		fmt.Fprintf(&buffer, "%s=\"%s\"", labelName, escapeLabelValue(labelValues[i]))
+	}
+	if len(extraName) > 0 {
+		if len(labelValues) > 0 {
+			buffer.WriteByte(',')
+		}
+		fmt.Fprintf(&buffer, "%s=\"%s\"", extraName, extraValue)
+	}
+	buffer.WriteByte('}')
+	return buffer.String()
+}
+
+type CounterVec struct {
+	vector
+}
+
+func NewCounterVec(name string, help string, labelNames ...string) *CounterVec {
+	return &CounterVec{newVector(name, help, "counter", labelNames)}
+}
+
+func (self *CounterVec) Inc(labelValues ...string) {
+	self.Add(1, labelValues...)
+}
+
+// Add increases the counter by value, which must not be negative.
+func (self *CounterVec) Add(value float64, labelValues ...string) {
+	if value < 0 {
+		return
+	}
+	self.sign.Lock()
+	defer self.sign.Unlock()
+	self.getSeries(labelValues).value += value
+}
+
+func (self *CounterVec) Value(labelValues ...string) float64 {
+	self.sign.Lock()
+	defer self.sign.Unlock()
+	return self.getSeries(labelValues).value
+}
+
+func (self *CounterVec) WriteText(writer io.Writer) error {
+	return writeValues(writer, &self.vector)
+}
+
+type GaugeVec struct {
+	vector
+}
+
+func NewGaugeVec(name string, help string, labelNames ...string) *GaugeVec {
+	return &GaugeVec{newVector(name, help, "gauge", labelNames)}
+}
+
+func (self *GaugeVec) Set(value float64, labelValues ...string) {
+	self.sign.Lock()
+	defer self.sign.Unlock()
+	self.getSeries(labelValues).value = value
+}
+
+func (self *GaugeVec) Add(value float64, labelValues ...string) {
+	self.sign.Lock()
+	defer self.sign.Unlock()
+	self.getSeries(labelValues).value += value
+}
+
+func (self *GaugeVec) Value(labelValues ...string) float64 {
+	self.sign.Lock()
+	defer self.sign.Unlock()
+	return self.getSeries(labelValues).value
+}
+
+func (self *GaugeVec) WriteText(writer io.Writer) error {
+	return writeValues(writer, &self.vector)
+}
+
+func writeValues(writer io.Writer, v *vector) error {
+	v.sign.Lock()
+	allSeries := v.sortedSeries()
+	v.sign.Unlock()
+	if err := v.writeHeader(writer); err != nil {
+		return err
+	}
+	for _, s := range allSeries {
+		if _, err := fmt.Fprintf(writer, "%s%s %s\n", v.name, v.labels(s.labelValues, "", ""), formatFloat(s.value)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type HistogramVec struct {
+	vector
+	buckets []float64
+}
+
+// NewHistogramVec creates the histogram vector whose upper bounds of buckets are buckets, in increasing order.
+func NewHistogramVec(name string, help string, buckets []float64, labelNames ...string) *HistogramVec {
+	return &HistogramVec{vector: newVector(name, help, "histogram", labelNames), buckets: buckets}
+}
+
+func (self *HistogramVec) Observe(value float64, labelValues ...string) {
+	self.sign.Lock()
+	defer self.sign.Unlock()
+	s := self.getSeries(labelValues)
+	if s.bucketCounts == nil {
+		s.bucketCounts = make([]uint64, len(self.buckets)+1)
+	}
+	index := sort.SearchFloat64s(self.buckets, value)
+	s.bucketCounts[index]++
+	s.sum += value
+}
+
+// Count returns the count of observations.
+func (self *HistogramVec) Count(labelValues ...string) uint64 {
+	self.sign.Lock()
+	defer self.sign.Unlock()
+	var count uint64
+	for _, bucketCount := range self.getSeries(labelValues).bucketCounts {
+		count += bucketCount
+	}
+	return count
+}
+
+func (self *HistogramVec) WriteText(writer io.Writer) error {
+	self.sign.Lock()
+	allSeries := self.sortedSeries()
+	self.sign.Unlock()
+	if err := self.writeHeader(writer); err != nil {
+		return err
+	}
+	for _, s := range allSeries {
+		var cumulativeCount uint64
+		for i, bucketCount := range s.bucketCounts {
+			cumulativeCount += bucketCount
+			upperBound := "+Inf"
+			if i < len(self.buckets) {
+				upperBound = formatFloat(self.buckets[i])
+			}
+			labels := self.labels(s.labelValues, "le", upperBound)
+			if _, err := fmt.Fprintf(writer, "%s_bucket%s %d\n", self.name, labels, cumulativeCount); err != nil {
+				return err
+			}
+		}
+		labels := self.labels(s.labelValues, "", "")
+		if _, err := fmt.Fprintf(writer, "%s_sum%s %s\n%s_count%s %d\n", self.name, labels, formatFloat(s.sum), self.name, labels, cumulativeCount); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func formatFloat(value float64) string {
+	switch {
+	case math.IsInf(value, 1):
+		return "+Inf"
+	case math.IsInf(value, -1):
+		return "-Inf"
+	}
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+func escapeHelp(help string) string {
+	return strings.NewReplacer("\\", "\\\\", "\n", "\\n").Replace(help)
+}
+
+func escapeLabelValue(value string) string {
+	return strings.NewReplacer("\\", "\\\\", "\"", "\\\"", "\n", "\\n").Replace(value)
+}
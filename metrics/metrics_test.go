@@ -0,0 +1,62 @@
+package metrics
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestWriteText(t *testing.T) {
+	counter := NewCounterVec("test_total", "The test counter.", "group")
+	counter.Inc("b")
+	counter.Add(2, "a\"")
+	gauge := NewGaugeVec("test_gauge", "The test gauge.")
+	gauge.Set(3)
+	gauge.Add(-1)
+	histogram := NewHistogramVec("test_seconds", "The test histogram.", []float64{0.1, 1}, "operation")
+	histogram.Observe(0.05, "pop")
+	histogram.Observe(0.5, "pop")
+	histogram.Observe(5, "pop")
+	var buffer bytes.Buffer
+	for _, collector := range []Collector{counter, gauge, histogram} {
+		if err := collector.WriteText(&buffer); err != nil {
+			t.Errorf("Write text error: %s", err)
+			t.FailNow()
+		}
+	}
+	expectedText := `# HELP test_total The test counter.
+# TYPE test_total counter
+test_total{group="a\""} 2
+test_total{group="b"} 1
+# HELP test_gauge The test gauge.
+# TYPE test_gauge gauge
+test_gauge 2
+# HELP test_seconds The test histogram.
+# TYPE test_seconds histogram
+test_seconds_bucket{operation="pop",le="0.1"} 1
+test_seconds_bucket{operation="pop",le="1"} 2
+test_seconds_bucket{operation="pop",le="+Inf"} 3
+test_seconds_sum{operation="pop"} 5.55
+test_seconds_count{operation="pop"} 3
+`
+	if buffer.String() != expectedText {
+		t.Errorf("The text %q is not equals %q.", buffer.String(), expectedText)
+		t.FailNow()
+	}
+}
+
+func TestCountProviderError(t *testing.T) {
+	CountProviderError("test provider", OP_POP, errors.New("test"))
+	CountProviderError("test provider", OP_POP, nil)
+	if value := ProviderErrors.Value("test provider", OP_POP, "*errors.errorString"); value != 1 {
+		t.Errorf("The count of provider errors %v is not equals 1.", value)
+		t.FailNow()
+	}
+	var buffer bytes.Buffer
+	WriteText(&buffer)
+	if !strings.Contains(buffer.String(), "# TYPE idcenter_operation_duration_seconds histogram") {
+		t.Errorf("The registered metrics are not written: %s", buffer.String())
+		t.FailNow()
+	}
+}
@@ -0,0 +1,99 @@
+package provider
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	// The sign of group which is not used for this duration is evicted.
+	GROUP_SIGN_IDLE_TIMEOUT = 10 * time.Minute
+)
+
+type groupSign struct {
+	sync.RWMutex
+	// The count of the goroutines which hold or wait for the sign, and the time when it is released
+	// last time. Both of them are guarded by the sign of groupSigns.
+	refs     int
+	lastUsed time.Time
+}
+
+// The signs (locks) of groups, which are created on demand and evicted after they are idle for the
+// idle timeout. It is safe for concurrent use.
+type groupSigns struct {
+	sign        sync.Mutex
+	signs       map[string]*groupSign
+	idleTimeout time.Duration
+	lastEvicted time.Time
+}
+
+func newGroupSigns(idleTimeout time.Duration) *groupSigns {
+	return &groupSigns{signs: make(map[string]*groupSign), idleTimeout: idleTimeout, lastEvicted: time.Now()}
+}
+
+// Set locks the sign of group exclusively. The result must be passed to Unset.
+func (self *groupSigns) Set(group string) *groupSign {
+	sign := self.acquire(group)
+	sign.Lock()
+	return sign
+}
+
+func (self *groupSigns) Unset(sign *groupSign) {
+	sign.Unlock()
+	self.release(sign)
+}
+
+// RSet locks the sign of group for reading. The result must be passed to RUnset.
+func (self *groupSigns) RSet(group string) *groupSign {
+	sign := self.acquire(group)
+	sign.RLock()
+	return sign
+}
+
+func (self *groupSigns) RUnset(sign *groupSign) {
+	sign.RUnlock()
+	self.release(sign)
+}
+
+// Len returns the count of the signs which are kept.
+func (self *groupSigns) Len() int {
+	self.sign.Lock()
+	defer self.sign.Unlock()
+	return len(self.signs)
+}
+
+// acquire returns the sign of group, which is referenced until it is released, so that it is not evicted
+// while it is held or waited for. The idle signs are evicted at most once per idle timeout meanwhile.
+func (self *groupSigns) acquire(group string) *groupSign {
+	self.sign.Lock()
+	defer self.sign.Unlock()
+	sign := self.signs[group]
+	if sign == nil {
+		sign = &groupSign{}
+		self.signs[group] = sign
+	}
+	sign.refs++
+	if now := time.Now(); now.Sub(self.lastEvicted) >= self.idleTimeout {
+		self.evictIdle(now)
+	}
+	return sign
+}
+
+func (self *groupSigns) release(sign *groupSign) {
+	self.sign.Lock()
+	defer self.sign.Unlock()
+	sign.refs--
+	sign.lastUsed = time.Now()
+}
+
+// evictIdle removes the signs which are not referenced and idle for the idle timeout. The sign of
+// groupSigns must be held. The removed sign is not used by anyone, so the one created for its group
+// later excludes the others as well.
+func (self *groupSigns) evictIdle(now time.Time) {
+	for group, sign := range self.signs {
+		if sign.refs == 0 && now.Sub(sign.lastUsed) >= self.idleTimeout {
+			delete(self.signs, group)
+		}
+	}
+	self.lastEvicted = now
+}
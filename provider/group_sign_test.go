@@ -0,0 +1,147 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go_idcenter/base"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestGroupSigns(t *testing.T) {
+	signs := newGroupSigns(time.Millisecond)
+	const goroutineCount = 32
+	const loopCount = 500
+	groups := []string{"sign_test_1", "sign_test_2", "sign_test_3"}
+	// The counts are indexed like groups, so that each one is guarded by the sign of its group only.
+	var counts [3]int
+	var readers [3]int32
+	var waitGroup sync.WaitGroup
+	for i := 0; i < goroutineCount; i++ {
+		waitGroup.Add(1)
+		go func(i int) {
+			defer waitGroup.Done()
+			for j := 0; j < loopCount; j++ {
+				index := (i + j) % len(groups)
+				group := groups[index]
+				if j%4 == 0 {
+					// The readers share the sign, but exclude the writers.
+					sign := signs.RSet(group)
+					atomic.AddInt32(&readers[index], 1)
+					_ = counts[index]
+					atomic.AddInt32(&readers[index], -1)
+					signs.RUnset(sign)
+					continue
+				}
+				sign := signs.Set(group)
+				if n := atomic.LoadInt32(&readers[index]); n != 0 {
+					t.Errorf("The sign of group '%s' is set while %d readers hold it.", group, n)
+				}
+				counts[index]++
+				signs.Unset(sign)
+				// The signs of other groups are created and evicted meanwhile.
+				unique := signs.Set(fmt.Sprintf("sign_test_%d_%d", i, j))
+				signs.Unset(unique)
+			}
+		}(i)
+	}
+	waitGroup.Wait()
+	total := 0
+	for _, count := range counts {
+		total += count
+	}
+	if expected := goroutineCount * loopCount * 3 / 4; total != expected {
+		t.Errorf("The total count should be %d, but %d.", expected, total)
+		t.FailNow()
+	}
+}
+
+func TestGroupSignsEviction(t *testing.T) {
+	signs := newGroupSigns(10 * time.Millisecond)
+	for i := 0; i < 100; i++ {
+		signs.Unset(signs.Set(fmt.Sprintf("sign_test_%d", i)))
+	}
+	held := signs.Set("sign_test_held")
+	time.Sleep(20 * time.Millisecond)
+	// The eviction is triggered by the next acquisition.
+	signs.RUnset(signs.RSet("sign_test_new"))
+	if n := signs.Len(); n != 2 {
+		t.Errorf("Only the held and the new signs should be kept, but %d signs.", n)
+		t.FailNow()
+	}
+	// The held sign still excludes the others after the eviction.
+	acquired := make(chan struct{})
+	go func() {
+		signs.Unset(signs.Set("sign_test_held"))
+		close(acquired)
+	}()
+	select {
+	case <-acquired:
+		t.Errorf("The held sign should not be acquired by another goroutine.")
+		t.FailNow()
+	case <-time.After(20 * time.Millisecond):
+	}
+	signs.Unset(held)
+	<-acquired
+}
+
+// TestProviderSigns stresses the signs through the methods of the providers. The context is
+// cancelled, so that each method sets and unsets the sign of group without the servers.
+func TestProviderSigns(t *testing.T) {
+	signs := newGroupSigns(10 * time.Millisecond)
+	rcp := redisCacheProvider{ProviderName: "Redis Cache Provider (" + t.Name() + ")", signs: signs}
+	msp := mysqlStorageProvider{ProviderName: "MySQL Storage Provider (" + t.Name() + ")", signs: signs}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	idRange := base.IdRange{Begin: 1, End: 11, Increment: 1}
+	calls := []func(group string) error{
+		func(group string) error { _, err := rcp.BuildListContext(ctx, group, idRange, 0); return err },
+		func(group string) error { _, err := rcp.PopContext(ctx, group); return err },
+		func(group string) error { _, err := rcp.ClearContext(ctx, group); return err },
+		func(group string) error { _, err := msp.UpdateContext(ctx, group, 10, 0); return err },
+		func(group string) error { _, err := msp.ForwardContext(ctx, group, 100); return err },
+		func(group string) error { _, err := msp.PropelContext(ctx, group, 1, 1); return err },
+		func(group string) error { _, err := msp.ReserveContext(ctx, group, 10, 1, 1); return err },
+	}
+	var waitGroup sync.WaitGroup
+	for i := 0; i < 16; i++ {
+		waitGroup.Add(1)
+		go func(i int) {
+			defer waitGroup.Done()
+			for j := 0; j < 200; j++ {
+				group := fmt.Sprintf("sign_test_%d", (i+j)%5)
+				if err := calls[(i+j)%len(calls)](group); !errors.Is(err, base.ErrCanceled) {
+					t.Errorf("The call on group '%s' should be cancelled, but: %v", group, err)
+				}
+			}
+		}(i)
+	}
+	waitGroup.Wait()
+
+	// The sign held exclusively blocks the pop of the same group.
+	held := signs.Set("sign_test_held")
+	popped := make(chan struct{})
+	go func() {
+		rcp.PopContext(ctx, "sign_test_held")
+		close(popped)
+	}()
+	select {
+	case <-popped:
+		t.Errorf("The pop should wait for the sign held by another goroutine.")
+		t.FailNow()
+	case <-time.After(20 * time.Millisecond):
+	}
+	signs.Unset(held)
+	<-popped
+
+	// All the signs are released, so they are evicted once idle.
+	time.Sleep(20 * time.Millisecond)
+	signs.RUnset(signs.RSet("sign_test_new"))
+	if n := signs.Len(); n != 1 {
+		t.Errorf("Only the new sign should be kept, but %d signs.", n)
+		t.FailNow()
+	}
+}
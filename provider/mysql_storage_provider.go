@@ -1,20 +1,28 @@
 package provider
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"github.com/ziutek/mymysql/autorc"
+	"github.com/ziutek/mymysql/mysql"
 	_ "github.com/ziutek/mymysql/thrsafe"
 	. "go_idcenter/base"
-	"go_lib"
+	"go_idcenter/metrics"
 	"go_lib/pool"
-	"sync"
 	"time"
 )
 
 const (
-	TABLE_NAME = "group"
-	TIMEOUT_MS = time.Duration(100)
+	TABLE_NAME    = "group"
+	GROUP_COLUMNS = "`name`, `start`, `step`, `offset`, `increment`, `bound`, `count`, `begin`, `end`, `last_modified`"
+	TIMEOUT_MS    = time.Duration(100)
+)
+
+// The names of connection pools in metrics.
+const (
+	POOL_NAME_MYSQL = "mysql"
+	POOL_NAME_REDIS = "redis"
 )
 
 type MysqlParameter struct {
@@ -27,29 +35,19 @@ type MysqlParameter struct {
 	PoolSize uint16
 }
 
+// The provider keeps its own connection pool, so the providers of different parameters can work in one process.
 type mysqlStorageProvider struct {
 	ProviderName string
+	connPool     *pool.Pool
+	connPoolSize int
+	signs        *groupSigns
 }
 
-var storageInitContext sync.Once
-var mysqlConnPool *pool.Pool
-var signMap map[string]*go_lib.Sign
-var iMysqlStorageProvider *mysqlStorageProvider
-
-func NewMysqlStorageProvider(parameter MysqlParameter) *mysqlStorageProvider {
-	storageInitContext.Do(func() {
-		err := initializeForStorageProvider(parameter)
-		if err != nil {
-			panic(err)
-		}
-	})
-	return iMysqlStorageProvider
-}
-
-func initializeForStorageProvider(parameter MysqlParameter) error {
+// NewMysqlStorageProvider creates the provider whose connection pool is initialized by parameter.
+func NewMysqlStorageProvider(parameter MysqlParameter) (*mysqlStorageProvider, error) {
 	mysqlServerAddr := fmt.Sprintf("%v:%v", parameter.Ip, parameter.Port)
 	Logger().Infof("Initialize mysql storage provider (parameter=%v)...", parameter)
-	mysqlConnPool = &pool.Pool{Id: "MySQL Connection Pool", Size: int(parameter.PoolSize)}
+	connPool := &pool.Pool{Id: "MySQL Connection Pool (" + parameter.Name + ")", Size: int(parameter.PoolSize)}
 	initFunc := func() (interface{}, error) {
 		conn := autorc.New("tcp", "", mysqlServerAddr, parameter.User, parameter.Password)
 		conn.Raw.Register("set names utf8")
@@ -61,19 +59,23 @@ func initializeForStorageProvider(parameter MysqlParameter) error {
 		}
 		return conn, nil
 	}
-	err := mysqlConnPool.Init(initFunc)
+	err := connPool.Init(initFunc)
 	if err != nil {
 		errorMsg := fmt.Sprintf("Occur error when mysql connection pool initialization (parameter=%v): %s\n", parameter, err)
 		Logger().Errorln(errorMsg)
-		return errors.New(errorMsg)
+		return nil, errors.New(errorMsg)
 	}
-	signMap = make(map[string]*go_lib.Sign)
-	iMysqlStorageProvider = &mysqlStorageProvider{parameter.Name}
-	return nil
+	metrics.PoolConnections.Add(float64(parameter.PoolSize), POOL_NAME_MYSQL, metrics.POOL_STATE_SIZE)
+	return &mysqlStorageProvider{
+		ProviderName: parameter.Name,
+		connPool:     connPool,
+		connPoolSize: int(parameter.PoolSize),
+		signs:        newGroupSigns(GROUP_SIGN_IDLE_TIMEOUT),
+	}, nil
 }
 
-func getMysqlConnection() (*autorc.Conn, error) {
-	element, ok := mysqlConnPool.Get(TIMEOUT_MS)
+func (self mysqlStorageProvider) getConnection() (*autorc.Conn, error) {
+	element, ok := self.connPool.Get(TIMEOUT_MS)
 	if !ok {
 		errorMsg := fmt.Sprintf("Getting mysql connection is FAILING!")
 		return nil, errors.New(errorMsg)
@@ -90,40 +92,122 @@ func getMysqlConnection() (*autorc.Conn, error) {
 		errorMsg := fmt.Sprintf("The type of element in pool is UNMATCHED! (type=%v)", t)
 		return nil, errors.New(errorMsg)
 	}
+	metrics.PoolConnections.Add(1, POOL_NAME_MYSQL, metrics.POOL_STATE_IN_USE)
 	return conn, nil
 }
 
-func releaseMysqlConnection(conn *autorc.Conn) bool {
+func (self mysqlStorageProvider) releaseConnection(conn *autorc.Conn) bool {
 	if conn == nil {
 		return false
 	}
-	result := mysqlConnPool.Put(conn, TIMEOUT_MS)
+	metrics.PoolConnections.Add(-1, POOL_NAME_MYSQL, metrics.POOL_STATE_IN_USE)
+	result := self.connPool.Put(conn, TIMEOUT_MS)
 	return result
 }
 
+func queryFirst(ctx context.Context, conn *autorc.Conn, sql string) (mysql.Row, mysql.Result, error) {
+	var row mysql.Row
+	var result mysql.Result
+	err := queryContext(ctx, conn, func() (err error) {
+		row, result, err = conn.QueryFirst(sql)
+		return err
+	})
+	return row, result, err
+}
+
+func query(ctx context.Context, conn *autorc.Conn, sql string) ([]mysql.Row, mysql.Result, error) {
+	var rows []mysql.Row
+	var result mysql.Result
+	err := queryContext(ctx, conn, func() (err error) {
+		rows, result, err = conn.Query(sql)
+		return err
+	})
+	return rows, result, err
+}
+
+// queryContext runs the query on conn, which gives up when ctx is done. The retries of autorc are
+// disabled meanwhile, or the interrupted query would be repeated on a new connection, so the query
+// is retried here once after reconnecting, e.g. if the idle connection is closed by the server.
+func queryContext(ctx context.Context, conn *autorc.Conn, query func() error) error {
+	if err := CheckContext(ctx, "query", ""); err != nil {
+		return err
+	}
+	if ctx.Done() == nil {
+		return query()
+	}
+	maxRetries := conn.MaxRetries
+	conn.MaxRetries = -1
+	defer func() { conn.MaxRetries = maxRetries }()
+	err := queryInterruptibly(ctx, conn, query)
+	if err != nil && ctx.Err() == nil && autorc.IsNetErr(err) {
+		if err = conn.Raw.Reconnect(); err == nil {
+			err = queryInterruptibly(ctx, conn, query)
+		}
+	}
+	return err
+}
+
+// queryInterruptibly runs the query, and interrupts it by the deadline of the network connection
+// when ctx is done. The interrupted connection is reconnected, so that it is clean for the next query.
+func queryInterruptibly(ctx context.Context, conn *autorc.Conn, query func() error) error {
+	if !conn.Raw.IsConnected() {
+		if err := conn.Raw.Connect(); err != nil {
+			return err
+		}
+	}
+	netConn := conn.Raw.NetConn()
+	interrupted := make(chan struct{})
+	stop := context.AfterFunc(ctx, func() {
+		netConn.SetDeadline(time.Now())
+		close(interrupted)
+	})
+	err := query()
+	if stop() {
+		return err
+	}
+	<-interrupted
+	if err == nil {
+		netConn.SetDeadline(time.Time{})
+		return nil
+	}
+	if err := conn.Raw.Reconnect(); err != nil {
+		Logger().Warnf("Reconnecting the interrupted mysql connection is FAILING: %s\n", err)
+	}
+	return CheckContext(ctx, "query", "")
+}
+
 func (self mysqlStorageProvider) Name() string {
 	return self.ProviderName
 }
 
-func (self mysqlStorageProvider) BuildInfo(group string, start uint64, step uint32) (bool, error) {
+func (self mysqlStorageProvider) BuildInfo(group string, start uint64, step uint32, offset uint64, increment uint32) (bool, error) {
+	return self.BuildInfoContext(context.Background(), group, start, step, offset, increment)
+}
+
+func (self mysqlStorageProvider) BuildInfoContext(ctx context.Context, group string, start uint64, step uint32, offset uint64, increment uint32) (bool, error) {
 	if len(group) == 0 {
-		errorMsg := fmt.Sprint("The group name is INVALID!")
+		err := NewError(ErrInvalidGroupName, OP_BUILD_INFO, group, nil)
+		Logger().Errorln(err)
+		return false, err
+	}
+	if increment == 0 || offset == 0 || offset > uint64(increment) {
+		errorMsg := fmt.Sprintf("The offset '%v' or the increment '%v' is INVALID!", offset, increment)
 		Logger().Errorln(errorMsg)
 		return false, errors.New(errorMsg)
 	}
-	errorMsgPrefix := fmt.Sprintf("Occur error when build group info (group=%v, start=%v, step=%v)", group, start, step)
-	conn, err := getMysqlConnection()
-	defer releaseMysqlConnection(conn)
+	errorMsgPrefix := fmt.Sprintf("Occur error when build group info (group=%v, start=%v, step=%v, offset=%v, increment=%v)", group, start, step, offset, increment)
+	conn, err := self.getConnection()
+	defer self.releaseConnection(conn)
 	if err != nil {
 		errorMsg := fmt.Sprintf("%s: %s", errorMsgPrefix, err)
 		Logger().Errorln(errorMsg)
-		return false, errors.New(errorMsg)
+		return false, storageError(OP_BUILD_INFO, group, err)
 	}
-	groupInfo, err := self.get(conn, group)
+	groupInfo, err := self.get(ctx, conn, group)
 	if err != nil {
 		errorMsg := fmt.Sprintf("%s: %s", errorMsgPrefix, err)
 		Logger().Errorln(errorMsg)
-		return false, errors.New(errorMsg)
+		return false, storageError(OP_BUILD_INFO, group, err)
 	}
 	if groupInfo != nil {
 		warnMsg := fmt.Sprintf("The group '%s' already exists. IGNORE group info building.", group)
@@ -131,134 +215,316 @@ func (self mysqlStorageProvider) BuildInfo(group string, start uint64, step uint
 		return false, nil
 	}
 	creation_dt := formatTime(time.Now())
-	rawSql := "insert `%s`(`name`, `start`, `step`, `count`, `begin`, `end`, `creation_dt`) values('%s', %v, %v, %v, %v, %v, '%v')"
-	sql := fmt.Sprintf(rawSql, TABLE_NAME, group, start, step, 0, 0, 0, creation_dt)
-	_, _, err = conn.QueryFirst(sql)
+	rawSql := "insert `%s`(`name`, `start`, `step`, `offset`, `increment`, `count`, `begin`, `end`, `creation_dt`) values('%s', %v, %v, %v, %v, %v, %v, %v, '%v')"
+	sql := fmt.Sprintf(rawSql, TABLE_NAME, group, start, step, offset, increment, 0, 0, 0, creation_dt)
+	_, _, err = queryFirst(ctx, conn, sql)
 	if err != nil {
 		errorMsg := fmt.Sprintf("%s (sql=%s): %s", errorMsgPrefix, sql, err)
 		Logger().Errorln(errorMsg)
-		return false, errors.New(errorMsg)
+		// The group is built by another instance after the getting.
+		var mysqlErr *mysql.Error
+		if errors.As(err, &mysqlErr) && mysqlErr.Code == mysql.ER_DUP_ENTRY {
+			return false, NewError(ErrConflict, OP_BUILD_INFO, group, err)
+		}
+		return false, storageError(OP_BUILD_INFO, group, err)
 	}
 	return true, nil
 }
 
 func (self mysqlStorageProvider) Get(group string) (*GroupInfo, error) {
+	return self.GetContext(context.Background(), group)
+}
+
+func (self mysqlStorageProvider) GetContext(ctx context.Context, group string) (*GroupInfo, error) {
 	if len(group) == 0 {
-		errorMsg := fmt.Sprint("The group name is INVALID!")
-		Logger().Errorln(errorMsg)
-		return nil, errors.New(errorMsg)
+		err := NewError(ErrInvalidGroupName, OP_GET, group, nil)
+		Logger().Errorln(err)
+		return nil, err
 	}
 	errorMsgPrefix := fmt.Sprintf("Occur error when get group info (group=%v)", group)
-	conn, err := getMysqlConnection()
-	defer releaseMysqlConnection(conn)
+	conn, err := self.getConnection()
+	defer self.releaseConnection(conn)
 	if err != nil {
 		errorMsg := fmt.Sprintf("%s: %s", errorMsgPrefix, err)
 		Logger().Errorln(errorMsg)
-		return nil, errors.New(errorMsg)
+		return nil, storageError(OP_GET, group, err)
 	}
-	return self.get(conn, group)
+	return self.get(ctx, conn, group)
 }
 
-func (self mysqlStorageProvider) get(conn *autorc.Conn, group string) (*GroupInfo, error) {
+func (self mysqlStorageProvider) get(ctx context.Context, conn *autorc.Conn, group string) (*GroupInfo, error) {
 	errorMsgPrefix := fmt.Sprintf("Occur error when get group info (group=%v)", group)
-	rawSql := "select `start`, `step`, `count`, `begin`, `end`, `last_modified` from `%s` where `name`='%s'"
-	sql := fmt.Sprintf(rawSql, TABLE_NAME, group)
-	row, _, err := conn.QueryFirst(sql)
+	rawSql := "select %s from `%s` where `name`='%s'"
+	sql := fmt.Sprintf(rawSql, GROUP_COLUMNS, TABLE_NAME, group)
+	row, _, err := queryFirst(ctx, conn, sql)
 	if err != nil {
 		errorMsg := fmt.Sprintf("%s (sql=%s): %s", errorMsgPrefix, sql, err)
 		Logger().Errorln(errorMsg)
-		return nil, errors.New(errorMsg)
+		return nil, storageError(OP_GET, group, err)
 	}
 	if row == nil {
 		return nil, nil
 	}
-	start := row.Uint64(0)
-	step := uint32(row.Uint(1))
-	count := row.Uint64(2)
-	begin := row.Uint64(3)
-	end := row.Uint64(4)
-	lastModified := row.Time(5, time.Local)
-	idRange := IdRange{Begin: begin, End: end}
-	groupInfo := GroupInfo{Name: group, Start: start, Step: step, Count: count, Range: idRange, LastModified: lastModified}
-	return &groupInfo, nil
+	return parseGroupInfo(row), nil
+}
+
+func (self mysqlStorageProvider) List() ([]GroupInfo, error) {
+	return self.ListContext(context.Background())
+}
+
+func (self mysqlStorageProvider) ListContext(ctx context.Context) ([]GroupInfo, error) {
+	errorMsgPrefix := "Occur error when list group info"
+	conn, err := self.getConnection()
+	defer self.releaseConnection(conn)
+	if err != nil {
+		errorMsg := fmt.Sprintf("%s: %s", errorMsgPrefix, err)
+		Logger().Errorln(errorMsg)
+		return nil, storageError(OP_LIST, "", err)
+	}
+	rawSql := "select %s from `%s` order by `name`"
+	sql := fmt.Sprintf(rawSql, GROUP_COLUMNS, TABLE_NAME)
+	rows, _, err := query(ctx, conn, sql)
+	if err != nil {
+		errorMsg := fmt.Sprintf("%s (sql=%s): %s", errorMsgPrefix, sql, err)
+		Logger().Errorln(errorMsg)
+		return nil, storageError(OP_LIST, "", err)
+	}
+	groupInfos := make([]GroupInfo, 0, len(rows))
+	for _, row := range rows {
+		groupInfos = append(groupInfos, *parseGroupInfo(row))
+	}
+	return groupInfos, nil
+}
+
+// parseGroupInfo converts the row of GROUP_COLUMNS to group info.
+func parseGroupInfo(row mysql.Row) *GroupInfo {
+	name := row.Str(0)
+	start := row.Uint64(1)
+	step := uint32(row.Uint(2))
+	offset := row.Uint64(3)
+	increment := uint32(row.Uint(4))
+	bound := row.Uint64(5)
+	count := row.Uint64(6)
+	begin := row.Uint64(7)
+	end := row.Uint64(8)
+	lastModified := row.Time(9, time.Local)
+	idRange := IdRange{Begin: begin, End: end, Increment: increment}
+	groupInfo := GroupInfo{Name: name, Start: start, Step: step, Offset: offset, Increment: increment, Bound: bound, Count: count, Range: idRange, LastModified: lastModified}
+	return &groupInfo
+}
+
+func (self mysqlStorageProvider) Update(group string, step uint32, bound uint64) (bool, error) {
+	return self.UpdateContext(context.Background(), group, step, bound)
 }
 
-func (self mysqlStorageProvider) Propel(group string) (*IdRange, error) {
+func (self mysqlStorageProvider) UpdateContext(ctx context.Context, group string, step uint32, bound uint64) (bool, error) {
 	if len(group) == 0 {
-		errorMsg := fmt.Sprint("The group name is INVALID!")
+		err := NewError(ErrInvalidGroupName, OP_UPDATE, group, nil)
+		Logger().Errorln(err)
+		return false, err
+	}
+	if step == 0 {
+		errorMsg := fmt.Sprint("The step is INVALID!")
 		Logger().Errorln(errorMsg)
-		return nil, errors.New(errorMsg)
+		return false, errors.New(errorMsg)
+	}
+	sign := self.signs.Set(group)
+	defer self.signs.Unset(sign)
+	if err := CheckContext(ctx, OP_UPDATE, group); err != nil {
+		return false, err
 	}
-	sign := getSign(group)
-	sign.Set()
-	defer sign.Unset()
+	errorMsgPrefix := fmt.Sprintf("Occur error when update group info (group=%v, step=%v, bound=%v)", group, step, bound)
+	conn, err := self.getConnection()
+	defer self.releaseConnection(conn)
+	if err != nil {
+		errorMsg := fmt.Sprintf("%s: %s", errorMsgPrefix, err)
+		Logger().Errorln(errorMsg)
+		return false, storageError(OP_UPDATE, group, err)
+	}
+	rawSql := "update `%s` set `step`=%v, `bound`=%v where `name`='%s'"
+	sql := fmt.Sprintf(rawSql, TABLE_NAME, step, bound, group)
+	_, result, err := queryFirst(ctx, conn, sql)
+	if err != nil {
+		errorMsg := fmt.Sprintf("%s (sql=%s): %s", errorMsgPrefix, sql, err)
+		Logger().Errorln(errorMsg)
+		return false, storageError(OP_UPDATE, group, err)
+	}
+	return result != nil && result.AffectedRows() > 0, nil
+}
+
+func (self mysqlStorageProvider) Forward(group string, next uint64) (bool, error) {
+	return self.ForwardContext(context.Background(), group, next)
+}
+
+func (self mysqlStorageProvider) ForwardContext(ctx context.Context, group string, next uint64) (bool, error) {
 	if len(group) == 0 {
-		errorMsg := fmt.Sprint("The group name is INVALID!")
+		err := NewError(ErrInvalidGroupName, OP_FORWARD, group, nil)
+		Logger().Errorln(err)
+		return false, err
+	}
+	sign := self.signs.Set(group)
+	defer self.signs.Unset(sign)
+	if err := CheckContext(ctx, OP_FORWARD, group); err != nil {
+		return false, err
+	}
+	errorMsgPrefix := fmt.Sprintf("Occur error when forward group (group=%v, next=%v)", group, next)
+	conn, err := self.getConnection()
+	defer self.releaseConnection(conn)
+	if err != nil {
+		errorMsg := fmt.Sprintf("%s: %s", errorMsgPrefix, err)
 		Logger().Errorln(errorMsg)
-		return nil, errors.New(errorMsg)
+		return false, storageError(OP_FORWARD, group, err)
 	}
-	errorMsgPrefix := fmt.Sprintf("Occur error when propel (group=%v)", group)
-	conn, err := getMysqlConnection()
-	defer releaseMysqlConnection(conn)
+	groupInfo, err := self.get(ctx, conn, group)
 	if err != nil {
 		errorMsg := fmt.Sprintf("%s: %s", errorMsgPrefix, err)
 		Logger().Errorln(errorMsg)
+		return false, storageError(OP_FORWARD, group, err)
+	}
+	if groupInfo == nil {
+		return false, nil
+	}
+	// The first range begins at the start, and the others at the end of the previous one.
+	var sql string
+	if groupInfo.Count == 0 {
+		if groupInfo.Start >= next {
+			return true, nil
+		}
+		sql = fmt.Sprintf("update `%s` set `start`=%v where `name`='%s'", TABLE_NAME, next, group)
+	} else {
+		if groupInfo.Range.End >= next {
+			return true, nil
+		}
+		sql = fmt.Sprintf("update `%s` set `end`=%v where `name`='%s'", TABLE_NAME, next, group)
+	}
+	_, _, err = queryFirst(ctx, conn, sql)
+	if err != nil {
+		errorMsg := fmt.Sprintf("%s (sql=%s): %s", errorMsgPrefix, sql, err)
+		Logger().Errorln(errorMsg)
+		return false, storageError(OP_FORWARD, group, err)
+	}
+	Logger().Infof("MySQL Storage Provider: The group '%s' is forwarded to %v.", group, next)
+	return true, nil
+}
+
+func (self mysqlStorageProvider) Propel(group string, offset uint64, increment uint32) (*IdRange, error) {
+	return self.PropelContext(context.Background(), group, offset, increment)
+}
+
+func (self mysqlStorageProvider) PropelContext(ctx context.Context, group string, offset uint64, increment uint32) (*IdRange, error) {
+	return self.propel(ctx, group, 0, offset, increment)
+}
+
+func (self mysqlStorageProvider) Reserve(group string, size uint64, offset uint64, increment uint32) (*IdRange, error) {
+	return self.ReserveContext(context.Background(), group, size, offset, increment)
+}
+
+func (self mysqlStorageProvider) ReserveContext(ctx context.Context, group string, size uint64, offset uint64, increment uint32) (*IdRange, error) {
+	if size == 0 {
+		errorMsg := fmt.Sprint("The size of range is INVALID!")
+		Logger().Errorln(errorMsg)
 		return nil, errors.New(errorMsg)
 	}
-	groupInfo, err := self.get(conn, group)
+	return self.propel(ctx, group, size, offset, increment)
+}
+
+// propel moves the range of group forward by size ids, or by step ids if size is zero.
+func (self mysqlStorageProvider) propel(ctx context.Context, group string, size uint64, offset uint64, increment uint32) (*IdRange, error) {
+	op := OP_PROPEL
+	if size > 0 {
+		op = OP_RESERVE
+	}
+	if len(group) == 0 {
+		err := NewError(ErrInvalidGroupName, op, group, nil)
+		Logger().Errorln(err)
+		return nil, err
+	}
+	sign := self.signs.Set(group)
+	defer self.signs.Unset(sign)
+	if err := CheckContext(ctx, op, group); err != nil {
+		return nil, err
+	}
+	errorMsgPrefix := fmt.Sprintf("Occur error when propel (group=%v, size=%v)", group, size)
+	conn, err := self.getConnection()
+	defer self.releaseConnection(conn)
 	if err != nil {
 		errorMsg := fmt.Sprintf("%s: %s", errorMsgPrefix, err)
 		Logger().Errorln(errorMsg)
-		return nil, errors.New(errorMsg)
+		return nil, storageError(op, group, err)
+	}
+	groupInfo, err := self.get(ctx, conn, group)
+	if err != nil {
+		errorMsg := fmt.Sprintf("%s: %s", errorMsgPrefix, err)
+		Logger().Errorln(errorMsg)
+		return nil, storageError(op, group, err)
 	}
 	if groupInfo == nil {
 		warnMsg := fmt.Sprintf("The group '%s' not exist. IGNORE propeling.", group)
 		Logger().Warnln(warnMsg)
-		return nil, nil
+		return nil, NewError(ErrGroupNotFound, op, group, nil)
 	}
 	idRange := groupInfo.Range
+	if increment == 0 {
+		increment = 1
+	}
 	var newBegin, newEnd uint64
 	if groupInfo.Count == 0 {
-		newBegin = groupInfo.Start
-		newEnd = groupInfo.Start + uint64(groupInfo.Step)
+		newBegin = Align(groupInfo.Start, offset, increment)
 	} else {
-		newBegin = idRange.End
-		newEnd = idRange.End + uint64(groupInfo.Step)
+		newBegin = Align(idRange.End, offset, increment)
+	}
+	if size == 0 {
+		size = uint64(groupInfo.Step)
+	}
+	newEnd = newBegin + size*uint64(increment)
+	if groupInfo.Bound > 0 {
+		if newBegin >= groupInfo.Bound {
+			errorMsg := fmt.Sprintf("%s: The ids are exhausted! (bound=%v)", errorMsgPrefix, groupInfo.Bound)
+			Logger().Errorln(errorMsg)
+			return nil, NewError(ErrExhausted, op, group, nil)
+		}
+		if newEnd > groupInfo.Bound {
+			newEnd = groupInfo.Bound
+		}
 	}
 	newCount := groupInfo.Count + 1
 	rawSql := "update `%s` set `begin`=%v, `end`=%v, `count`=%v where `name`='%s'"
 	sql := fmt.Sprintf(rawSql, TABLE_NAME, newBegin, newEnd, newCount, group)
-	_, _, err = conn.QueryFirst(sql)
+	_, _, err = queryFirst(ctx, conn, sql)
 	if err != nil {
 		errorMsg := fmt.Sprintf("%s (sql=%s): %s", errorMsgPrefix, sql, err)
 		Logger().Errorln(errorMsg)
-		return nil, errors.New(errorMsg)
+		return nil, storageError(op, group, err)
 	}
-	newIdRange := IdRange{Begin: newBegin, End: newEnd}
+	newIdRange := IdRange{Begin: newBegin, End: newEnd, Increment: increment}
 	return &newIdRange, nil
 }
 
 func (self mysqlStorageProvider) Clear(group string) (bool, error) {
+	return self.ClearContext(context.Background(), group)
+}
+
+func (self mysqlStorageProvider) ClearContext(ctx context.Context, group string) (bool, error) {
 	if len(group) == 0 {
-		errorMsg := fmt.Sprint("The group name is INVALID!")
-		Logger().Errorln(errorMsg)
-		return false, errors.New(errorMsg)
+		err := NewError(ErrInvalidGroupName, OP_CLEAR, group, nil)
+		Logger().Errorln(err)
+		return false, err
 	}
 	errorMsgPrefix := fmt.Sprintf("Occur error when clear group info (group=%v)", group)
-	conn, err := getMysqlConnection()
-	defer releaseMysqlConnection(conn)
+	conn, err := self.getConnection()
+	defer self.releaseConnection(conn)
 	if err != nil {
 		errorMsg := fmt.Sprintf("%s: %s", errorMsgPrefix, err)
 		Logger().Errorln(errorMsg)
-		return false, errors.New(errorMsg)
+		return false, storageError(OP_CLEAR, group, err)
 	}
 	rawSql := "delete from `%s` where `name`='%s'"
 	sql := fmt.Sprintf(rawSql, TABLE_NAME, group)
-	_, result, err := conn.QueryFirst(sql)
+	_, result, err := queryFirst(ctx, conn, sql)
 	if err != nil {
 		errorMsg := fmt.Sprintf("%s (sql=%s): %s", errorMsgPrefix, sql, err)
 		Logger().Errorln(errorMsg)
-		return false, errors.New(errorMsg)
+		return false, storageError(OP_CLEAR, group, err)
 	}
 	var affectedRows uint64 = 0
 	if result != nil {
@@ -268,16 +534,58 @@ func (self mysqlStorageProvider) Clear(group string) (bool, error) {
 	return true, nil
 }
 
-func getSign(group string) *go_lib.Sign {
-	if len(group) == 0 {
-		return nil
+func (self mysqlStorageProvider) CheckHealth() error {
+	return self.CheckHealthContext(context.Background())
+}
+
+func (self mysqlStorageProvider) CheckHealthContext(ctx context.Context) error {
+	conn, err := self.getConnection()
+	defer self.releaseConnection(conn)
+	if err != nil {
+		errorMsg := fmt.Sprintf("Occur error when check health: %s", err)
+		Logger().Errorln(errorMsg)
+		return storageError(OP_CHECK_HEALTH, "", err)
 	}
-	sign := signMap[group]
-	if sign == nil {
-		sign = go_lib.NewSign()
-		signMap[group] = sign
+	if _, _, err := queryFirst(ctx, conn, "select 1"); err != nil {
+		errorMsg := fmt.Sprintf("Occur error when check health (sql=select 1): %s", err)
+		Logger().Errorln(errorMsg)
+		return storageError(OP_CHECK_HEALTH, "", err)
+	}
+	return nil
+}
+
+// Close closes the connections in pool. It should be called after the operations in flight
+// are done, or the connections in use are not closed.
+func (self mysqlStorageProvider) Close() error {
+	closed := 0
+	for closed < self.connPoolSize {
+		conn, err := self.getConnection()
+		if err != nil {
+			break
+		}
+		metrics.PoolConnections.Add(-1, POOL_NAME_MYSQL, metrics.POOL_STATE_IN_USE)
+		if err := conn.Raw.Close(); err != nil {
+			Logger().Warnf("Closing mysql connection is FAILING: %s\n", err)
+		}
+		closed++
+	}
+	metrics.PoolConnections.Add(-float64(self.connPoolSize), POOL_NAME_MYSQL, metrics.POOL_STATE_SIZE)
+	if closed < self.connPoolSize {
+		errorMsg := fmt.Sprintf("Only %d of %d mysql connections are closed!", closed, self.connPoolSize)
+		Logger().Warnln(errorMsg)
+		return errors.New(errorMsg)
+	}
+	return nil
+}
+
+// storageError returns the error of op on group whose cause is err, or err if it is classified already,
+// e.g. by the context.
+func storageError(op string, group string, err error) error {
+	var classifiedErr *Error
+	if errors.As(err, &classifiedErr) {
+		return err
 	}
-	return sign
+	return UnavailableError(ErrStorageUnavailable, op, group, err)
 }
 
 func formatTime(t time.Time) string {
@@ -1,6 +1,7 @@
 package provider
 
 import (
+	"errors"
 	. "go_idcenter/base"
 	// "runtime/debug"
 	"testing"
@@ -22,13 +23,17 @@ func TestMysqlStorageProvider(t *testing.T) {
 		Password: "haolin",
 		PoolSize: uint16(3),
 	}
-	msp := NewMysqlStorageProvider(parameter)
+	msp, err := NewMysqlStorageProvider(parameter)
+	if err != nil {
+		t.Errorf("NewMysqlStorageProvider Error: %s\n", err.Error())
+		t.FailNow()
+	}
 	group := "test"
 	start := uint64(100)
 	step := uint32(1000)
 
 	// Build & Get & Propel
-	ok, err := msp.BuildInfo(group, start, step)
+	ok, err := msp.BuildInfo(group, start, step, 1, 1)
 	if err != nil {
 		t.Errorf("BuildInfo Error: %s\n", err.Error())
 		t.FailNow()
@@ -53,7 +58,7 @@ func TestMysqlStorageProvider(t *testing.T) {
 	var idRange *IdRange
 	var begin, end uint64 = start, start + uint64(step)
 	for i := 1; i <= 100; i++ {
-		idRange, err = msp.Propel(group)
+		idRange, err = msp.Propel(group, 1, 1)
 		if err != nil {
 			t.Errorf("Propel Error: %s", err.Error())
 			t.FailNow()
@@ -74,6 +79,64 @@ func TestMysqlStorageProvider(t *testing.T) {
 		end = end + uint64(step)
 	}
 
+	// Forward
+	ok, err = msp.Forward(group, begin+100)
+	if err != nil || !ok {
+		t.Errorf("Forward is Failing! (%v)", err)
+		t.FailNow()
+	}
+	begin = begin + 100
+
+	// Reserve & Update & List
+	idRange, err = msp.Reserve(group, 10, 1, 1)
+	if err != nil {
+		t.Errorf("Reserve Error: %s", err.Error())
+		t.FailNow()
+	}
+	if idRange == nil || idRange.Begin != begin || idRange.End != begin+10 {
+		t.Errorf("Unexpected reserved range! (%v)", idRange)
+		t.FailNow()
+	}
+	bound := idRange.End + 5
+	ok, err = msp.Update(group, step, bound)
+	if err != nil {
+		t.Errorf("Update Error: %s", err.Error())
+		t.FailNow()
+	}
+	if !ok {
+		t.Error("Update is Failing!")
+		t.FailNow()
+	}
+	idRange, err = msp.Propel(group, 1, 1)
+	if err != nil {
+		t.Errorf("Propel Error: %s", err.Error())
+		t.FailNow()
+	}
+	if idRange == nil || idRange.End != bound {
+		t.Errorf("The range is not bounded! (%v, bound=%v)", idRange, bound)
+		t.FailNow()
+	}
+	_, err = msp.Propel(group, 1, 1)
+	if !errors.Is(err, ErrExhausted) {
+		t.Errorf("The ids are not exhausted! (%v)", err)
+		t.FailNow()
+	}
+	groupInfos, err := msp.List()
+	if err != nil {
+		t.Errorf("List Error: %s", err.Error())
+		t.FailNow()
+	}
+	listed := false
+	for _, groupInfo := range groupInfos {
+		if groupInfo.Name == group && groupInfo.Bound == bound {
+			listed = true
+		}
+	}
+	if !listed {
+		t.Errorf("The group '%s' is not listed!", group)
+		t.FailNow()
+	}
+
 	// Clear
 	ok, err = msp.Clear(group)
 	if err != nil {
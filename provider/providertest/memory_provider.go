@@ -0,0 +1,193 @@
+// Package providertest provides the providers which keep ids in the memory of process,
+// for testing the id center without Redis and MySQL.
+package providertest
+
+import (
+	"fmt"
+	"go_idcenter/base"
+	"sync"
+)
+
+// The memory providers keep ids in the memory of process. The ids are not persistent.
+type memoryCacheProvider struct {
+	name  string
+	sign  sync.Mutex
+	lists map[string][]uint64
+}
+
+func (self *memoryCacheProvider) Name() string {
+	return self.name
+}
+
+func (self *memoryCacheProvider) BuildList(group string, idRange base.IdRange, seed int64) (bool, error) {
+	self.sign.Lock()
+	defer self.sign.Unlock()
+	self.lists[group] = idRange.ShuffledIds(seed)
+	return true, nil
+}
+
+func (self *memoryCacheProvider) Pop(group string) (uint64, error) {
+	self.sign.Lock()
+	defer self.sign.Unlock()
+	list := self.lists[group]
+	if len(list) == 0 {
+		return 0, &base.EmptyListError{Msg: fmt.Sprintf("Empty List! (group=%s)", group)}
+	}
+	self.lists[group] = list[1:]
+	return list[0], nil
+}
+
+func (self *memoryCacheProvider) Clear(group string) (bool, error) {
+	self.sign.Lock()
+	defer self.sign.Unlock()
+	delete(self.lists, group)
+	return true, nil
+}
+
+// CheckHealth always succeeds, because the memory is always reachable.
+func (self *memoryCacheProvider) CheckHealth() error {
+	return nil
+}
+
+func (self *memoryCacheProvider) Drain() (map[string][]uint64, error) {
+	self.sign.Lock()
+	defer self.sign.Unlock()
+	drained := make(map[string][]uint64)
+	for group, list := range self.lists {
+		if len(list) > 0 {
+			drained[group] = list
+		}
+	}
+	self.lists = make(map[string][]uint64)
+	return drained, nil
+}
+
+func NewMemoryCacheProvider(name string) *memoryCacheProvider {
+	return &memoryCacheProvider{name: name, lists: make(map[string][]uint64)}
+}
+
+type memoryStorageProvider struct {
+	name   string
+	sign   sync.Mutex
+	groups map[string]*base.GroupInfo
+}
+
+func NewMemoryStorageProvider(name string) *memoryStorageProvider {
+	return &memoryStorageProvider{name: name, groups: make(map[string]*base.GroupInfo)}
+}
+
+func (self *memoryStorageProvider) Name() string {
+	return self.name
+}
+
+// CheckHealth always succeeds, because the memory is always reachable.
+func (self *memoryStorageProvider) CheckHealth() error {
+	return nil
+}
+
+func (self *memoryStorageProvider) BuildInfo(group string, start uint64, step uint32, offset uint64, increment uint32) (bool, error) {
+	self.sign.Lock()
+	defer self.sign.Unlock()
+	if _, contains := self.groups[group]; contains {
+		return false, nil
+	}
+	self.groups[group] = &base.GroupInfo{Name: group, Start: start, Step: step, Offset: offset, Increment: increment}
+	return true, nil
+}
+
+func (self *memoryStorageProvider) Get(group string) (*base.GroupInfo, error) {
+	self.sign.Lock()
+	defer self.sign.Unlock()
+	groupInfo, contains := self.groups[group]
+	if !contains {
+		return nil, nil
+	}
+	copiedGroupInfo := *groupInfo
+	return &copiedGroupInfo, nil
+}
+
+func (self *memoryStorageProvider) List() ([]base.GroupInfo, error) {
+	self.sign.Lock()
+	defer self.sign.Unlock()
+	groupInfos := make([]base.GroupInfo, 0, len(self.groups))
+	for _, groupInfo := range self.groups {
+		groupInfos = append(groupInfos, *groupInfo)
+	}
+	return groupInfos, nil
+}
+
+func (self *memoryStorageProvider) Update(group string, step uint32, bound uint64) (bool, error) {
+	self.sign.Lock()
+	defer self.sign.Unlock()
+	groupInfo, contains := self.groups[group]
+	if !contains {
+		return false, nil
+	}
+	groupInfo.Step = step
+	groupInfo.Bound = bound
+	return true, nil
+}
+
+func (self *memoryStorageProvider) Forward(group string, next uint64) (bool, error) {
+	self.sign.Lock()
+	defer self.sign.Unlock()
+	groupInfo, contains := self.groups[group]
+	if !contains {
+		return false, nil
+	}
+	if groupInfo.Count == 0 {
+		if groupInfo.Start < next {
+			groupInfo.Start = next
+		}
+	} else if groupInfo.Range.End < next {
+		groupInfo.Range.End = next
+	}
+	return true, nil
+}
+
+func (self *memoryStorageProvider) Propel(group string, offset uint64, increment uint32) (*base.IdRange, error) {
+	return self.Reserve(group, 0, offset, increment)
+}
+
+func (self *memoryStorageProvider) Reserve(group string, size uint64, offset uint64, increment uint32) (*base.IdRange, error) {
+	op := base.OP_PROPEL
+	if size > 0 {
+		op = base.OP_RESERVE
+	}
+	self.sign.Lock()
+	defer self.sign.Unlock()
+	groupInfo, contains := self.groups[group]
+	if !contains {
+		return nil, base.NewError(base.ErrGroupNotFound, op, group, nil)
+	}
+	if increment == 0 {
+		increment = 1
+	}
+	begin := base.Align(groupInfo.Start, offset, increment)
+	if groupInfo.Count > 0 {
+		begin = base.Align(groupInfo.Range.End, offset, increment)
+	}
+	if size == 0 {
+		size = uint64(groupInfo.Step)
+	}
+	end := begin + size*uint64(increment)
+	if groupInfo.Bound > 0 {
+		if begin >= groupInfo.Bound {
+			return nil, base.NewError(base.ErrExhausted, op, group, nil)
+		}
+		if end > groupInfo.Bound {
+			end = groupInfo.Bound
+		}
+	}
+	groupInfo.Range = base.IdRange{Begin: begin, End: end, Increment: increment}
+	groupInfo.Count++
+	idRange := groupInfo.Range
+	return &idRange, nil
+}
+
+func (self *memoryStorageProvider) Clear(group string) (bool, error) {
+	self.sign.Lock()
+	defer self.sign.Unlock()
+	delete(self.groups, group)
+	return true, nil
+}
@@ -0,0 +1,75 @@
+package providertest
+
+import (
+	"errors"
+	"go_idcenter/base"
+	"testing"
+)
+
+func TestMemoryProviders(t *testing.T) {
+	mcp := NewMemoryCacheProvider("Test Memory Cache Provider")
+	msp := NewMemoryStorageProvider("Test Memory Storage Provider")
+	group := "test"
+
+	ok, err := msp.BuildInfo(group, 1, 10, 2, 3)
+	if err != nil || !ok {
+		t.Errorf("BuildInfo is Failing! (%v)", err)
+		t.FailNow()
+	}
+	idRange, err := msp.Propel(group, 2, 3)
+	if err != nil {
+		t.Errorf("Propel Error: %s", err)
+		t.FailNow()
+	}
+	if idRange.Begin != 2 || idRange.End != 32 || idRange.Increment != 3 {
+		t.Errorf("Unexpected range! (%v)", idRange)
+		t.FailNow()
+	}
+	ok, err = mcp.BuildList(group, *idRange, 0)
+	if err != nil || !ok {
+		t.Errorf("BuildList is Failing! (%v)", err)
+		t.FailNow()
+	}
+	for _, expectedId := range []uint64{2, 5, 8} {
+		id, err := mcp.Pop(group)
+		if err != nil || id != expectedId {
+			t.Errorf("The id '%d' is not equals '%d'. (%v)", id, expectedId, err)
+			t.FailNow()
+		}
+	}
+	ok, err = msp.Forward(group, 100)
+	if err != nil || !ok {
+		t.Errorf("Forward is Failing! (%v)", err)
+		t.FailNow()
+	}
+	idRange, err = msp.Propel(group, 2, 3)
+	if err != nil || idRange.Begin != 101 {
+		t.Errorf("The range %v is not forwarded to 101. (%v)", idRange, err)
+		t.FailNow()
+	}
+	if ok, _ := msp.Forward("unknown", 100); ok {
+		t.Errorf("The unknown group should not be forwarded.")
+		t.FailNow()
+	}
+	_, err = msp.Reserve("unknown", 10, 2, 3)
+	var baseErr *base.Error
+	if !errors.Is(err, base.ErrGroupNotFound) || !errors.As(err, &baseErr) || baseErr.Op != base.OP_RESERVE || baseErr.Group != "unknown" {
+		t.Errorf("Unexpected error of reserving from the unknown group: %v", err)
+		t.FailNow()
+	}
+	msp.Update(group, 10, 102)
+	if _, err := msp.Propel(group, 2, 3); !errors.Is(err, base.ErrExhausted) {
+		t.Errorf("The ids should be exhausted: %v", err)
+		t.FailNow()
+	}
+	mcp.Clear(group)
+	_, err = mcp.Pop(group)
+	var emptyListErr *base.EmptyListError
+	if !errors.As(err, &emptyListErr) {
+		t.Errorf("Pop from a cleared list: %v", err)
+	}
+	msp.Clear(group)
+	if groupInfo, _ := msp.Get(group); groupInfo != nil {
+		t.Errorf("The group '%s' is not cleared!", group)
+	}
+}
@@ -1,21 +1,16 @@
 package provider
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"github.com/garyburd/redigo/redis"
 	"go_idcenter/base"
-	"go_lib"
+	"go_idcenter/metrics"
 	"strconv"
-	"sync"
 	"time"
 )
 
-var cacheInitContext sync.Once
-var rwSignMap map[string]*go_lib.RWSign
-var redisPool *redis.Pool
-var iRedisCacheProvider *redisCacheProvider
-
 type RedisParameter struct {
 	Name     string
 	Ip       string
@@ -24,24 +19,20 @@ type RedisParameter struct {
 	PoolSize uint16
 }
 
+// The provider keeps its own connection pool, so the providers of different parameters can work in one process.
 type redisCacheProvider struct {
 	ProviderName string
+	pool         *redis.Pool
+	poolSize     int
+	signs        *groupSigns
 }
 
+// NewRedisCacheProvider creates the provider whose connection pool is configured by parameter.
+// The connections are dialed when they are used.
 func NewRedisCacheProvider(parameter RedisParameter) *redisCacheProvider {
-	cacheInitContext.Do(func() {
-		err := initializeForCacheProvider(parameter)
-		if err != nil {
-			panic(err)
-		}
-	})
-	return iRedisCacheProvider
-}
-
-func initializeForCacheProvider(parameter RedisParameter) error {
 	redisServerAddr := fmt.Sprintf("%v:%v", parameter.Ip, parameter.Port)
 	base.Logger().Infof("Initialize redis cache provider (parameter=%v)...", parameter)
-	redisPool = &redis.Pool{
+	redisPool := &redis.Pool{
 		MaxIdle:     int(parameter.PoolSize),
 		IdleTimeout: 240 * time.Second,
 		Dial: func() (redis.Conn, error) {
@@ -58,82 +49,105 @@ func initializeForCacheProvider(parameter RedisParameter) error {
 			return c, err
 		},
 	}
-	rwSignMap = make(map[string]*go_lib.RWSign)
-	iRedisCacheProvider = &redisCacheProvider{parameter.Name}
-	return nil
+	metrics.PoolConnections.Add(float64(parameter.PoolSize), POOL_NAME_REDIS, metrics.POOL_STATE_SIZE)
+	return &redisCacheProvider{
+		ProviderName: parameter.Name,
+		pool:         redisPool,
+		poolSize:     int(parameter.PoolSize),
+		signs:        newGroupSigns(GROUP_SIGN_IDLE_TIMEOUT),
+	}
 }
 
 func (self redisCacheProvider) Name() string {
 	return self.ProviderName
 }
 
-func (self redisCacheProvider) BuildList(group string, begin uint64, end uint64) (bool, error) {
+func (self redisCacheProvider) BuildList(group string, idRange base.IdRange, seed int64) (bool, error) {
+	return self.BuildListContext(context.Background(), group, idRange, seed)
+}
+
+// BuildListContext is BuildList which gives up between the commands when ctx is done. The ids of range
+// which are not pushed yet are skipped.
+func (self redisCacheProvider) BuildListContext(ctx context.Context, group string, idRange base.IdRange, seed int64) (bool, error) {
 	if len(group) == 0 {
-		errorMsg := fmt.Sprint("The group name is INVALID!")
-		base.Logger().Errorln(errorMsg)
-		return false, errors.New(errorMsg)
+		err := base.NewError(base.ErrInvalidGroupName, base.OP_BUILD_LIST, group, nil)
+		base.Logger().Errorln(err)
+		return false, err
 	}
-	rwSign := getRWSign(group)
-	rwSign.Set()
-	defer rwSign.Unset()
+	sign := self.signs.Set(group)
+	defer self.signs.Unset(sign)
+	if err := base.CheckContext(ctx, base.OP_BUILD_LIST, group); err != nil {
+		return false, err
+	}
+	begin, end := idRange.Begin, idRange.End
 	if (begin <= 0) || (end <= 0) || (begin >= end) {
 		errorMsg := fmt.Sprintf("Invalid Parameter(s)! (begin=%d, end=%d)\n", begin, end)
 		base.Logger().Error(errorMsg)
 		return false, errors.New(errorMsg)
 	}
-	conn := redisPool.Get()
-	defer conn.Close()
+	conn := self.getConnection()
+	defer self.releaseConnection(conn)
 	exists, err := redis.Bool(conn.Do("EXISTS", group))
 	if err != nil {
 		errorMsg := fmt.Sprintf("Redis Error <EXISTS %s>: %s\n ", group, err.Error())
-		base.Logger().Errorf(errorMsg)
-		return false, errors.New(errorMsg)
+		base.Logger().Error(errorMsg)
+		return false, base.UnavailableError(base.ErrCacheUnavailable, base.OP_BUILD_LIST, group, err)
 	}
 	if exists {
 		effectedKeys, err := redis.Int(conn.Do("DEL", group))
 		if err != nil {
 			errorMsg := fmt.Sprintf("Redis Error <DEL %s>: %s\n ", group, err.Error())
 			base.Logger().Error(errorMsg)
-			return false, errors.New(errorMsg)
+			return false, base.UnavailableError(base.ErrCacheUnavailable, base.OP_BUILD_LIST, group, err)
 		}
 		if effectedKeys < 1 {
 			warningMsg := fmt.Sprintf("Redis warning <DEL %s>: seemingly failed.\n ", group)
 			base.Logger().Warn(warningMsg)
 		}
 	}
-	for i := begin; i < end; i++ {
-		length, err := redis.Int(conn.Do("LPUSH", group, i))
+	for _, id := range idRange.ShuffledIds(seed) {
+		if err := base.CheckContext(ctx, base.OP_BUILD_LIST, group); err != nil {
+			return false, err
+		}
+		length, err := redis.Int(conn.Do("LPUSH", group, id))
 		if err != nil {
-			errorMsg := fmt.Sprintf("Redis Error <LPUSH %s %d> (total_length=%d): %s\n ", group, i, length, err.Error())
+			errorMsg := fmt.Sprintf("Redis Error <LPUSH %s %d> (total_length=%d): %s\n ", group, id, length, err.Error())
 			base.Logger().Error(errorMsg)
-			return false, errors.New(errorMsg)
+			return false, base.UnavailableError(base.ErrCacheUnavailable, base.OP_BUILD_LIST, group, err)
 		}
 	}
-	base.Logger().Infof("The list of group '%s' is builded. (begin=%d, end=%d)\n", group, begin, end)
+	base.Logger().Infof("The list of group '%s' is builded. (begin=%d, end=%d, increment=%d, seed=%d)\n", group, begin, end, idRange.Increment, seed)
 	return true, nil
 
 }
 
 func (self redisCacheProvider) Pop(group string) (uint64, error) {
+	return self.PopContext(context.Background(), group)
+}
+
+// PopContext is Pop which gives up when ctx is done before the command is sent.
+func (self redisCacheProvider) PopContext(ctx context.Context, group string) (uint64, error) {
 	if len(group) == 0 {
-		errorMsg := fmt.Sprint("The group name is INVALID!")
-		base.Logger().Errorln(errorMsg)
-		return 0, errors.New(errorMsg)
+		err := base.NewError(base.ErrInvalidGroupName, base.OP_POP, group, nil)
+		base.Logger().Errorln(err)
+		return 0, err
+	}
+	sign := self.signs.RSet(group)
+	defer self.signs.RUnset(sign)
+	if err := base.CheckContext(ctx, base.OP_POP, group); err != nil {
+		return 0, err
 	}
-	rwSign := getRWSign(group)
-	rwSign.RSet()
-	defer rwSign.RUnset()
-	conn := redisPool.Get()
-	defer conn.Close()
+	conn := self.getConnection()
+	defer self.releaseConnection(conn)
 	value, err := conn.Do("RPOP", group)
 	if err != nil {
 		errorMsg := fmt.Sprintf("Redis Error <RPOP %s>: %s\n ", group, err.Error())
 		base.Logger().Error(errorMsg)
-		return 0, errors.New(errorMsg)
+		return 0, base.UnavailableError(base.ErrCacheUnavailable, base.OP_POP, group, err)
 	}
 	if value == nil {
 		errorMsg := fmt.Sprintf("Empty List! (group=%s)", group)
-		return 0, &base.EmptyListError{errorMsg}
+		return 0, &base.EmptyListError{Msg: errorMsg}
 	}
 	baValue := value.([]uint8)
 	number, err := strconv.ParseUint(string(baValue), 10, 64)
@@ -146,34 +160,76 @@ func (self redisCacheProvider) Pop(group string) (uint64, error) {
 }
 
 func (self redisCacheProvider) Clear(group string) (bool, error) {
+	return self.ClearContext(context.Background(), group)
+}
+
+// ClearContext is Clear which gives up when ctx is done before the command is sent.
+func (self redisCacheProvider) ClearContext(ctx context.Context, group string) (bool, error) {
 	if len(group) == 0 {
-		errorMsg := fmt.Sprint("The group name is INVALID!")
-		base.Logger().Errorln(errorMsg)
-		return false, errors.New(errorMsg)
+		err := base.NewError(base.ErrInvalidGroupName, base.OP_CLEAR, group, nil)
+		base.Logger().Errorln(err)
+		return false, err
 	}
-	rwSign := getRWSign(group)
-	rwSign.RSet()
-	defer rwSign.RUnset()
-	conn := redisPool.Get()
-	defer conn.Close()
+	sign := self.signs.RSet(group)
+	defer self.signs.RUnset(sign)
+	if err := base.CheckContext(ctx, base.OP_CLEAR, group); err != nil {
+		return false, err
+	}
+	conn := self.getConnection()
+	defer self.releaseConnection(conn)
 	effectedKeys, err := redis.Int(conn.Do("DEL", group))
 	if err != nil {
 		errorMsg := fmt.Sprintf("Redis Error <DEL %s>: %s\n ", group, err.Error())
 		base.Logger().Error(errorMsg)
-		return false, errors.New(errorMsg)
+		return false, base.UnavailableError(base.ErrCacheUnavailable, base.OP_CLEAR, group, err)
 	}
 	base.Logger().Infof("Redis Cache Provider: The group '%s' is cleared. (affectedKeys=%v)", group, (effectedKeys > 0))
 	return true, nil
 }
 
-func getRWSign(group string) *go_lib.RWSign {
-	if len(group) == 0 {
-		return nil
+func (self redisCacheProvider) CheckHealth() error {
+	return self.CheckHealthContext(context.Background())
+}
+
+func (self redisCacheProvider) CheckHealthContext(ctx context.Context) error {
+	if err := base.CheckContext(ctx, base.OP_CHECK_HEALTH, ""); err != nil {
+		return err
 	}
-	rwSign := rwSignMap[group]
-	if rwSign == nil {
-		rwSign = go_lib.NewRWSign()
-		rwSignMap[group] = rwSign
+	conn := self.getConnection()
+	defer self.releaseConnection(conn)
+	if _, err := conn.Do("PING"); err != nil {
+		errorMsg := fmt.Sprintf("Redis Error <PING>: %s", err)
+		base.Logger().Errorln(errorMsg)
+		return base.UnavailableError(base.ErrCacheUnavailable, base.OP_CHECK_HEALTH, "", err)
 	}
-	return rwSign
+	return nil
+}
+
+// Retained returns the length of the list of group, i.e. the count of ids which are retained by Redis.
+func (self redisCacheProvider) Retained(group string) (uint64, error) {
+	conn := self.getConnection()
+	defer self.releaseConnection(conn)
+	length, err := redis.Uint64(conn.Do("LLEN", group))
+	if err != nil {
+		errorMsg := fmt.Sprintf("Redis Error <LLEN %s>: %s\n ", group, err.Error())
+		base.Logger().Error(errorMsg)
+		return 0, base.UnavailableError(base.ErrCacheUnavailable, base.OP_DRAIN, group, err)
+	}
+	return length, nil
+}
+
+// Close closes the connection pool. The ids in the lists are retained by Redis.
+func (self redisCacheProvider) Close() error {
+	metrics.PoolConnections.Add(-float64(self.poolSize), POOL_NAME_REDIS, metrics.POOL_STATE_SIZE)
+	return self.pool.Close()
+}
+
+func (self redisCacheProvider) getConnection() redis.Conn {
+	metrics.PoolConnections.Add(1, POOL_NAME_REDIS, metrics.POOL_STATE_IN_USE)
+	return self.pool.Get()
+}
+
+func (self redisCacheProvider) releaseConnection(conn redis.Conn) {
+	metrics.PoolConnections.Add(-1, POOL_NAME_REDIS, metrics.POOL_STATE_IN_USE)
+	conn.Close()
 }
@@ -23,7 +23,7 @@ func TestRedisCacheProvider(t *testing.T) {
 	group := "test"
 
 	// Build & Pop
-	ok, err := rcp.BuildList(group, 1, 100)
+	ok, err := rcp.BuildList(group, base.IdRange{Begin: 1, End: 100}, 0)
 	if err != nil {
 		t.Errorf("BuildList Error: %s\n", err.Error())
 		t.FailNow()
@@ -53,7 +53,7 @@ func TestRedisCacheProvider(t *testing.T) {
 	}
 
 	// Build & Clear
-	ok, err = rcp.BuildList(group, 1, 100)
+	ok, err = rcp.BuildList(group, base.IdRange{Begin: 1, End: 100}, 0)
 	if err != nil {
 		t.Errorf("BuildList Error: %s\n", err.Error())
 		t.FailNow()
@@ -0,0 +1,255 @@
+// Package ratelimit limits the allocations of clients and groups by token buckets, in which
+// a token is an id.
+package ratelimit
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// The kinds of limits.
+const (
+	KIND_CLIENT = "client"
+	KIND_GROUP  = "group"
+)
+
+const (
+	CONFIG_PREFIX = "rate_limit."
+	// The key of the default limit of the clients or the groups which are not configured.
+	DEFAULT_KEY = "*"
+	// The interval of evicting the idle buckets, which are full and so equal to the new ones.
+	EVICTION_INTERVAL = time.Minute
+)
+
+type Limit struct {
+	// The ids per second.
+	Rate float64
+	// The max ids in a burst.
+	Burst float64
+}
+
+// ParseLimit parses the limit in the form '<rate>[/<burst>]', e.g. '100/200'. The burst is the rate by default.
+func ParseLimit(literal string) (Limit, error) {
+	parts := strings.SplitN(literal, "/", 2)
+	rate, err := strconv.ParseFloat(strings.TrimSpace(parts[0]), 64)
+	if err != nil || rate <= 0 || math.IsInf(rate, 0) {
+		return Limit{}, fmt.Errorf("The rate of limit '%s' is INVALID!", literal)
+	}
+	burst := math.Max(rate, 1)
+	if len(parts) == 2 {
+		burst, err = strconv.ParseFloat(strings.TrimSpace(parts[1]), 64)
+		if err != nil || burst < 1 || math.IsInf(burst, 0) {
+			return Limit{}, fmt.Errorf("The burst of limit '%s' is INVALID!", literal)
+		}
+	}
+	return Limit{Rate: rate, Burst: burst}, nil
+}
+
+type bucket struct {
+	limit     Limit
+	tokens    float64
+	updatedAt time.Time
+	allowed   uint64
+	rejected  uint64
+}
+
+func (self *bucket) refill(now time.Time) {
+	self.tokens = math.Min(self.limit.Burst, self.tokens+now.Sub(self.updatedAt).Seconds()*self.limit.Rate)
+	self.updatedAt = now
+}
+
+// wait returns the duration until n tokens are available. The bucket must be refilled.
+// At most the burst is waited for, so that a request larger than the burst is allowed
+// once the bucket is full, and its excess is paid by the following requests.
+func (self *bucket) wait(n float64) time.Duration {
+	n = math.Min(n, self.limit.Burst)
+	if self.tokens >= n {
+		return 0
+	}
+	return time.Duration((n - self.tokens) / self.limit.Rate * float64(time.Second))
+}
+
+type Usage struct {
+	Kind      string  `json:"kind"`
+	Key       string  `json:"key"`
+	Rate      float64 `json:"rate"`
+	Burst     float64 `json:"burst"`
+	Available float64 `json:"available"`
+	Allowed   uint64  `json:"allowed"`
+	Rejected  uint64  `json:"rejected"`
+}
+
+// The limiter keeps a token bucket per client and per group. A request is allowed only if
+// both the bucket of its client and the one of its group have a token for each of its ids.
+type Limiter struct {
+	// The limits by kind and key. The key DEFAULT_KEY is for the ones not configured.
+	limits    map[string]map[string]Limit
+	sign      sync.Mutex
+	buckets   map[string]map[string]*bucket
+	evictedAt time.Time
+	now       func() time.Time
+}
+
+func NewLimiter() *Limiter {
+	return &Limiter{
+		limits:    map[string]map[string]Limit{KIND_CLIENT: {}, KIND_GROUP: {}},
+		buckets:   map[string]map[string]*bucket{KIND_CLIENT: {}, KIND_GROUP: {}},
+		evictedAt: time.Now(),
+		now:       time.Now,
+	}
+}
+
+// SetLimit sets the limit of the client or the group key, or the default one by DEFAULT_KEY.
+func (self *Limiter) SetLimit(kind string, key string, limit Limit) error {
+	limits, contains := self.limits[kind]
+	if !contains {
+		return fmt.Errorf("The kind of limit '%s' is INVALID!", kind)
+	}
+	if len(key) == 0 {
+		return fmt.Errorf("The key of %s limit is empty!", kind)
+	}
+	if limit.Rate <= 0 || limit.Burst < 1 {
+		return fmt.Errorf("The %s limit of '%s' is INVALID! (rate=%v, burst=%v)", kind, key, limit.Rate, limit.Burst)
+	}
+	self.sign.Lock()
+	defer self.sign.Unlock()
+	limits[key] = limit
+	delete(self.buckets[kind], key)
+	return nil
+}
+
+// Allow takes a token for the request of one id of client on group. If it is rejected, the
+// result is false with the duration after which the request may be allowed.
+func (self *Limiter) Allow(client string, group string) (bool, time.Duration) {
+	return self.AllowN(client, group, 1)
+}
+
+// AllowN takes n tokens for the request of n ids of client on group, e.g. the size of a
+// reserved range. If it is rejected, the result is false with the duration after which
+// the request may be allowed.
+func (self *Limiter) AllowN(client string, group string, n uint64) (bool, time.Duration) {
+	cost := float64(n)
+	self.sign.Lock()
+	defer self.sign.Unlock()
+	now := self.now()
+	if now.Sub(self.evictedAt) >= EVICTION_INTERVAL {
+		self.evict(now)
+	}
+	clientBucket := self.getBucket(KIND_CLIENT, client, now)
+	groupBucket := self.getBucket(KIND_GROUP, group, now)
+	var wait time.Duration
+	for _, b := range []*bucket{clientBucket, groupBucket} {
+		if b != nil && b.wait(cost) > wait {
+			wait = b.wait(cost)
+		}
+	}
+	for _, b := range []*bucket{clientBucket, groupBucket} {
+		if b == nil {
+			continue
+		}
+		if wait > 0 {
+			b.rejected++
+			continue
+		}
+		b.tokens -= cost
+		b.allowed++
+	}
+	return wait == 0, wait
+}
+
+// getBucket returns the refilled bucket of key, or nil if it is unlimited. The lock must be held.
+func (self *Limiter) getBucket(kind string, key string, now time.Time) *bucket {
+	b, contains := self.buckets[kind][key]
+	if !contains {
+		limit, contains := self.limits[kind][key]
+		if !contains {
+			limit, contains = self.limits[kind][DEFAULT_KEY]
+		}
+		if !contains {
+			return nil
+		}
+		b = &bucket{limit: limit, tokens: limit.Burst, updatedAt: now}
+		self.buckets[kind][key] = b
+	}
+	b.refill(now)
+	return b
+}
+
+// evict removes the full buckets, so that the ones of the clients and the groups seen once
+// are not kept forever. Their counts of requests are reset. The lock must be held.
+func (self *Limiter) evict(now time.Time) {
+	for _, buckets := range self.buckets {
+		for key, b := range buckets {
+			b.refill(now)
+			if b.tokens >= b.limit.Burst {
+				delete(buckets, key)
+			}
+		}
+	}
+	self.evictedAt = now
+}
+
+// Usage returns the current usage of the buckets, in the order of kind and key.
+func (self *Limiter) Usage() []Usage {
+	self.sign.Lock()
+	defer self.sign.Unlock()
+	now := self.now()
+	usages := make([]Usage, 0)
+	for _, kind := range []string{KIND_CLIENT, KIND_GROUP} {
+		for key, b := range self.buckets[kind] {
+			b.refill(now)
+			usages = append(usages, Usage{
+				Kind:      kind,
+				Key:       key,
+				Rate:      b.limit.Rate,
+				Burst:     b.limit.Burst,
+				Available: math.Floor(b.tokens),
+				Allowed:   b.allowed,
+				Rejected:  b.rejected,
+			})
+		}
+	}
+	sort.Slice(usages, func(i, j int) bool {
+		if usages[i].Kind != usages[j].Kind {
+			return usages[i].Kind < usages[j].Kind
+		}
+		return usages[i].Key < usages[j].Key
+	})
+	return usages
+}
+
+// LoadConfig creates the limiter by the options in the form 'rate_limit.<kind>.<key>=<rate>[/<burst>]',
+// where the kind is 'client' or 'group', and the key '*' is for the ones not configured, e.g.
+//
+//	rate_limit.client.*=100/200
+//	rate_limit.group.order=1000
+//
+// The result is nil if no limit is configured.
+func LoadConfig(dict map[string]string) (*Limiter, error) {
+	var limiter *Limiter
+	for key, value := range dict {
+		if !strings.HasPrefix(key, CONFIG_PREFIX) {
+			continue
+		}
+		parts := strings.SplitN(key[len(CONFIG_PREFIX):], ".", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("The rate limit key '%s' is INVALID!", key)
+		}
+		limit, err := ParseLimit(value)
+		if err != nil {
+			return nil, err
+		}
+		if limiter == nil {
+			limiter = NewLimiter()
+		}
+		if err := limiter.SetLimit(parts[0], parts[1], limit); err != nil {
+			return nil, err
+		}
+	}
+	return limiter, nil
+}
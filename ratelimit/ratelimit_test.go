@@ -0,0 +1,150 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+type testClock struct {
+	current time.Time
+}
+
+func (self *testClock) now() time.Time {
+	return self.current
+}
+
+func newTestLimiter(clock *testClock) *Limiter {
+	limiter := NewLimiter()
+	limiter.now = clock.now
+	limiter.evictedAt = clock.current
+	return limiter
+}
+
+func TestParseLimit(t *testing.T) {
+	cases := map[string]Limit{
+		"100":     {Rate: 100, Burst: 100},
+		"0.5":     {Rate: 0.5, Burst: 1},
+		"10/50":   {Rate: 10, Burst: 50},
+		" 2 / 3 ": {Rate: 2, Burst: 3},
+	}
+	for literal, expected := range cases {
+		limit, err := ParseLimit(literal)
+		if err != nil || limit != expected {
+			t.Errorf("Unexpected limit %v of '%s'. (%v)", limit, literal, err)
+		}
+	}
+	for _, literal := range []string{"", "0", "-1", "abc", "10/0", "10/x", "+Inf"} {
+		if _, err := ParseLimit(literal); err == nil {
+			t.Errorf("The limit '%s' should be INVALID.", literal)
+		}
+	}
+}
+
+func TestLimiter(t *testing.T) {
+	clock := &testClock{current: time.Unix(1000, 0)}
+	limiter := newTestLimiter(clock)
+	limiter.SetLimit(KIND_CLIENT, DEFAULT_KEY, Limit{Rate: 10, Burst: 3})
+	limiter.SetLimit(KIND_GROUP, "order", Limit{Rate: 1, Burst: 3})
+
+	for i := 0; i < 3; i++ {
+		if ok, _ := limiter.Allow("job", "user"); !ok {
+			t.Errorf("The request %d in the burst should be allowed.", i)
+			t.FailNow()
+		}
+	}
+	ok, wait := limiter.Allow("job", "user")
+	if ok || wait != 100*time.Millisecond {
+		t.Errorf("The request should be rejected for 100ms, but: %v, %s", ok, wait)
+		t.FailNow()
+	}
+	clock.current = clock.current.Add(100 * time.Millisecond)
+	if ok, _ := limiter.Allow("job", "user"); !ok {
+		t.Errorf("The request should be allowed after the refilling.")
+		t.FailNow()
+	}
+	// The client 'web' has its own bucket, but shares the bucket of group 'order' with 'job'.
+	for i := 0; i < 3; i++ {
+		limiter.Allow("web", "order")
+	}
+	clock.current = clock.current.Add(time.Second)
+	if ok, _ := limiter.Allow("job", "order"); !ok {
+		t.Errorf("The request should be allowed by the group 'order'.")
+		t.FailNow()
+	}
+	ok, wait = limiter.Allow("job", "order")
+	if ok || wait != time.Second {
+		t.Errorf("The request should be rejected by the group 'order' for 1s, but: %v, %s", ok, wait)
+		t.FailNow()
+	}
+
+	usages := limiter.Usage()
+	if len(usages) != 3 || usages[0].Key != "job" || usages[1].Key != "web" || usages[2].Key != "order" {
+		t.Errorf("Unexpected usages: %v", usages)
+		t.FailNow()
+	}
+	if usages[0].Allowed != 5 || usages[0].Rejected != 2 || usages[2].Allowed != 4 || usages[2].Rejected != 1 || usages[2].Available != 0 {
+		t.Errorf("Unexpected usages: %v", usages)
+	}
+
+	clock.current = clock.current.Add(EVICTION_INTERVAL)
+	limiter.Allow("job", "user")
+	if usages := limiter.Usage(); len(usages) != 1 || usages[0].Key != "job" || usages[0].Allowed != 1 {
+		t.Errorf("The idle buckets should be evicted, but: %v", usages)
+	}
+}
+
+func TestLimiterAllowN(t *testing.T) {
+	clock := &testClock{current: time.Unix(1000, 0)}
+	limiter := newTestLimiter(clock)
+	limiter.SetLimit(KIND_GROUP, DEFAULT_KEY, Limit{Rate: 10, Burst: 20})
+
+	if ok, _ := limiter.AllowN("job", "order", 15); !ok {
+		t.Errorf("The request of 15 ids in the burst should be allowed.")
+		t.FailNow()
+	}
+	ok, wait := limiter.AllowN("job", "order", 10)
+	if ok || wait != 500*time.Millisecond {
+		t.Errorf("The request of 10 ids should be rejected for 500ms, but: %v, %s", ok, wait)
+		t.FailNow()
+	}
+	clock.current = clock.current.Add(1500 * time.Millisecond)
+	// The request larger than the burst waits for the full bucket only, and leaves a debt.
+	if ok, _ := limiter.AllowN("job", "order", 50); !ok {
+		t.Errorf("The request of 50 ids should be allowed by the full bucket.")
+		t.FailNow()
+	}
+	ok, wait = limiter.Allow("job", "order")
+	if ok || wait != 3100*time.Millisecond {
+		t.Errorf("The request should be rejected for 3.1s after the debt, but: %v, %s", ok, wait)
+		t.FailNow()
+	}
+}
+
+func TestLoadConfig(t *testing.T) {
+	limiter, err := LoadConfig(map[string]string{"id_start": "1"})
+	if err != nil || limiter != nil {
+		t.Errorf("No limiter should be created without limits. (%v)", err)
+		t.FailNow()
+	}
+	limiter, err = LoadConfig(map[string]string{"rate_limit.client.*": "100/200", "rate_limit.group.order": "1000"})
+	if err != nil || limiter.limits[KIND_CLIENT][DEFAULT_KEY] != (Limit{100, 200}) || limiter.limits[KIND_GROUP]["order"] != (Limit{1000, 1000}) {
+		t.Errorf("Unexpected limits %v. (%v)", limiter, err)
+		t.FailNow()
+	}
+	for _, dict := range []map[string]string{{"rate_limit.user.a": "1"}, {"rate_limit.client": "1"}, {"rate_limit.group.order": "x"}} {
+		if _, err := LoadConfig(dict); err == nil {
+			t.Errorf("The config %v should be refused.", dict)
+		}
+	}
+}
+
+func BenchmarkLimiterAllow(b *testing.B) {
+	limiter := NewLimiter()
+	limiter.SetLimit(KIND_CLIENT, DEFAULT_KEY, Limit{Rate: 1e9, Burst: 1e9})
+	limiter.SetLimit(KIND_GROUP, DEFAULT_KEY, Limit{Rate: 1e9, Burst: 1e9})
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			limiter.Allow("client", "group")
+		}
+	})
+}
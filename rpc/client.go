@@ -0,0 +1,113 @@
+package rpc
+
+import (
+	"context"
+
+	"google.golang.org/grpc"
+)
+
+// The Go client of the id center service.
+type Client struct {
+	conn *grpc.ClientConn
+}
+
+func NewClient(conn *grpc.ClientConn) *Client {
+	return &Client{conn: conn}
+}
+
+func (self *Client) invoke(ctx context.Context, method string, request interface{}, reply interface{}) error {
+	return self.conn.Invoke(ctx, "/"+SERVICE_NAME+"/"+method, request, reply)
+}
+
+func (self *Client) GetId(ctx context.Context, group string) (uint64, error) {
+	reply := &IdReply{}
+	if err := self.invoke(ctx, "GetId", &GroupRequest{Group: group}, reply); err != nil {
+		return 0, err
+	}
+	return reply.Id, nil
+}
+
+func (self *Client) GetIds(ctx context.Context, group string, count int) ([]uint64, error) {
+	reply := &IdsReply{}
+	if err := self.invoke(ctx, "GetIds", &IdsRequest{Group: group, Count: int32(count)}, reply); err != nil {
+		return nil, err
+	}
+	return reply.Ids, nil
+}
+
+func (self *Client) ReserveRange(ctx context.Context, group string, size uint64) (*RangeReply, error) {
+	reply := &RangeReply{}
+	if err := self.invoke(ctx, "ReserveRange", &RangeRequest{Group: group, Size: size}, reply); err != nil {
+		return nil, err
+	}
+	return reply, nil
+}
+
+func (self *Client) CreateGroup(ctx context.Context, group string, start uint64, step uint32) (*GroupReply, error) {
+	reply := &GroupReply{}
+	if err := self.invoke(ctx, "CreateGroup", &CreateGroupRequest{Group: group, Start: start, Step: step}, reply); err != nil {
+		return nil, err
+	}
+	return reply, nil
+}
+
+func (self *Client) GetGroup(ctx context.Context, group string) (*GroupReply, error) {
+	reply := &GroupReply{}
+	if err := self.invoke(ctx, "GetGroup", &GroupRequest{Group: group}, reply); err != nil {
+		return nil, err
+	}
+	return reply, nil
+}
+
+func (self *Client) ListGroups(ctx context.Context) ([]*GroupReply, error) {
+	reply := &GroupListReply{}
+	if err := self.invoke(ctx, "ListGroups", &GroupRequest{}, reply); err != nil {
+		return nil, err
+	}
+	return reply.Groups, nil
+}
+
+// UpdateGroup updates the step and the bound of group, and the nil one keeps the current one.
+func (self *Client) UpdateGroup(ctx context.Context, group string, step *uint32, bound *uint64) (*GroupReply, error) {
+	reply := &GroupReply{}
+	if err := self.invoke(ctx, "UpdateGroup", &UpdateGroupRequest{Group: group, Step: step, Bound: bound}, reply); err != nil {
+		return nil, err
+	}
+	return reply, nil
+}
+
+func (self *Client) DeleteGroup(ctx context.Context, group string) (bool, error) {
+	reply := &ResultReply{}
+	if err := self.invoke(ctx, "DeleteGroup", &GroupRequest{Group: group}, reply); err != nil {
+		return false, err
+	}
+	return reply.Result, nil
+}
+
+// IdStream receives the ids pushed by the server.
+type IdStream struct {
+	stream grpc.ClientStream
+}
+
+func (self *Client) StreamIds(ctx context.Context, group string, batchSize int) (*IdStream, error) {
+	stream, err := self.conn.NewStream(ctx, &serviceDesc.Streams[0], "/"+SERVICE_NAME+"/StreamIds")
+	if err != nil {
+		return nil, err
+	}
+	if err := stream.SendMsg(&StreamIdsRequest{Group: group, BatchSize: int32(batchSize)}); err != nil {
+		return nil, err
+	}
+	if err := stream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return &IdStream{stream: stream}, nil
+}
+
+// Recv returns the next batch of ids.
+func (self *IdStream) Recv() ([]uint64, error) {
+	reply := &IdsReply{}
+	if err := self.stream.RecvMsg(reply); err != nil {
+		return nil, err
+	}
+	return reply.Ids, nil
+}
@@ -0,0 +1,443 @@
+// Package rpc serves the id center over gRPC. The messages are defined in idcenter.proto and
+// encoded in protobuf, so the clients in the other languages generate their stubs from it,
+// and the Go clients use Client.
+package rpc
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"go_idcenter/auth"
+	"go_idcenter/base"
+	"go_idcenter/manager"
+	"go_idcenter/ratelimit"
+	"math"
+	"net"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+const (
+	SERVICE_NAME   = "idcenter.IdCenter"
+	MAX_BATCH_SIZE = manager.MAX_ID_COUNT
+	// The metadata of the request id, which is generated if the client does not give one.
+	REQUEST_ID_METADATA = "x-request-id"
+)
+
+func newGroupReply(groupInfo *base.GroupInfo) *GroupReply {
+	return &GroupReply{
+		Name:         groupInfo.Name,
+		Start:        groupInfo.Start,
+		Step:         groupInfo.Step,
+		Offset:       groupInfo.Offset,
+		Increment:    groupInfo.Increment,
+		Bound:        groupInfo.Bound,
+		Count:        groupInfo.Count,
+		Begin:        groupInfo.Range.Begin,
+		End:          groupInfo.Range.End,
+		LastModified: timestamppb.New(groupInfo.LastModified),
+	}
+}
+
+// The id center service exposes the manager by gRPC.
+type IdCenterService struct {
+	manager       *manager.IdCenterManager
+	authenticator *auth.Authenticator
+	rateLimiter   *ratelimit.Limiter
+	// The streams of ids end once streamsCtx is done, i.e. the server is shutting down.
+	streamsCtx  context.Context
+	stopStreams context.CancelFunc
+}
+
+// Server is the gRPC server of the id center.
+type Server struct {
+	*grpc.Server
+	service *IdCenterService
+}
+
+// Shutdown ends the streams of ids, which never end by themselves, and then stops the server
+// gracefully until ctx is done, after which the calls in flight are canceled.
+func (self *Server) Shutdown(ctx context.Context) {
+	self.service.stopStreams()
+	done := make(chan struct{})
+	go func() {
+		self.GracefulStop()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		self.Stop()
+	}
+}
+
+// NewServer creates the gRPC server of the id center. The token of client is read from the
+// metadata 'authorization' (Bearer <token>) or 'x-api-key', otherwise the client is identified
+// by its verified certificate if the TLS credentials are given by options. The authentication
+// is disabled if authenticator is nil. The allocations are limited by rateLimiter if it is not nil,
+// and the batches of streams are delayed instead of rejected.
+func NewServer(idCenterManager *manager.IdCenterManager, authenticator *auth.Authenticator, rateLimiter *ratelimit.Limiter, options ...grpc.ServerOption) *Server {
+	options = append([]grpc.ServerOption{grpc.ChainUnaryInterceptor(requestIdInterceptor)}, options...)
+	service := &IdCenterService{manager: idCenterManager, authenticator: authenticator, rateLimiter: rateLimiter}
+	service.streamsCtx, service.stopStreams = context.WithCancel(context.Background())
+	server := &Server{Server: grpc.NewServer(options...), service: service}
+	server.RegisterService(&serviceDesc, service)
+	return server
+}
+
+func (self *IdCenterService) GetId(ctx context.Context, request *GroupRequest) (*IdReply, error) {
+	if err := self.checkCall(ctx, auth.PERMISSION_ALLOCATE, request.Group); err != nil {
+		return nil, err
+	}
+	if err := self.limit(ctx, request.Group, 1); err != nil {
+		return nil, err
+	}
+	id, err := self.manager.GetIdContext(ctx, request.Group)
+	if err != nil {
+		return nil, toStatusError("Get id error", err)
+	}
+	if id == 0 {
+		return nil, status.Error(codes.Unavailable, "No id is available now!")
+	}
+	return &IdReply{Group: request.Group, Id: id}, nil
+}
+
+func (self *IdCenterService) GetIds(ctx context.Context, request *IdsRequest) (*IdsReply, error) {
+	if err := self.checkCall(ctx, auth.PERMISSION_ALLOCATE, request.Group); err != nil {
+		return nil, err
+	}
+	count := int(request.Count)
+	if count <= 0 || count > MAX_BATCH_SIZE {
+		return nil, status.Errorf(codes.InvalidArgument, "The count must be in [1, %d]!", MAX_BATCH_SIZE)
+	}
+	if err := self.limit(ctx, request.Group, uint64(count)); err != nil {
+		return nil, err
+	}
+	ids, err := self.manager.GetIdsContext(ctx, request.Group, count)
+	if err != nil {
+		return nil, toStatusError("Get ids error", err)
+	}
+	return &IdsReply{Group: request.Group, Ids: ids}, nil
+}
+
+func (self *IdCenterService) ReserveRange(ctx context.Context, request *RangeRequest) (*RangeReply, error) {
+	if err := self.checkCall(ctx, auth.PERMISSION_ALLOCATE, request.Group); err != nil {
+		return nil, err
+	}
+	if request.Size == 0 {
+		return nil, status.Error(codes.InvalidArgument, "The size must be positive!")
+	}
+	if err := self.limit(ctx, request.Group, request.Size); err != nil {
+		return nil, err
+	}
+	idRange, err := self.manager.ReserveRangeContext(ctx, request.Group, request.Size)
+	if err != nil {
+		return nil, toStatusError("Reserve range error", err)
+	}
+	if idRange == nil {
+		return nil, status.Error(codes.Unavailable, "No range is available now!")
+	}
+	return &RangeReply{Group: request.Group, Begin: idRange.Begin, End: idRange.End, Increment: idRange.Increment}, nil
+}
+
+func (self *IdCenterService) CreateGroup(ctx context.Context, request *CreateGroupRequest) (*GroupReply, error) {
+	if err := self.checkCall(ctx, auth.PERMISSION_ADMIN, request.Group); err != nil {
+		return nil, err
+	}
+	ok, err := self.manager.CreateGroupContext(ctx, request.Group, request.Start, request.Step)
+	if err != nil {
+		return nil, toStatusError("Create group error", err)
+	}
+	if !ok {
+		return nil, status.Errorf(codes.AlreadyExists, "The group '%s' already exists!", request.Group)
+	}
+	return self.GetGroup(ctx, &GroupRequest{Group: request.Group})
+}
+
+func (self *IdCenterService) GetGroup(ctx context.Context, request *GroupRequest) (*GroupReply, error) {
+	if err := self.checkCall(ctx, auth.PERMISSION_ADMIN, request.Group); err != nil {
+		return nil, err
+	}
+	groupInfo, err := self.manager.GetGroupContext(ctx, request.Group)
+	if err != nil {
+		return nil, toStatusError("Get group error", err)
+	}
+	if groupInfo == nil {
+		return nil, status.Errorf(codes.NotFound, "The group '%s' is not found!", request.Group)
+	}
+	return newGroupReply(groupInfo), nil
+}
+
+func (self *IdCenterService) ListGroups(ctx context.Context, request *GroupRequest) (*GroupListReply, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, status.FromContextError(err).Err()
+	}
+	if err := self.authorize(ctx, auth.PERMISSION_ADMIN, auth.ALL_GROUPS); err != nil {
+		return nil, err
+	}
+	groupInfos, err := self.manager.ListGroupsContext(ctx)
+	if err != nil {
+		return nil, toStatusError("List groups error", err)
+	}
+	groups := make([]*GroupReply, 0, len(groupInfos))
+	for i := range groupInfos {
+		groups = append(groups, newGroupReply(&groupInfos[i]))
+	}
+	return &GroupListReply{Groups: groups}, nil
+}
+
+func (self *IdCenterService) UpdateGroup(ctx context.Context, request *UpdateGroupRequest) (*GroupReply, error) {
+	if err := self.checkCall(ctx, auth.PERMISSION_ADMIN, request.Group); err != nil {
+		return nil, err
+	}
+	// The step or the bound which is not given keeps the current one.
+	groupInfo, err := self.manager.GetGroupContext(ctx, request.Group)
+	if err != nil {
+		return nil, toStatusError("Get group error", err)
+	}
+	if groupInfo == nil {
+		return nil, status.Errorf(codes.NotFound, "The group '%s' is not found!", request.Group)
+	}
+	step, bound := groupInfo.Step, groupInfo.Bound
+	if request.Step != nil {
+		step = *request.Step
+	}
+	if request.Bound != nil {
+		bound = *request.Bound
+	}
+	if step == 0 {
+		return nil, status.Error(codes.InvalidArgument, "The step must be positive!")
+	}
+	ok, err := self.manager.UpdateGroupContext(ctx, request.Group, step, bound)
+	if err != nil {
+		return nil, toStatusError("Update group error", err)
+	}
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "The group '%s' is not found!", request.Group)
+	}
+	return self.GetGroup(ctx, &GroupRequest{Group: request.Group})
+}
+
+func (self *IdCenterService) DeleteGroup(ctx context.Context, request *GroupRequest) (*ResultReply, error) {
+	if err := self.checkCall(ctx, auth.PERMISSION_ADMIN, request.Group); err != nil {
+		return nil, err
+	}
+	result, err := self.manager.ClearContext(ctx, request.Group)
+	if err != nil {
+		return nil, toStatusError("Clear id group error", err)
+	}
+	return &ResultReply{Group: request.Group, Result: result}, nil
+}
+
+// toStatusError converts the error of manager to the status by its kind, with the message prefixed by errorMsgPrefix.
+func toStatusError(errorMsgPrefix string, err error) error {
+	code := codes.Internal
+	switch {
+	case errors.Is(err, base.ErrTimeout):
+		code = codes.DeadlineExceeded
+	case errors.Is(err, base.ErrCanceled):
+		code = codes.Canceled
+	case errors.Is(err, base.ErrInvalidGroupName), errors.Is(err, base.ErrInvalidArgument):
+		code = codes.InvalidArgument
+	case errors.Is(err, base.ErrGroupNotFound):
+		code = codes.NotFound
+	case errors.Is(err, base.ErrConflict):
+		code = codes.AlreadyExists
+	case errors.Is(err, base.ErrExhausted):
+		code = codes.ResourceExhausted
+	case errors.Is(err, base.ErrStorageUnavailable), errors.Is(err, base.ErrCacheUnavailable), errors.Is(err, base.ErrShutDown):
+		code = codes.Unavailable
+	}
+	return status.Errorf(code, "%s: %s", errorMsgPrefix, err)
+}
+
+// StreamIds pushes the ids of group to the client until the stream is closed.
+func (self *IdCenterService) StreamIds(request *StreamIdsRequest, stream grpc.ServerStream) error {
+	ctx, cancel := context.WithCancel(withRequestId(stream.Context()))
+	defer cancel()
+	stopAfter := context.AfterFunc(self.streamsCtx, cancel)
+	defer stopAfter()
+	if err := self.checkCall(ctx, auth.PERMISSION_ALLOCATE, request.Group); err != nil {
+		return err
+	}
+	batchSize := int(request.BatchSize)
+	if batchSize <= 0 || batchSize > MAX_BATCH_SIZE {
+		return status.Errorf(codes.InvalidArgument, "The batch size must be in [1, %d]!", MAX_BATCH_SIZE)
+	}
+	logger := base.Log(base.SUBSYSTEM_RPC)
+	logger.InfoContext(ctx, "Start streaming ids.", base.LOG_FIELD_GROUP, request.Group, "batch_size", request.BatchSize)
+	client := self.clientIdentity(ctx)
+	batches := make(chan []uint64, 1)
+	errorChan := make(chan error, 1)
+	go func() {
+		defer close(batches)
+		for {
+			if !self.waitForLimit(ctx, client, request.Group, uint64(batchSize)) {
+				return
+			}
+			ids, err := self.manager.GetIdsContext(ctx, request.Group, batchSize)
+			if err != nil {
+				errorChan <- err
+				return
+			}
+			select {
+			case batches <- ids:
+			case <-ctx.Done():
+				logger.WarnContext(ctx, "The pre-allocated ids are unsent.", base.LOG_FIELD_GROUP, request.Group, "ids", ids)
+				return
+			}
+		}
+	}()
+	for ids := range batches {
+		if err := stream.SendMsg(&IdsReply{Group: request.Group, Ids: ids}); err != nil {
+			logger.WarnContext(ctx, "Streaming ids is stopped.", base.LOG_FIELD_GROUP, request.Group, "unsent", ids,
+				base.LOG_FIELD_ERROR, err.Error(), base.LOG_FIELD_ERROR_CLASS, base.ErrorClass(err))
+			return err
+		}
+	}
+	// The allocation which is canceled by the shutdown is not an error of group.
+	if stream.Context().Err() == nil && self.streamsCtx.Err() != nil {
+		return status.Error(codes.Unavailable, "The server is shutting down!")
+	}
+	select {
+	case err := <-errorChan:
+		return toStatusError("Get ids error", err)
+	default:
+	}
+	return status.FromContextError(ctx.Err()).Err()
+}
+
+func (self *IdCenterService) checkCall(ctx context.Context, permission string, group string) error {
+	if err := ctx.Err(); err != nil {
+		return status.FromContextError(err).Err()
+	}
+	if len(group) == 0 {
+		return status.Error(codes.InvalidArgument, "The group name is empty!")
+	}
+	return self.authorize(ctx, permission, group)
+}
+
+// limit takes the tokens of count ids of the client and group of call from the rate limiter.
+func (self *IdCenterService) limit(ctx context.Context, group string, count uint64) error {
+	if self.rateLimiter == nil {
+		return nil
+	}
+	client := self.clientIdentity(ctx)
+	ok, wait := self.rateLimiter.AllowN(client, group, count)
+	if ok {
+		return nil
+	}
+	retryAfter := int(math.Max(1, math.Ceil(wait.Seconds())))
+	base.Log(base.SUBSYSTEM_RPC).WarnContext(ctx, "The call is rate limited.", "client", client, base.LOG_FIELD_GROUP, group, "retry_after", retryAfter)
+	return status.Errorf(codes.ResourceExhausted, "Too many requests! Retry after %d seconds.", retryAfter)
+}
+
+// waitForLimit waits until the tokens of count ids of the client and group are taken from the
+// rate limiter. It returns false if ctx is done before that.
+func (self *IdCenterService) waitForLimit(ctx context.Context, client string, group string, count uint64) bool {
+	if self.rateLimiter == nil {
+		return ctx.Err() == nil
+	}
+	for {
+		ok, wait := self.rateLimiter.AllowN(client, group, count)
+		if ok {
+			return true
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return false
+		}
+	}
+}
+
+// clientIdentity returns the name of the authenticated client, or the remote host if the authentication is disabled.
+func (self *IdCenterService) clientIdentity(ctx context.Context) string {
+	remote, state := peerOf(ctx)
+	if self.authenticator != nil {
+		if client := self.authenticator.Identify(tokenFromContext(ctx), state); client != nil {
+			return client.Name
+		}
+	}
+	host, _, err := net.SplitHostPort(remote)
+	if err != nil {
+		return remote
+	}
+	return host
+}
+
+// peerOf returns the remote address of call, and the state of its TLS connection which is nil for the plain one.
+func peerOf(ctx context.Context) (string, *tls.ConnectionState) {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return "-", nil
+	}
+	if tlsInfo, ok := p.AuthInfo.(credentials.TLSInfo); ok {
+		return p.Addr.String(), &tlsInfo.State
+	}
+	return p.Addr.String(), nil
+}
+
+// authorize checks the token in the metadata of call for the permission on group.
+// The denied attempt is audited.
+func (self *IdCenterService) authorize(ctx context.Context, permission string, group string) error {
+	if self.authenticator == nil {
+		return nil
+	}
+	remote, state := peerOf(ctx)
+	client, err := self.authenticator.Authorize(tokenFromContext(ctx), state, permission, group)
+	if err == nil {
+		return nil
+	}
+	auth.Audit(ctx, client, remote, permission, group, err)
+	if err == auth.ErrUnauthenticated {
+		return status.Error(codes.Unauthenticated, err.Error())
+	}
+	return status.Errorf(codes.PermissionDenied, "The %s operation on group '%s' is not allowed!", permission, group)
+}
+
+func requestIdInterceptor(ctx context.Context, request interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	return handler(withRequestId(ctx), request)
+}
+
+// withRequestId returns the context which carries the request id in the metadata of call, or a generated one.
+// The request id is sent back in the header of response.
+func withRequestId(ctx context.Context) context.Context {
+	var requestId string
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get(REQUEST_ID_METADATA); len(values) > 0 && base.IsValidRequestId(values[0]) {
+			requestId = values[0]
+		}
+	}
+	if len(requestId) == 0 {
+		requestId = base.NewRequestId()
+	}
+	grpc.SetHeader(ctx, metadata.Pairs(REQUEST_ID_METADATA, requestId))
+	return base.WithRequestId(ctx, requestId)
+}
+
+func tokenFromContext(ctx context.Context) string {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return ""
+	}
+	if values := md.Get("authorization"); len(values) > 0 {
+		if len(values[0]) > 7 && strings.EqualFold(values[0][:7], "Bearer ") {
+			return strings.TrimSpace(values[0][7:])
+		}
+	}
+	if values := md.Get("x-api-key"); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
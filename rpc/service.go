@@ -0,0 +1,86 @@
+package rpc
+
+import (
+	"context"
+
+	"google.golang.org/grpc"
+)
+
+type IdCenterServer interface {
+	GetId(ctx context.Context, request *GroupRequest) (*IdReply, error)
+	GetIds(ctx context.Context, request *IdsRequest) (*IdsReply, error)
+	ReserveRange(ctx context.Context, request *RangeRequest) (*RangeReply, error)
+	CreateGroup(ctx context.Context, request *CreateGroupRequest) (*GroupReply, error)
+	GetGroup(ctx context.Context, request *GroupRequest) (*GroupReply, error)
+	ListGroups(ctx context.Context, request *GroupRequest) (*GroupListReply, error)
+	UpdateGroup(ctx context.Context, request *UpdateGroupRequest) (*GroupReply, error)
+	DeleteGroup(ctx context.Context, request *GroupRequest) (*ResultReply, error)
+	StreamIds(request *StreamIdsRequest, stream grpc.ServerStream) error
+}
+
+// The service descriptor of the service IdCenter in idcenter.proto, whose messages are generated by protoc-gen-go.
+var serviceDesc = grpc.ServiceDesc{
+	ServiceName: SERVICE_NAME,
+	HandlerType: (*IdCenterServer)(nil),
+	Methods: []grpc.MethodDesc{
+		unaryMethod("GetId", func() interface{} { return &GroupRequest{} }, func(s IdCenterServer, ctx context.Context, request interface{}) (interface{}, error) {
+			return s.GetId(ctx, request.(*GroupRequest))
+		}),
+		unaryMethod("GetIds", func() interface{} { return &IdsRequest{} }, func(s IdCenterServer, ctx context.Context, request interface{}) (interface{}, error) {
+			return s.GetIds(ctx, request.(*IdsRequest))
+		}),
+		unaryMethod("ReserveRange", func() interface{} { return &RangeRequest{} }, func(s IdCenterServer, ctx context.Context, request interface{}) (interface{}, error) {
+			return s.ReserveRange(ctx, request.(*RangeRequest))
+		}),
+		unaryMethod("CreateGroup", func() interface{} { return &CreateGroupRequest{} }, func(s IdCenterServer, ctx context.Context, request interface{}) (interface{}, error) {
+			return s.CreateGroup(ctx, request.(*CreateGroupRequest))
+		}),
+		unaryMethod("GetGroup", func() interface{} { return &GroupRequest{} }, func(s IdCenterServer, ctx context.Context, request interface{}) (interface{}, error) {
+			return s.GetGroup(ctx, request.(*GroupRequest))
+		}),
+		unaryMethod("ListGroups", func() interface{} { return &GroupRequest{} }, func(s IdCenterServer, ctx context.Context, request interface{}) (interface{}, error) {
+			return s.ListGroups(ctx, request.(*GroupRequest))
+		}),
+		unaryMethod("UpdateGroup", func() interface{} { return &UpdateGroupRequest{} }, func(s IdCenterServer, ctx context.Context, request interface{}) (interface{}, error) {
+			return s.UpdateGroup(ctx, request.(*UpdateGroupRequest))
+		}),
+		unaryMethod("DeleteGroup", func() interface{} { return &GroupRequest{} }, func(s IdCenterServer, ctx context.Context, request interface{}) (interface{}, error) {
+			return s.DeleteGroup(ctx, request.(*GroupRequest))
+		}),
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "StreamIds",
+			ServerStreams: true,
+			Handler: func(srv interface{}, stream grpc.ServerStream) error {
+				request := &StreamIdsRequest{}
+				if err := stream.RecvMsg(request); err != nil {
+					return err
+				}
+				return srv.(IdCenterServer).StreamIds(request, stream)
+			},
+		},
+	},
+	Metadata: "idcenter.proto",
+}
+
+func unaryMethod(name string, newRequest func() interface{}, call func(s IdCenterServer, ctx context.Context, request interface{}) (interface{}, error)) grpc.MethodDesc {
+	fullMethod := "/" + SERVICE_NAME + "/" + name
+	return grpc.MethodDesc{
+		MethodName: name,
+		Handler: func(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+			request := newRequest()
+			if err := dec(request); err != nil {
+				return nil, err
+			}
+			if interceptor == nil {
+				return call(srv.(IdCenterServer), ctx, request)
+			}
+			info := &grpc.UnaryServerInfo{Server: srv, FullMethod: fullMethod}
+			handler := func(ctx context.Context, request interface{}) (interface{}, error) {
+				return call(srv.(IdCenterServer), ctx, request)
+			}
+			return interceptor(ctx, request, info, handler)
+		},
+	}
+}
@@ -1,45 +1,81 @@
 package main
 
 import (
-	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/json"
 	"errors"
 	"flag"
 	"fmt"
+	"go_idcenter/auth"
 	"go_idcenter/base"
+	"go_idcenter/frontend"
 	"go_idcenter/manager"
 	"go_idcenter/provider"
+	"go_idcenter/ratelimit"
+	"go_idcenter/rpc"
 	"go_lib"
+	"net"
 	"net/http"
+	"os"
+	"os/signal"
 	"strconv"
-)
+	"sync"
+	"syscall"
+	"time"
 
-var logger logging.Logger
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+)
 
 var serverPort int
+var grpcPort int
+var respPort int
+var binaryPort int
+var socketPath string
+var socketMode string
+var shutdownTimeout time.Duration
+var unusedIdsFile string
 var iConfig go_lib.Config
 var idCenterManager manager.IdCenterManager
+var providerRegistry = manager.NewRegistry()
+var authenticator *auth.Authenticator
+var tlsReloader *auth.TlsReloader
+var rateLimiter *ratelimit.Limiter
 
 func init() {
-	flag.IntVar(&serverPort, "port", 9092, "the server (http listen) port")
+	flag.IntVar(&serverPort, "port", 9092, "the server (http listen) port, 0 means disabled (e.g. only the unix socket is served)")
+	flag.IntVar(&grpcPort, "grpc-port", 0, "the gRPC server listen port, 0 means disabled")
+	flag.IntVar(&respPort, "resp-port", 0, "the RESP (Redis protocol) server listen port, 0 means disabled")
+	flag.IntVar(&binaryPort, "binary-port", 0, "the binary protocol server listen port, 0 means disabled")
+	flag.StringVar(&socketPath, "socket", "", "the path of unix domain socket which the http server also listens on, empty means disabled")
+	flag.StringVar(&socketMode, "socket-mode", "0660", "the permissions of the unix domain socket file, in octal")
+	flag.DurationVar(&shutdownTimeout, "shutdown-timeout", 10*time.Second, "the deadline of draining requests on shutdown")
+	flag.StringVar(&unusedIdsFile, "unused-ids-file", "unused_ids.json", "the file which the report of unused ids is appended to on shutdown: the ids taken into the process memory but not issued, or the counts of ids retained in Redis per group; empty means only logging")
 	iConfig = go_lib.Config{Path: base.CONFIG_FILE_NAME}
 	err := iConfig.ReadConfig(false)
 	if err != nil {
 		errorMsg := fmt.Sprintf("Config Loading error: %s", err)
-		base.Logger().Fatalf(errorMsg)
+		base.Logger().Fatalln(errorMsg)
+		panic(errors.New(errorMsg))
+	}
+	if err := base.LoadLogConfig(iConfig.Dict); err != nil {
+		errorMsg := fmt.Sprintf("The log config is INVALID! Error: %s", err)
+		base.Logger().Fatalln(errorMsg)
 		panic(errors.New(errorMsg))
 	}
 	configRedisPort := iConfig.Dict["redis_server_port"]
 	redisPort, err := strconv.Atoi(configRedisPort)
 	if err != nil {
 		errorMsg := fmt.Sprintf("The redis server port '%v' is INVALID! Error: %s", configRedisPort, err)
-		base.Logger().Fatalf(errorMsg)
+		base.Logger().Fatalln(errorMsg)
 		panic(errors.New(errorMsg))
 	}
 	configRedisPoolSize := iConfig.Dict["redis_server_pool_size"]
 	redisPoolSize, err := strconv.Atoi(configRedisPoolSize)
 	if err != nil {
 		errorMsg := fmt.Sprintf("The redis server pool size '%v' is INVALID! Error: %s", redisPoolSize, err)
-		base.Logger().Fatalf(errorMsg)
+		base.Logger().Fatalln(errorMsg)
 		panic(errors.New(errorMsg))
 	}
 	cacheParameter := provider.RedisParameter{
@@ -50,24 +86,24 @@ func init() {
 		PoolSize: uint16(redisPoolSize),
 	}
 	rcp := manager.NewRedisCacheProvider(cacheParameter)
-	err = manager.RegisterProvider(interface{}(rcp).(base.Provider))
+	err = providerRegistry.Register(interface{}(rcp).(base.Provider))
 	if err != nil {
 		errorMsg := fmt.Sprintf("Redis Cache provider register error: %s", err)
-		base.Logger().Fatalf(errorMsg)
+		base.Logger().Fatalln(errorMsg)
 		panic(errors.New(errorMsg))
 	}
 	configMysqlPort := iConfig.Dict["mysql_server_port"]
 	mysqlPort, err := strconv.Atoi(configMysqlPort)
 	if err != nil {
 		errorMsg := fmt.Sprintf("The mysql server port '%v' is INVALID! Error: %s", configMysqlPort, err)
-		base.Logger().Fatalf(errorMsg)
+		base.Logger().Fatalln(errorMsg)
 		panic(errors.New(errorMsg))
 	}
 	configMysqlPoolSize := iConfig.Dict["mysql_server_pool_size"]
 	mysqlPoolSize, err := strconv.Atoi(configMysqlPoolSize)
 	if err != nil {
 		errorMsg := fmt.Sprintf("The mysql server pool size '%v' is INVALID! Error: %s", configMysqlPoolSize, err)
-		base.Logger().Fatalf(errorMsg)
+		base.Logger().Fatalln(errorMsg)
 		panic(errors.New(errorMsg))
 	}
 	storageParameter := provider.MysqlParameter{
@@ -79,99 +115,275 @@ func init() {
 		Password: iConfig.Dict["mysql_server_password"],
 		PoolSize: uint16(mysqlPoolSize),
 	}
-	msp := manager.NewMysqlStorageProvider(storageParameter)
-	err = manager.RegisterProvider(interface{}(msp).(base.Provider))
+	msp, err := manager.NewMysqlStorageProvider(storageParameter)
+	if err != nil {
+		errorMsg := fmt.Sprintf("MySQL Storage provider initialization error: %s", err)
+		base.Logger().Fatalln(errorMsg)
+		panic(errors.New(errorMsg))
+	}
+	err = providerRegistry.Register(interface{}(msp).(base.Provider))
 	if err != nil {
 		errorMsg := fmt.Sprintf("MySQL Storage provider register error: %s", err)
-		base.Logger().Fatalf(errorMsg)
+		base.Logger().Fatalln(errorMsg)
 		panic(errors.New(errorMsg))
 	}
 	configIdStart := iConfig.Dict["id_start"]
 	idStart, err := strconv.Atoi(configIdStart)
 	if err != nil {
 		errorMsg := fmt.Sprintf("The start number of id '%v' is INVALID! Error: %s", configIdStart, err)
-		base.Logger().Fatalf(errorMsg)
+		base.Logger().Fatalln(errorMsg)
 		panic(errors.New(errorMsg))
 	}
 	configIdStep := iConfig.Dict["id_step"]
 	idStep, err := strconv.Atoi(configIdStep)
 	if err != nil {
 		errorMsg := fmt.Sprintf("The step number of id '%v' is INVALID! Error: %s", configIdStep, err)
-		base.Logger().Fatalf(errorMsg)
+		base.Logger().Fatalln(errorMsg)
+		panic(errors.New(errorMsg))
+	}
+	configIdOffset := iConfig.Dict["id_offset"]
+	idOffset, err := strconv.Atoi(configIdOffset)
+	if err != nil {
+		errorMsg := fmt.Sprintf("The offset number of id '%v' is INVALID! Error: %s", configIdOffset, err)
+		base.Logger().Fatalln(errorMsg)
+		panic(errors.New(errorMsg))
+	}
+	configIdIncrement := iConfig.Dict["id_increment"]
+	idIncrement, err := strconv.Atoi(configIdIncrement)
+	if err != nil || idIncrement <= 0 || idOffset <= 0 || idOffset > idIncrement {
+		errorMsg := fmt.Sprintf("The increment number of id '%v' is INVALID! (offset=%v) Error: %v", configIdIncrement, idOffset, err)
+		base.Logger().Fatalln(errorMsg)
+		panic(errors.New(errorMsg))
+	}
+	groupConfigs, err := manager.LoadGroupConfigs(iConfig.Dict)
+	if err != nil {
+		errorMsg := fmt.Sprintf("The group config is INVALID! Error: %s", err)
+		base.Logger().Fatalln(errorMsg)
+		panic(errors.New(errorMsg))
+	}
+	authenticator, err = auth.LoadConfig(iConfig.Dict)
+	if err != nil {
+		errorMsg := fmt.Sprintf("The auth config is INVALID! Error: %s", err)
+		base.Logger().Fatalln(errorMsg)
 		panic(errors.New(errorMsg))
 	}
+	if authenticator == nil {
+		base.Logger().Warnln("No client is configured, so the authentication is disabled.")
+	}
+	rateLimiter, err = ratelimit.LoadConfig(iConfig.Dict)
+	if err != nil {
+		errorMsg := fmt.Sprintf("The rate limit config is INVALID! Error: %s", err)
+		base.Logger().Fatalln(errorMsg)
+		panic(errors.New(errorMsg))
+	}
+	tlsParameter, err := auth.LoadTlsParameter(iConfig.Dict)
+	if err != nil {
+		errorMsg := fmt.Sprintf("The TLS config is INVALID! Error: %s", err)
+		base.Logger().Fatalln(errorMsg)
+		panic(errors.New(errorMsg))
+	}
+	if tlsParameter != nil {
+		tlsReloader, err = auth.NewTlsReloader(*tlsParameter)
+		if err != nil {
+			errorMsg := fmt.Sprintf("The TLS certificates are INVALID! Error: %s", err)
+			base.Logger().Fatalln(errorMsg)
+			panic(errors.New(errorMsg))
+		}
+	}
 	idCenterManager = manager.IdCenterManager{
+		Registry:            providerRegistry,
 		CacheProviderName:   rcp.Name(),
 		StorageProviderName: msp.Name(),
 		Start:               uint64(idStart),
 		Step:                uint32(idStep),
+		Offset:              uint64(idOffset),
+		Increment:           uint32(idIncrement),
+		Groups:              groupConfigs,
 	}
 }
 
-func doForId(w http.ResponseWriter, r *http.Request) {
-	hj, ok := w.(http.Hijacker)
-	var errorMsg string
-	if !ok {
-		errorMsg = "The Web Server does not support Hijacking! "
-		http.Error(w, errorMsg, http.StatusInternalServerError)
-		base.Logger().Errorf(errorMsg)
+// The stopper stops a server gracefully until ctx is done.
+type stopper func(ctx context.Context)
+
+// The stoppers of the started servers.
+var stoppers []stopper
+
+// listenTcp listens on port, in TLS with the application protocols nextProtos if it is configured.
+func listenTcp(port int, nextProtos ...string) (net.Listener, error) {
+	listener, err := net.Listen("tcp", fmt.Sprintf(":%d", port))
+	if err != nil || tlsReloader == nil {
+		return listener, err
+	}
+	return tls.NewListener(listener, tlsReloader.Config(nextProtos...)), nil
+}
+
+func startGrpcServer() {
+	listener, err := net.Listen("tcp", fmt.Sprintf(":%d", grpcPort))
+	if err != nil {
+		base.Logger().Fatalln("gRPC listen error: ", err)
 		return
 	}
-	conn, bufrw, err := hj.Hijack()
+	base.Logger().Infof("Starting id center gRPC server (port=%d, tls=%v)...\n", grpcPort, tlsReloader != nil)
+	var options []grpc.ServerOption
+	if tlsReloader != nil {
+		options = append(options, grpc.Creds(credentials.NewTLS(tlsReloader.Config("h2"))))
+	}
+	server := rpc.NewServer(&idCenterManager, authenticator, rateLimiter, options...)
+	stoppers = append(stoppers, func(ctx context.Context) {
+		server.Shutdown(ctx)
+	})
+	go func() {
+		if err := server.Serve(listener); err != nil {
+			base.Logger().Fatalln("gRPC serve error: ", err)
+		}
+	}()
+}
+
+func startRespServer() {
+	listener, err := listenTcp(respPort)
 	if err != nil {
-		errorMsg = "Internal error!"
-		http.Error(w, errorMsg, http.StatusInternalServerError)
-		base.Logger().Errorf(errorMsg+" Hijacking Error: %s\n", err)
+		base.Logger().Fatalln("RESP listen error: ", err)
 		return
 	}
-	defer conn.Close()
-	r.ParseForm()
-	group := r.FormValue("group")
-	op := r.FormValue("op")
-	base.Logger().Infof("Receive a request for id (group=%s, op=%s))...\n", group, op)
-	var respContent interface{}
-	if op == "clear" {
-		result, err := idCenterManager.Clear(group)
-		if err != nil {
-			errorMsg = fmt.Sprintf("Clear id group error: %s", err)
-			base.Logger().Errorln(errorMsg)
+	base.Logger().Infof("Starting id center RESP server (port=%d, tls=%v)...\n", respPort, tlsReloader != nil)
+	respFrontend := frontend.NewRespFrontend(&idCenterManager)
+	respFrontend.Authenticator = authenticator
+	respFrontend.RateLimiter = rateLimiter
+	stoppers = append(stoppers, func(ctx context.Context) {
+		respFrontend.Shutdown(ctx)
+	})
+	go func() {
+		if err := respFrontend.Serve(listener); err != nil {
+			base.Logger().Fatalln("RESP serve error: ", err)
 		}
-		respContent = interface{}(result)
-	} else {
-		currentId, err := idCenterManager.GetId(group)
-		if err != nil {
-			errorMsg = fmt.Sprintf("Get id error: %s", err)
-			base.Logger().Errorln(errorMsg)
+	}()
+}
+
+func startBinaryServer() {
+	listener, err := listenTcp(binaryPort)
+	if err != nil {
+		base.Logger().Fatalln("Binary listen error: ", err)
+		return
+	}
+	base.Logger().Infof("Starting id center binary server (port=%d, tls=%v)...\n", binaryPort, tlsReloader != nil)
+	binaryFrontend := frontend.NewBinaryFrontend(&idCenterManager)
+	binaryFrontend.Authenticator = authenticator
+	binaryFrontend.RateLimiter = rateLimiter
+	stoppers = append(stoppers, func(ctx context.Context) {
+		binaryFrontend.Shutdown(ctx)
+	})
+	go func() {
+		if err := binaryFrontend.Serve(listener); err != nil {
+			base.Logger().Fatalln("Binary serve error: ", err)
 		}
-		respContent = interface{}(currentId)
+	}()
+}
+
+func startHttpServer(listener net.Listener) {
+	httpFrontend := frontend.NewHttpFrontend(&idCenterManager)
+	httpFrontend.Authenticator = authenticator
+	httpFrontend.RateLimiter = rateLimiter
+	server := &http.Server{Handler: httpFrontend}
+	stoppers = append(stoppers, func(ctx context.Context) {
+		if err := server.Shutdown(ctx); err != nil {
+			base.Logger().Warnf("The http server (addr=%s) is not shut down gracefully: %s\n", listener.Addr(), err)
+			server.Close()
+		}
+	})
+	go func() {
+		if err := server.Serve(listener); err != nil && err != http.ErrServerClosed {
+			base.Logger().Fatalln("Http serve error: ", err)
+		}
+	}()
+}
+
+// shutdown stops the servers, drains the allocations in flight, records the unused ids and closes the providers.
+func shutdown() {
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	var stopping sync.WaitGroup
+	for _, stop := range stoppers {
+		stopping.Add(1)
+		go func(stop stopper) {
+			defer stopping.Done()
+			stop(ctx)
+		}(stop)
 	}
-	if len(errorMsg) > 0 {
-		respContent = interface{}("Internal error!")
+	stopping.Wait()
+	idCenterManager.Shutdown(ctx)
+	report, err := idCenterManager.DrainUnusedIds()
+	if err != nil {
+		base.Logger().Errorln("Draining unused ids error: ", err)
+	} else if report != nil {
+		recordUnusedIds(report)
 	}
-	pushResponse(bufrw, respContent, group, op)
+	providerRegistry.Close()
 }
 
-func pushResponse(bufrw *bufio.ReadWriter, content interface{}, group string, op string) {
-	literals := fmt.Sprintf("%v", content)
-	_, err := bufrw.Write([]byte(literals))
-	if err == nil {
-		err = bufrw.Flush()
+// recordUnusedIds appends the report of unused ids to the file in a json line.
+func recordUnusedIds(report *manager.UnusedIdsReport) {
+	content, err := json.Marshal(report)
+	if err != nil {
+		base.Logger().Errorln("Json marshalling error: ", err)
+		return
 	}
+	base.Logger().Infof("The unused ids: %s\n", content)
+	if len(unusedIdsFile) == 0 {
+		return
+	}
+	file, err := os.OpenFile(unusedIdsFile, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
 	if err != nil {
-		base.Logger().Errorf("Pushing response error (content=%v, group=%s, op=%s): %s\n", literals, group, op, err)
-	} else {
-		base.Logger().Errorf("The response '%v' has been pushed. (group=%s, op=%s)\n", literals, group, op)
+		base.Logger().Errorf("Opening the file of unused ids '%s' error: %s\n", unusedIdsFile, err)
+		return
+	}
+	defer file.Close()
+	if _, err := file.Write(append(content, '\n')); err != nil {
+		base.Logger().Errorf("Writing the file of unused ids '%s' error: %s\n", unusedIdsFile, err)
 	}
 }
 
 func main() {
 	flag.Parse()
-	http.HandleFunc("/id", doForId)
-	base.Logger().Infof("Starting id center http server (port=%d)...\n", serverPort)
-	err := http.ListenAndServe(":"+fmt.Sprintf("%d", serverPort), nil)
-	if err != nil {
-		base.Logger().Fatalln("Listen and serve error: ", err)
-	} else {
-		base.Logger().Infoln("The id center http server is started.")
+	mode, err := strconv.ParseUint(socketMode, 8, 32)
+	if err != nil || mode > 0777 {
+		base.Logger().Fatalf("The socket mode '%s' is INVALID!\n", socketMode)
+		return
+	}
+	if serverPort <= 0 && len(socketPath) == 0 {
+		base.Logger().Fatalln("Neither the http port nor the unix socket is given!")
+		return
+	}
+	if serverPort > 0 {
+		listener, err := listenTcp(serverPort, "h2", "http/1.1")
+		if err != nil {
+			base.Logger().Fatalln("Http listen error: ", err)
+			return
+		}
+		base.Logger().Infof("Starting id center http server (port=%d, tls=%v)...\n", serverPort, tlsReloader != nil)
+		startHttpServer(listener)
+	}
+	if len(socketPath) > 0 {
+		listener, err := frontend.ListenUnix(socketPath, os.FileMode(mode))
+		if err != nil {
+			base.Logger().Fatalln("Unix socket listen error: ", err)
+			return
+		}
+		base.Logger().Infof("Starting id center http server (socket=%s, mode=%#o)...\n", socketPath, mode)
+		startHttpServer(listener)
+	}
+	if grpcPort > 0 {
+		startGrpcServer()
+	}
+	if respPort > 0 {
+		startRespServer()
+	}
+	if binaryPort > 0 {
+		startBinaryServer()
 	}
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGTERM, syscall.SIGINT)
+	received := <-signals
+	base.Logger().Infof("Receive the signal '%s'. Shutting down (timeout=%s)...\n", received, shutdownTimeout)
+	shutdown()
+	base.Logger().Infoln("The id center is shut down.")
 }
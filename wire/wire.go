@@ -0,0 +1,256 @@
+// Package wire is the codec of the compact binary protocol of the id center.
+//
+// The protocol runs over persistent TCP connections. Every request and response
+// is a frame, and all integers are in big-endian:
+//
+//	+----------------+--------+--------------------+-------------------+
+//	| length: uint32 | op: u8 | request id: uint32 | payload           |
+//	+----------------+--------+--------------------+-------------------+
+//
+// The length counts the bytes after itself, i.e. 5 plus the payload length, and
+// must not exceed MAX_FRAME_LENGTH. The request id is chosen by the client and is
+// echoed in the response, so that the client can pipeline requests on one
+// connection and match the responses, which may come back in any order.
+//
+// The requests:
+//
+//	OP_PING     (0x01)  empty payload
+//	OP_GET_ID   (0x02)  group length: uint16, group
+//	OP_GET_IDS  (0x03)  group length: uint16, group, count: uint16 (in [1, MAX_BATCH_SIZE])
+//	OP_AUTH     (0x04)  token length: uint16, token
+//
+// The token of OP_AUTH applies to the requests which follow it on the connection. The
+// server with authentication rejects the other requests by ERROR_CODE_UNAUTHENTICATED
+// until a valid token is given, unless the connection is in TLS with a known client
+// certificate.
+//
+// The responses, whose op is the one of request with the high bit set:
+//
+//	OP_PONG     (0x81)  empty payload
+//	OP_ID       (0x82)  id: uint64
+//	OP_IDS      (0x83)  count: uint16, ids: uint64 * count
+//	OP_AUTH_OK  (0x84)  empty payload
+//	OP_ERROR    (0xFF)  error code: uint16, message length: uint16, message
+package wire
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+const (
+	OP_PING    byte = 0x01
+	OP_GET_ID  byte = 0x02
+	OP_GET_IDS byte = 0x03
+	OP_AUTH    byte = 0x04
+	OP_PONG    byte = 0x81
+	OP_ID      byte = 0x82
+	OP_IDS     byte = 0x83
+	OP_AUTH_OK byte = 0x84
+	OP_ERROR   byte = 0xFF
+)
+
+const (
+	ERROR_CODE_INVALID_REQUEST uint16 = 1
+	ERROR_CODE_INVALID_GROUP   uint16 = 2
+	ERROR_CODE_UNKNOWN_OP      uint16 = 3
+	ERROR_CODE_UNAVAILABLE     uint16 = 4
+	ERROR_CODE_INTERNAL_ERROR  uint16 = 5
+	ERROR_CODE_UNAUTHENTICATED uint16 = 6
+	ERROR_CODE_FORBIDDEN       uint16 = 7
+	ERROR_CODE_RATE_LIMITED    uint16 = 8
+	ERROR_CODE_EXHAUSTED       uint16 = 9
+	ERROR_CODE_TIMEOUT         uint16 = 10
+)
+
+const (
+	HEADER_LENGTH    = 9
+	MAX_FRAME_LENGTH = 64 * 1024
+	MAX_BATCH_SIZE   = 1000
+)
+
+type Frame struct {
+	Op        byte
+	RequestId uint32
+	Payload   []byte
+}
+
+// The error which is carried by the frame of OP_ERROR.
+type Error struct {
+	Code    uint16
+	Message string
+}
+
+func (self *Error) Error() string {
+	return fmt.Sprintf("id center error %d: %s", self.Code, self.Message)
+}
+
+func ReadFrame(reader io.Reader) (*Frame, error) {
+	header := make([]byte, HEADER_LENGTH)
+	if _, err := io.ReadFull(reader, header); err != nil {
+		return nil, err
+	}
+	length := binary.BigEndian.Uint32(header[0:4])
+	if length < HEADER_LENGTH-4 || length > MAX_FRAME_LENGTH {
+		return nil, fmt.Errorf("The frame length '%d' is INVALID!", length)
+	}
+	frame := &Frame{Op: header[4], RequestId: binary.BigEndian.Uint32(header[5:9])}
+	frame.Payload = make([]byte, length-(HEADER_LENGTH-4))
+	if _, err := io.ReadFull(reader, frame.Payload); err != nil {
+		return nil, err
+	}
+	return frame, nil
+}
+
+func WriteFrame(writer io.Writer, frame *Frame) error {
+	length := HEADER_LENGTH - 4 + len(frame.Payload)
+	if length > MAX_FRAME_LENGTH {
+		return fmt.Errorf("The frame length '%d' is too large!", length)
+	}
+	buffer := make([]byte, HEADER_LENGTH+len(frame.Payload))
+	binary.BigEndian.PutUint32(buffer[0:4], uint32(length))
+	buffer[4] = frame.Op
+	binary.BigEndian.PutUint32(buffer[5:9], frame.RequestId)
+	copy(buffer[HEADER_LENGTH:], frame.Payload)
+	_, err := writer.Write(buffer)
+	return err
+}
+
+func NewPingRequest(requestId uint32) *Frame {
+	return &Frame{Op: OP_PING, RequestId: requestId}
+}
+
+func NewGetIdRequest(requestId uint32, group string) *Frame {
+	return &Frame{Op: OP_GET_ID, RequestId: requestId, Payload: appendString(nil, group)}
+}
+
+func NewGetIdsRequest(requestId uint32, group string, count int) *Frame {
+	payload := appendString(nil, group)
+	payload = binary.BigEndian.AppendUint16(payload, uint16(count))
+	return &Frame{Op: OP_GET_IDS, RequestId: requestId, Payload: payload}
+}
+
+func NewAuthRequest(requestId uint32, token string) *Frame {
+	return &Frame{Op: OP_AUTH, RequestId: requestId, Payload: appendString(nil, token)}
+}
+
+// ParseAuthRequest returns the token of request of OP_AUTH.
+func ParseAuthRequest(frame *Frame) (string, error) {
+	if frame.Op != OP_AUTH {
+		return "", fmt.Errorf("The op '%#x' is not an auth request!", frame.Op)
+	}
+	token, rest, err := readString(frame.Payload)
+	if err != nil {
+		return "", err
+	}
+	if len(rest) != 0 {
+		return "", errors.New("The payload of auth request is INVALID!")
+	}
+	return token, nil
+}
+
+// ParseGroupRequest returns the group and the count of request of OP_GET_ID or OP_GET_IDS.
+func ParseGroupRequest(frame *Frame) (string, int, error) {
+	group, rest, err := readString(frame.Payload)
+	if err != nil {
+		return "", 0, err
+	}
+	switch frame.Op {
+	case OP_GET_ID:
+		if len(rest) != 0 {
+			return "", 0, errors.New("The payload of get id request is INVALID!")
+		}
+		return group, 1, nil
+	case OP_GET_IDS:
+		if len(rest) != 2 {
+			return "", 0, errors.New("The payload of get ids request is INVALID!")
+		}
+		return group, int(binary.BigEndian.Uint16(rest)), nil
+	}
+	return "", 0, fmt.Errorf("The op '%#x' is not a group request!", frame.Op)
+}
+
+func NewPongResponse(requestId uint32) *Frame {
+	return &Frame{Op: OP_PONG, RequestId: requestId}
+}
+
+func NewAuthOkResponse(requestId uint32) *Frame {
+	return &Frame{Op: OP_AUTH_OK, RequestId: requestId}
+}
+
+func NewIdResponse(requestId uint32, id uint64) *Frame {
+	return &Frame{Op: OP_ID, RequestId: requestId, Payload: binary.BigEndian.AppendUint64(nil, id)}
+}
+
+func NewIdsResponse(requestId uint32, ids []uint64) *Frame {
+	payload := make([]byte, 0, 2+8*len(ids))
+	payload = binary.BigEndian.AppendUint16(payload, uint16(len(ids)))
+	for _, id := range ids {
+		payload = binary.BigEndian.AppendUint64(payload, id)
+	}
+	return &Frame{Op: OP_IDS, RequestId: requestId, Payload: payload}
+}
+
+func NewErrorResponse(requestId uint32, code uint16, message string) *Frame {
+	if len(message) > MAX_FRAME_LENGTH/2 {
+		message = message[:MAX_FRAME_LENGTH/2]
+	}
+	payload := binary.BigEndian.AppendUint16(nil, code)
+	return &Frame{Op: OP_ERROR, RequestId: requestId, Payload: appendString(payload, message)}
+}
+
+// ParseIds returns the ids of response of OP_ID or OP_IDS, or the error of response of OP_ERROR.
+func ParseIds(frame *Frame) ([]uint64, error) {
+	switch frame.Op {
+	case OP_ID:
+		if len(frame.Payload) != 8 {
+			return nil, errors.New("The payload of id response is INVALID!")
+		}
+		return []uint64{binary.BigEndian.Uint64(frame.Payload)}, nil
+	case OP_IDS:
+		if len(frame.Payload) < 2 {
+			return nil, errors.New("The payload of ids response is INVALID!")
+		}
+		count := int(binary.BigEndian.Uint16(frame.Payload))
+		if len(frame.Payload) != 2+8*count {
+			return nil, errors.New("The payload of ids response is INVALID!")
+		}
+		ids := make([]uint64, count)
+		for i := range ids {
+			ids[i] = binary.BigEndian.Uint64(frame.Payload[2+8*i:])
+		}
+		return ids, nil
+	case OP_ERROR:
+		return nil, ParseError(frame)
+	}
+	return nil, fmt.Errorf("The op '%#x' is not an ids response!", frame.Op)
+}
+
+func ParseError(frame *Frame) error {
+	if frame.Op != OP_ERROR || len(frame.Payload) < 2 {
+		return errors.New("The error response is INVALID!")
+	}
+	message, _, err := readString(frame.Payload[2:])
+	if err != nil {
+		return err
+	}
+	return &Error{Code: binary.BigEndian.Uint16(frame.Payload), Message: message}
+}
+
+func appendString(buffer []byte, value string) []byte {
+	buffer = binary.BigEndian.AppendUint16(buffer, uint16(len(value)))
+	return append(buffer, value...)
+}
+
+func readString(buffer []byte) (string, []byte, error) {
+	if len(buffer) < 2 {
+		return "", nil, errors.New("The string is truncated!")
+	}
+	length := int(binary.BigEndian.Uint16(buffer))
+	if len(buffer) < 2+length {
+		return "", nil, errors.New("The string is truncated!")
+	}
+	return string(buffer[2 : 2+length]), buffer[2+length:], nil
+}
@@ -0,0 +1,70 @@
+package wire
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestFrameCodec(t *testing.T) {
+	var buffer bytes.Buffer
+	requests := []*Frame{
+		NewPingRequest(1),
+		NewGetIdRequest(2, "wire_test"),
+		NewGetIdsRequest(3, "wire_test", 10),
+		NewAuthRequest(4, "wire_test_token"),
+	}
+	for _, request := range requests {
+		if err := WriteFrame(&buffer, request); err != nil {
+			t.Errorf("Write frame error: %s", err)
+			t.FailNow()
+		}
+	}
+	for _, request := range requests {
+		frame, err := ReadFrame(&buffer)
+		if err != nil {
+			t.Errorf("Read frame error: %s", err)
+			t.FailNow()
+		}
+		if frame.Op != request.Op || frame.RequestId != request.RequestId || !bytes.Equal(frame.Payload, request.Payload) {
+			t.Errorf("The frame %v is not equals %v.", frame, request)
+			t.FailNow()
+		}
+	}
+	group, count, err := ParseGroupRequest(requests[2])
+	if err != nil || group != "wire_test" || count != 10 {
+		t.Errorf("The parsed request (group=%s, count=%d, err=%v) is INVALID!", group, count, err)
+		t.FailNow()
+	}
+	if token, err := ParseAuthRequest(requests[3]); err != nil || token != "wire_test_token" {
+		t.Errorf("The parsed auth request (token=%s, err=%v) is INVALID!", token, err)
+		t.FailNow()
+	}
+	if _, _, err := ParseGroupRequest(&Frame{Op: OP_GET_ID, Payload: []byte{0, 9, 'a'}}); err == nil {
+		t.Errorf("The truncated request should be rejected.")
+		t.FailNow()
+	}
+	buffer.Write([]byte{0, 0, 0, 1, OP_PING})
+	if _, err := ReadFrame(&buffer); err == nil {
+		t.Errorf("The frame of invalid length should be rejected.")
+		t.FailNow()
+	}
+}
+
+func TestResponseCodec(t *testing.T) {
+	ids, err := ParseIds(NewIdResponse(1, 42))
+	if err != nil || len(ids) != 1 || ids[0] != 42 {
+		t.Errorf("The parsed ids %v (err=%v) is INVALID!", ids, err)
+		t.FailNow()
+	}
+	ids, err = ParseIds(NewIdsResponse(2, []uint64{1, 2, 1 << 63}))
+	if err != nil || len(ids) != 3 || ids[2] != 1<<63 {
+		t.Errorf("The parsed ids %v (err=%v) is INVALID!", ids, err)
+		t.FailNow()
+	}
+	_, err = ParseIds(NewErrorResponse(3, ERROR_CODE_UNAVAILABLE, "No id"))
+	wireErr, ok := err.(*Error)
+	if !ok || wireErr.Code != ERROR_CODE_UNAVAILABLE || wireErr.Message != "No id" {
+		t.Errorf("The parsed error %v is INVALID!", err)
+		t.FailNow()
+	}
+}